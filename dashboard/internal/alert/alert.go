@@ -0,0 +1,268 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"arcticmon/internal/models"
+	"arcticmon/internal/store"
+)
+
+// Alert states.
+const (
+	StatePending  = "pending"
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+const maxResolved = 50
+
+// Duration wraps time.Duration so rules can use "5m" in JSON.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Rule is a declarative threshold check against a signal.
+type Rule struct {
+	Name      string   `json:"name"`
+	Metric    string   `json:"metric"`
+	Match     string   `json:"match,omitempty"` // only evaluate this key (mount, container...)
+	Op        string   `json:"op"`
+	Threshold float64  `json:"threshold"`
+	For       Duration `json:"for"`
+	Severity  string   `json:"severity"`
+}
+
+// DefaultRules are used when no rules file is configured.
+var DefaultRules = []Rule{
+	{Name: "container-unhealthy", Metric: "service.unhealthy", Op: ">", Threshold: 0, For: Duration(time.Minute), Severity: "critical"},
+	{Name: "container-down", Metric: "service.down", Op: ">", Threshold: 0, For: Duration(2 * time.Minute), Severity: "warning"},
+	{Name: "disk-full", Metric: "host.diskPercent", Op: ">", Threshold: 90, For: Duration(5 * time.Minute), Severity: "critical"},
+	{Name: "memory-high", Metric: "host.memPercent", Op: ">", Threshold: 95, For: Duration(5 * time.Minute), Severity: "warning"},
+	{Name: "vpn-leak", Metric: "vpn.leaks", Op: ">", Threshold: 0, Severity: "critical"},
+	{Name: "ssh-bruteforce", Metric: "ssh.failed24h", Op: ">", Threshold: 100, Severity: "warning"},
+}
+
+// LoadRules reads rules from a JSON file. An empty path returns DefaultRules.
+func LoadRules(path string) ([]Rule, error) {
+	if path == "" {
+		return DefaultRules, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d: name is required", i)
+		}
+		if _, ok := signals[r.Metric]; !ok {
+			return nil, fmt.Errorf("rule %s: unknown metric %q", r.Name, r.Metric)
+		}
+		if _, ok := compare(r.Op, 0, 0); !ok {
+			return nil, fmt.Errorf("rule %s: unknown op %q", r.Name, r.Op)
+		}
+		if r.Severity == "" {
+			rules[i].Severity = "warning"
+		}
+	}
+	return rules, nil
+}
+
+// Engine evaluates rules on every store update and dispatches notifications
+// on firing/resolved transitions.
+type Engine struct {
+	store     *store.Store
+	rules     []Rule
+	notifiers []Notifier
+
+	mu       sync.Mutex
+	active   map[string]*models.Alert
+	resolved []models.Alert
+}
+
+// NewEngine creates an alert engine. Call Start to begin evaluating.
+func NewEngine(s *store.Store, rules []Rule, notifiers []Notifier) *Engine {
+	return &Engine{
+		store:     s,
+		rules:     rules,
+		notifiers: notifiers,
+		active:    make(map[string]*models.Alert),
+	}
+}
+
+// Start subscribes the engine to store updates.
+func (e *Engine) Start() {
+	e.store.OnUpdate(func(event string) {
+		if event == "alerts" {
+			return
+		}
+		e.evaluate(time.Now())
+	})
+}
+
+// Reload replaces the rules and notifiers. Active alerts whose rule no
+// longer exists are dropped on the next evaluation.
+func (e *Engine) Reload(rules []Rule, notifiers []Notifier) {
+	e.mu.Lock()
+	e.rules = rules
+	e.notifiers = notifiers
+	e.mu.Unlock()
+}
+
+func (e *Engine) evaluate(now time.Time) {
+	data := e.store.Get()
+
+	e.mu.Lock()
+	var transitions []models.Alert
+	changed := false
+	seen := make(map[string]bool)
+
+	for _, rule := range e.rules {
+		for _, smp := range signals[rule.Metric](data) {
+			if rule.Match != "" && smp.key != rule.Match {
+				continue
+			}
+			id := rule.Name
+			if smp.key != "" {
+				id += ":" + smp.key
+			}
+			cond, _ := compare(rule.Op, smp.value, rule.Threshold)
+			if !cond {
+				continue
+			}
+			seen[id] = true
+
+			a, ok := e.active[id]
+			if !ok {
+				a = &models.Alert{
+					ID:        id,
+					Rule:      rule.Name,
+					Key:       smp.key,
+					Severity:  rule.Severity,
+					State:     StatePending,
+					Threshold: rule.Threshold,
+					StartsAt:  now,
+				}
+				e.active[id] = a
+				changed = true
+			}
+			a.Value = smp.value
+			a.Message = describe(rule, smp)
+			if a.State == StatePending && now.Sub(a.StartsAt) >= time.Duration(rule.For) {
+				a.State = StateFiring
+				fired := now
+				a.FiredAt = &fired
+				transitions = append(transitions, *a)
+			}
+		}
+	}
+
+	for id, a := range e.active {
+		if seen[id] {
+			continue
+		}
+		delete(e.active, id)
+		changed = true
+		if a.State != StateFiring {
+			continue
+		}
+		a.State = StateResolved
+		resolvedAt := now
+		a.ResolvedAt = &resolvedAt
+		transitions = append(transitions, *a)
+		e.resolved = append([]models.Alert{*a}, e.resolved...)
+		if len(e.resolved) > maxResolved {
+			e.resolved = e.resolved[:maxResolved]
+		}
+	}
+
+	if changed || len(transitions) > 0 {
+		// Published under e.mu so concurrent evaluations cannot reorder
+		// snapshots; the store listener ignores "alerts" events.
+		e.store.UpdateAlerts(e.snapshotLocked())
+	}
+	notifiers := e.notifiers
+	e.mu.Unlock()
+
+	for _, a := range transitions {
+		go dispatch(notifiers, a)
+	}
+}
+
+// snapshotLocked returns firing and pending alerts (firing first), followed
+// by recently resolved ones. Caller must hold e.mu.
+func (e *Engine) snapshotLocked() []models.Alert {
+	out := make([]models.Alert, 0, len(e.active)+len(e.resolved))
+	for _, a := range e.active {
+		out = append(out, *a)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].State != out[j].State {
+			return out[i].State == StateFiring
+		}
+		return out[i].StartsAt.Before(out[j].StartsAt)
+	})
+	return append(out, e.resolved...)
+}
+
+func dispatch(notifiers []Notifier, a models.Alert) {
+	for _, n := range notifiers {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		if err := n.Notify(ctx, a); err != nil {
+			log.Printf("[alert] %s notify %s: %v", n.Name(), a.ID, err)
+		}
+		cancel()
+	}
+}
+
+func compare(op string, v, threshold float64) (result, ok bool) {
+	switch op {
+	case ">":
+		return v > threshold, true
+	case ">=":
+		return v >= threshold, true
+	case "<":
+		return v < threshold, true
+	case "<=":
+		return v <= threshold, true
+	case "==":
+		return v == threshold, true
+	case "!=":
+		return v != threshold, true
+	}
+	return false, false
+}
+
+func describe(r Rule, s sample) string {
+	subject := r.Metric
+	if s.key != "" {
+		subject += "[" + s.key + "]"
+	}
+	return fmt.Sprintf("%s = %.1f %s %.1f", subject, s.value, r.Op, r.Threshold)
+}
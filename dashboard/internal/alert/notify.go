@@ -0,0 +1,186 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"arcticmon/internal/config"
+	"arcticmon/internal/models"
+)
+
+// Notifier delivers alert transitions to an external system.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, a models.Alert) error
+}
+
+// NotifiersFromConfig builds every notifier that has its endpoint configured.
+func NotifiersFromConfig(cfg *config.Config) []Notifier {
+	client := &http.Client{Timeout: 10 * time.Second}
+	var out []Notifier
+	if cfg.AlertWebhookURL != "" {
+		out = append(out, &WebhookNotifier{URL: cfg.AlertWebhookURL, client: client})
+	}
+	if cfg.AlertNtfyURL != "" {
+		out = append(out, &NtfyNotifier{URL: cfg.AlertNtfyURL, Token: cfg.AlertNtfyToken, client: client})
+	}
+	if cfg.AlertGotifyURL != "" && cfg.AlertGotifyToken != "" {
+		out = append(out, &GotifyNotifier{URL: cfg.AlertGotifyURL, Token: cfg.AlertGotifyToken, client: client})
+	}
+	if cfg.SMTPHost != "" && cfg.SMTPTo != "" {
+		out = append(out, &SMTPNotifier{
+			Addr: net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+			User: cfg.SMTPUser,
+			Pass: cfg.SMTPPass,
+			From: cfg.SMTPFrom,
+			To:   strings.Split(cfg.SMTPTo, ","),
+		})
+	}
+	return out
+}
+
+func title(a models.Alert) string {
+	return fmt.Sprintf("[%s] %s %s", strings.ToUpper(a.State), a.Severity, a.ID)
+}
+
+// WebhookNotifier POSTs the alert as JSON.
+type WebhookNotifier struct {
+	URL    string
+	client *http.Client
+}
+
+func (n *WebhookNotifier) Name() string { return "webhook" }
+
+func (n *WebhookNotifier) Notify(ctx context.Context, a models.Alert) error {
+	body, err := json.Marshal(map[string]any{
+		"title": title(a),
+		"alert": a,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doNotify(n.client, req)
+}
+
+// NtfyNotifier publishes to an ntfy topic URL (e.g. https://ntfy.sh/mytopic).
+type NtfyNotifier struct {
+	URL    string
+	Token  string
+	client *http.Client
+}
+
+func (n *NtfyNotifier) Name() string { return "ntfy" }
+
+func (n *NtfyNotifier) Notify(ctx context.Context, a models.Alert) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", n.URL, strings.NewReader(a.Message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", title(a))
+	req.Header.Set("Tags", a.Severity+","+a.State)
+	priority := "default"
+	if a.State == StateFiring {
+		switch a.Severity {
+		case "critical":
+			priority = "urgent"
+		case "warning":
+			priority = "high"
+		}
+	}
+	req.Header.Set("Priority", priority)
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+	return doNotify(n.client, req)
+}
+
+// GotifyNotifier posts to a Gotify server's /message endpoint.
+type GotifyNotifier struct {
+	URL    string
+	Token  string
+	client *http.Client
+}
+
+func (n *GotifyNotifier) Name() string { return "gotify" }
+
+func (n *GotifyNotifier) Notify(ctx context.Context, a models.Alert) error {
+	priority := 2
+	if a.State == StateFiring {
+		switch a.Severity {
+		case "critical":
+			priority = 8
+		case "warning":
+			priority = 5
+		}
+	}
+	body, err := json.Marshal(map[string]any{
+		"title":    title(a),
+		"message":  a.Message,
+		"priority": priority,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST",
+		strings.TrimSuffix(n.URL, "/")+"/message", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", n.Token)
+	return doNotify(n.client, req)
+}
+
+// SMTPNotifier sends a plain-text email per transition.
+type SMTPNotifier struct {
+	Addr string
+	User string
+	Pass string
+	From string
+	To   []string
+}
+
+func (n *SMTPNotifier) Name() string { return "smtp" }
+
+func (n *SMTPNotifier) Notify(_ context.Context, a models.Alert) error {
+	var auth smtp.Auth
+	if n.User != "" {
+		host, _, _ := net.SplitHostPort(n.Addr)
+		auth = smtp.PlainAuth("", n.User, n.Pass, host)
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&msg, "Subject: Arctic Monitor %s\r\n", title(a))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n\r\nStarted: %s\r\n", a.Message, a.StartsAt.Format(time.RFC3339))
+	if a.ResolvedAt != nil {
+		fmt.Fprintf(&msg, "Resolved: %s\r\n", a.ResolvedAt.Format(time.RFC3339))
+	}
+	return smtp.SendMail(n.Addr, auth, n.From, n.To, msg.Bytes())
+}
+
+func doNotify(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package alert
+
+import "arcticmon/internal/models"
+
+// sample is one value of a signal, optionally keyed (mount, container...).
+type sample struct {
+	key   string
+	value float64
+}
+
+// signals maps rule metric names to extractors over the dashboard state.
+var signals = map[string]func(d models.DashboardData) []sample{
+	"host.cpuPercent": func(d models.DashboardData) []sample {
+		return []sample{{value: d.Host.CPUPercent}}
+	},
+	"host.memPercent": func(d models.DashboardData) []sample {
+		return []sample{{value: d.Host.MemPercent}}
+	},
+	"host.swapPercent": func(d models.DashboardData) []sample {
+		return []sample{{value: d.Host.SwapPercent}}
+	},
+	"host.diskPercent": func(d models.DashboardData) []sample {
+		out := make([]sample, 0, len(d.Host.Disks))
+		for _, disk := range d.Host.Disks {
+			out = append(out, sample{key: disk.Mount, value: disk.Percent})
+		}
+		return out
+	},
+	"host.gpuTempC": func(d models.DashboardData) []sample {
+		if !d.Host.GPU.Available {
+			return nil
+		}
+		return []sample{{value: float64(d.Host.GPU.TempC)}}
+	},
+	"service.unhealthy": func(d models.DashboardData) []sample {
+		out := make([]sample, 0, len(d.Services))
+		for _, svc := range d.Services {
+			out = append(out, sample{key: svc.Name, value: boolValue(svc.Health == "unhealthy")})
+		}
+		return out
+	},
+	"service.down": func(d models.DashboardData) []sample {
+		out := make([]sample, 0, len(d.Services))
+		for _, svc := range d.Services {
+			out = append(out, sample{key: svc.Name, value: boolValue(svc.Status != "running")})
+		}
+		return out
+	},
+	"ssh.failed24h": func(d models.DashboardData) []sample {
+		return []sample{{value: float64(d.SSHSecurity.Failed24h)}}
+	},
+	"collector.failures": func(d models.DashboardData) []sample {
+		out := make([]sample, 0, len(d.Collectors))
+		for _, c := range d.Collectors {
+			out = append(out, sample{key: c.Name, value: float64(c.ConsecutiveFailures)})
+		}
+		return out
+	},
+	"health.errors": func(d models.DashboardData) []sample {
+		counts := map[string]int{}
+		for _, h := range d.Health {
+			if h.Type == "error" {
+				counts[h.Source]++
+			}
+		}
+		out := make([]sample, 0, len(counts))
+		for src, n := range counts {
+			out = append(out, sample{key: src, value: float64(n)})
+		}
+		return out
+	},
+	"vpn.leaks": func(d models.DashboardData) []sample {
+		out := make([]sample, 0, len(d.VPNLeak.Containers))
+		for _, c := range d.VPNLeak.Containers {
+			leak := c.Leak || (c.NetworkMode != "" && !c.BehindVPN)
+			out = append(out, sample{key: c.Name, value: boolValue(leak)})
+		}
+		return out
+	},
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
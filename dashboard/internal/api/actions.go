@@ -3,284 +3,260 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
-	"strings"
+	"net/url"
 	"time"
 
 	"arcticmon/internal/config"
+	"arcticmon/internal/jobs"
 )
 
-// Actions provides API handlers for server management actions.
+// medianet is the Docker network of the media stack; only containers on
+// it are managed by the dashboard.
+const medianet = "mediaserver_medianet"
+
+// helperImage runs the nsenter helper containers of host actions.
+const helperImage = "alpine:3.19"
+
+// Actions provides API handlers for server management actions. Each action
+// runs as an asynchronous job; handlers answer 202 with the queued job.
 type Actions struct {
 	cfg    *config.Config
+	jobs   *jobs.Manager
 	docker *http.Client
+	// stream has no timeout, for pulls, waits and followed logs that are
+	// bounded by the job context instead.
+	stream *http.Client
 }
 
-func NewActions(cfg *config.Config) *Actions {
-	return &Actions{
-		cfg: cfg,
-		docker: &http.Client{
-			Timeout: 30 * time.Second,
-			Transport: &http.Transport{
-				DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
-					return net.Dial("unix", cfg.DockerSocket)
-				},
-			},
+func NewActions(cfg *config.Config, jm *jobs.Manager) *Actions {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", cfg.DockerSocket)
 		},
 	}
+	return &Actions{
+		cfg:    cfg,
+		jobs:   jm,
+		docker: &http.Client{Timeout: 30 * time.Second, Transport: transport},
+		stream: &http.Client{Transport: transport},
+	}
 }
 
-// RestartStack restarts all containers on the medianet network.
-func (a *Actions) RestartStack(w http.ResponseWriter, r *http.Request) {
-	containers, err := a.listContainers()
+// submit queues fn as a job for the requesting user and answers 202 with
+// the job and its URL. While a job holding a conflicting lease is queued
+// or running it answers 409 with that job instead.
+func (a *Actions) submit(w http.ResponseWriter, r *http.Request, action, lease string, params map[string]string, fn jobs.Func) {
+	job, err := a.jobs.Submit(action, requestUser(r), lease, params, fn)
+	var conflict *jobs.LeaseError
+	if errors.As(err, &conflict) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]any{"error": conflict.Error(), "holder": conflict.Holder})
+		return
+	}
 	if err != nil {
-		writeError(w, 500, "Failed to list containers: "+err.Error())
+		writeError(w, http.StatusServiceUnavailable, err.Error())
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// RestartStack restarts all running stack containers in dependency
+// order, skipping those protected by the actions policy.
+func (a *Actions) RestartStack(w http.ResponseWriter, r *http.Request) {
+	a.submit(w, r, "restart-stack", jobs.GlobalLease, nil, a.restartStack)
+}
 
-	var restarted []string
-	var errors []string
+func (a *Actions) restartStack(ctx context.Context, run *jobs.Run) (any, error) {
+	containers, err := a.stackContainers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list containers: %w", err)
+	}
 
+	var results []containerResult
+	failed := 0
 	for _, c := range containers {
-		name := strings.TrimPrefix(c.Names[0], "/")
-		// Don't restart ourselves or autoheal
-		if name == "arcticmon" || name == "autoheal" {
-			continue
-		}
-
-		req, _ := http.NewRequestWithContext(r.Context(), "POST",
-			fmt.Sprintf("http://docker/containers/%s/restart?t=10", c.ID), nil)
-		resp, err := a.docker.Do(req)
-		if err != nil {
-			errors = append(errors, name+": "+err.Error())
+		if c.State != "running" || !a.cfg.ContainerManaged(c.Name) {
 			continue
 		}
-		resp.Body.Close()
-		if resp.StatusCode == 204 {
-			restarted = append(restarted, name)
-		} else {
-			errors = append(errors, fmt.Sprintf("%s: status %d", name, resp.StatusCode))
+		run.Logf("restarting %s", c.Name)
+		res := a.containerOp(ctx, c, "restart")
+		results = append(results, res)
+		if res.Status == "error" {
+			failed++
+			run.Logf("%s: %s", c.Name, res.Error)
 		}
 	}
 
-	writeJSON(w, map[string]any{
-		"restarted": restarted,
-		"errors":    errors,
-	})
+	result := map[string]any{"results": results}
+	if failed > 0 {
+		return result, fmt.Errorf("%d of %d containers failed to restart", failed, len(results))
+	}
+	return result, nil
 }
 
-// RestartVM reboots the host machine via Docker exec with nsenter.
+// RestartVM reboots the host machine via a privileged nsenter container.
 func (a *Actions) RestartVM(w http.ResponseWriter, r *http.Request) {
-	// Use nsenter through a temporary container to reboot the host
-	createBody := `{
-		"Image": "alpine:3.19",
-		"Cmd": ["nsenter", "-t", "1", "-m", "-u", "-i", "-n", "--", "reboot"],
-		"HostConfig": {
-			"PidMode": "host",
-			"Privileged": true,
-			"AutoRemove": true
-		}
-	}`
-
-	req, _ := http.NewRequestWithContext(r.Context(), "POST",
-		"http://docker/containers/create?name=arcticmon-reboot",
-		strings.NewReader(createBody))
-	req.Header.Set("Content-Type", "application/json")
+	a.submit(w, r, "restart-vm", jobs.GlobalLease, nil, a.restartVM)
+}
 
-	resp, err := a.docker.Do(req)
+func (a *Actions) restartVM(ctx context.Context, run *jobs.Run) (any, error) {
+	// The host goes down with the helper, so it is auto-removed and not
+	// waited for.
+	id, err := a.startHelper(ctx, run, "arcticmon-reboot", true,
+		"nsenter", "-t", "1", "-m", "-u", "-i", "-n", "--", "reboot")
 	if err != nil {
-		writeError(w, 500, "Failed to create reboot container: "+err.Error())
-		return
+		return nil, err
 	}
-	defer resp.Body.Close()
+	run.Logf("reboot issued from helper container %.12s", id)
+	return map[string]any{"status": "rebooting"}, nil
+}
 
-	if resp.StatusCode != 201 {
-		writeError(w, 500, fmt.Sprintf("Create container: status %d", resp.StatusCode))
-		return
-	}
+// UpdateSystem runs apt update && apt full-upgrade -y on the host via
+// nsenter, capturing the helper container's output in the job log.
+func (a *Actions) UpdateSystem(w http.ResponseWriter, r *http.Request) {
+	a.submit(w, r, "update-system", jobs.GlobalLease, nil, a.updateSystem)
+}
 
-	var created struct {
-		ID string `json:"Id"`
-	}
-	json.NewDecoder(resp.Body).Decode(&created)
+func (a *Actions) updateSystem(ctx context.Context, run *jobs.Run) (any, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+	defer cancel()
 
-	// Start the container
-	startReq, _ := http.NewRequestWithContext(r.Context(), "POST",
-		fmt.Sprintf("http://docker/containers/%s/start", created.ID), nil)
-	startResp, err := a.docker.Do(startReq)
+	code, err := a.runHelper(ctx, run, "arcticmon-sysupdate",
+		"nsenter", "-t", "1", "-m", "-u", "-i", "-n", "--",
+		"sh", "-c", "apt-get update && apt-get full-upgrade -y && apt-get autoremove -y")
 	if err != nil {
-		writeError(w, 500, "Failed to start reboot container: "+err.Error())
-		return
+		return nil, err
 	}
-	startResp.Body.Close()
-
-	writeJSON(w, map[string]any{"status": "rebooting"})
+	if code != 0 {
+		return map[string]any{"status": "failed"}, fmt.Errorf("apt upgrade exited with code %d", code)
+	}
+	return map[string]any{"status": "completed", "note": "System packages updated successfully."}, nil
 }
 
-// UpdateStack pulls latest images, recreates containers, and prunes unused images.
-func (a *Actions) UpdateStack(w http.ResponseWriter, r *http.Request) {
-	containers, err := a.listContainers()
+// runHelper runs a privileged helper container to completion, streaming
+// its output into the job log, and returns its exit code. The helper is
+// removed once it has exited; if the job stops following it first (timeout,
+// shutdown or a broken wait), it is left running, since killing it could
+// interrupt dpkg mid-upgrade.
+func (a *Actions) runHelper(ctx context.Context, run *jobs.Run, name string, cmd ...string) (int, error) {
+	id, err := a.startHelper(ctx, run, name, false, cmd...)
 	if err != nil {
-		writeError(w, 500, "Failed to list containers: "+err.Error())
-		return
-	}
-
-	var pulled []string
-	var errors []string
-
-	for _, c := range containers {
-		name := strings.TrimPrefix(c.Names[0], "/")
-		image := c.Image
-
-		// Pull latest image
-		pullReq, _ := http.NewRequestWithContext(r.Context(), "POST",
-			fmt.Sprintf("http://docker/images/create?fromImage=%s", image), nil)
-		pullResp, err := a.docker.Do(pullReq)
-		if err != nil {
-			errors = append(errors, name+": pull failed: "+err.Error())
-			continue
-		}
-		// Read body to completion (Docker streams progress)
-		buf := make([]byte, 4096)
-		for {
-			_, readErr := pullResp.Body.Read(buf)
-			if readErr != nil {
-				break
-			}
-		}
-		pullResp.Body.Close()
-
-		if pullResp.StatusCode == 200 {
-			pulled = append(pulled, name)
-		} else {
-			errors = append(errors, fmt.Sprintf("%s: pull status %d", name, pullResp.StatusCode))
-		}
+		return 0, err
 	}
-
-	// Prune unused images
-	pruneReq, _ := http.NewRequestWithContext(r.Context(), "POST",
-		"http://docker/images/prune?filters={\"dangling\":[\"false\"]}", nil)
-	pruneResp, err := a.docker.Do(pruneReq)
-	pruned := ""
-	if err == nil {
-		defer pruneResp.Body.Close()
-		var pruneResult struct {
-			SpaceReclaimed uint64 `json:"SpaceReclaimed"`
-		}
-		json.NewDecoder(pruneResp.Body).Decode(&pruneResult)
-		if pruneResult.SpaceReclaimed > 0 {
-			mb := pruneResult.SpaceReclaimed / (1024 * 1024)
-			pruned = fmt.Sprintf("Pruned %d MB of unused images", mb)
-		} else {
-			pruned = "No unused images to prune"
-		}
+	code, err := a.waitHelper(ctx, run, id)
+	if err != nil {
+		run.Logf("no longer following %s, which keeps running on the host: %v", name, err)
+		return 0, err
 	}
-
-	writeJSON(w, map[string]any{
-		"pulled": pulled,
-		"errors": errors,
-		"pruned": pruned,
-		"note":   "Images pulled and pruned. Restart the stack to apply updates.",
-	})
+	a.dockerCall(context.WithoutCancel(ctx), "DELETE", "/containers/"+id+"?force=1", nil, nil)
+	run.SetExitCode(code)
+	return code, nil
 }
 
-// UpdateSystem runs apt update && apt full-upgrade -y on the host via nsenter.
-func (a *Actions) UpdateSystem(w http.ResponseWriter, r *http.Request) {
-	createBody := `{
-		"Image": "alpine:3.19",
-		"Cmd": ["nsenter", "-t", "1", "-m", "-u", "-i", "-n", "--", "sh", "-c", "apt-get update && apt-get full-upgrade -y && apt-get autoremove -y"],
-		"HostConfig": {
-			"PidMode": "host",
-			"Privileged": true,
-			"AutoRemove": true
-		}
-	}`
-
-	req, _ := http.NewRequestWithContext(r.Context(), "POST",
-		"http://docker/containers/create?name=arcticmon-sysupdate",
-		strings.NewReader(createBody))
-	req.Header.Set("Content-Type", "application/json")
+// waitHelper follows a helper's output until it exits and returns its exit
+// code.
+func (a *Actions) waitHelper(ctx context.Context, run *jobs.Run, id string) (int, error) {
+	logsDone := make(chan struct{})
+	go func() {
+		defer close(logsDone)
+		a.followLogs(ctx, run, id)
+	}()
 
-	resp, err := a.docker.Do(req)
+	req, err := http.NewRequestWithContext(ctx, "POST", "http://docker/containers/"+id+"/wait", nil)
 	if err != nil {
-		writeError(w, 500, "Failed to create update container: "+err.Error())
-		return
+		return 0, err
+	}
+	resp, err := a.stream.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("wait: %w", err)
 	}
 	defer resp.Body.Close()
-
-	if resp.StatusCode != 201 {
-		writeError(w, 500, fmt.Sprintf("Create container: status %d", resp.StatusCode))
-		return
+	var result struct {
+		StatusCode int `json:"StatusCode"`
 	}
-
-	var created struct {
-		ID string `json:"Id"`
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("wait: %w", err)
 	}
-	json.NewDecoder(resp.Body).Decode(&created)
+	<-logsDone
+	return result.StatusCode, nil
+}
 
-	// Start the container
-	startReq, _ := http.NewRequestWithContext(r.Context(), "POST",
-		fmt.Sprintf("http://docker/containers/%s/start", created.ID), nil)
-	startResp, err := a.docker.Do(startReq)
-	if err != nil {
-		writeError(w, 500, "Failed to start update container: "+err.Error())
-		return
+// startHelper creates and starts a privileged container in the host PID
+// namespace, pulling the helper image if needed. A leftover container with
+// the same name from an earlier run is removed first, unless it is still
+// running.
+func (a *Actions) startHelper(ctx context.Context, run *jobs.Run, name string, autoRemove bool, cmd ...string) (string, error) {
+	var prev struct {
+		State struct {
+			Running bool `json:"Running"`
+		} `json:"State"`
 	}
-	startResp.Body.Close()
-
-	// Wait for container to finish (longer timeout for apt upgrade)
-	waitClient := &http.Client{
-		Timeout: 10 * time.Minute,
-		Transport: a.docker.Transport,
+	if a.dockerCall(ctx, "GET", "/containers/"+url.PathEscape(name)+"/json", nil, &prev) == nil && prev.State.Running {
+		return "", fmt.Errorf("%s from an earlier run is still running on the host", name)
 	}
-	waitReq, _ := http.NewRequestWithContext(r.Context(), "POST",
-		fmt.Sprintf("http://docker/containers/%s/wait", created.ID), nil)
-	waitResp, err := waitClient.Do(waitReq)
-	if err != nil {
-		writeJSON(w, map[string]any{
-			"status": "running",
-			"note":   "System update started but timed out waiting for completion.",
-		})
-		return
+	if _, err := a.inspectImage(ctx, helperImage); err != nil {
+		run.Logf("pulling %s", helperImage)
+		if _, err := a.pullImage(ctx, helperImage); err != nil {
+			return "", fmt.Errorf("pull %s: %w", helperImage, err)
+		}
 	}
-	defer waitResp.Body.Close()
+	a.dockerCall(ctx, "DELETE", "/containers/"+url.PathEscape(name)+"?force=1", nil, nil)
 
-	var waitResult struct {
-		StatusCode int `json:"StatusCode"`
+	body := map[string]any{
+		"Image": helperImage,
+		"Cmd":   cmd,
+		"HostConfig": map[string]any{
+			"PidMode":    "host",
+			"Privileged": true,
+			"AutoRemove": autoRemove,
+		},
 	}
-	json.NewDecoder(waitResp.Body).Decode(&waitResult)
-
-	if waitResult.StatusCode == 0 {
-		writeJSON(w, map[string]any{"status": "completed", "note": "System packages updated successfully."})
-	} else {
-		writeJSON(w, map[string]any{
-			"status": "failed",
-			"note":   fmt.Sprintf("apt upgrade exited with code %d", waitResult.StatusCode),
-		})
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := a.dockerCall(ctx, "POST", "/containers/create?name="+url.QueryEscape(name), body, &created); err != nil {
+		return "", fmt.Errorf("create helper container: %w", err)
+	}
+	if err := a.dockerCall(ctx, "POST", "/containers/"+created.ID+"/start", nil, nil); err != nil {
+		a.dockerCall(ctx, "DELETE", "/containers/"+created.ID+"?force=1", nil, nil)
+		return "", fmt.Errorf("start helper container: %w", err)
 	}
+	return created.ID, nil
 }
 
-func (a *Actions) listContainers() ([]struct {
-	ID    string   `json:"Id"`
-	Names []string `json:"Names"`
-	Image string   `json:"Image"`
-}, error) {
-	req, _ := http.NewRequest("GET",
-		"http://docker/containers/json?all=false&filters={\"network\":[\"mediaserver_medianet\"]}", nil)
-	resp, err := a.docker.Do(req)
+// followLogs copies a container's output into the job log until it exits.
+func (a *Actions) followLogs(ctx context.Context, run *jobs.Run, id string) {
+	req, err := http.NewRequestWithContext(ctx, "GET",
+		"http://docker/containers/"+id+"/logs?follow=1&stdout=1&stderr=1", nil)
 	if err != nil {
-		return nil, err
+		return
+	}
+	resp, err := a.stream.Do(req)
+	if err != nil {
+		run.Logf("[logs unavailable: %v]", err)
+		return
 	}
 	defer resp.Body.Close()
-
-	var containers []struct {
-		ID    string   `json:"Id"`
-		Names []string `json:"Names"`
-		Image string   `json:"Image"`
+	err = demuxLogs(resp.Body, false, func(l LogLine) error {
+		if l.Stream == "stderr" {
+			run.Log("! " + l.Line)
+		} else {
+			run.Log(l.Line)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		run.Logf("[logs interrupted: %v]", err)
 	}
-	return containers, json.NewDecoder(resp.Body).Decode(&containers)
 }
 
 func writeError(w http.ResponseWriter, code int, msg string) {
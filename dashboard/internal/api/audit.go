@@ -0,0 +1,218 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"arcticmon/internal/audit"
+)
+
+// maxAuditErrorBody bounds how much of an error response is kept to
+// extract the message for the audit entry.
+const maxAuditErrorBody = 1024
+
+// auditMiddleware records every mutating API request (any method other
+// than GET, HEAD and OPTIONS) in the audit log, including rejected ones.
+// The action is the path below /api/, e.g. "actions/restart-stack" or
+// "services/sonarr/restart"; query parameters are kept as params. Request
+// bodies are not logged.
+func auditMiddleware(next http.Handler, al *audit.Log) http.Handler {
+	if al == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !mutating(r.Method) || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rec := &auditRecorder{ResponseWriter: w, status: http.StatusOK}
+		info := &auditInfo{}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), auditKey{}, info)))
+
+		e := audit.Entry{
+			Time:       start,
+			User:       info.user,
+			Token:      info.token,
+			IP:         clientIP(r),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Action:     strings.TrimPrefix(r.URL.Path, "/api/"),
+			Status:     rec.status,
+			Outcome:    outcomeOf(rec.status),
+			Results:    info.results,
+			DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if q := r.URL.Query(); len(q) > 0 {
+			e.Params = make(map[string]string, len(q))
+			for k, v := range q {
+				e.Params[k] = strings.Join(v, ",")
+			}
+		}
+		if loc := rec.Header().Get("Location"); strings.HasPrefix(loc, "/api/jobs/") {
+			e.JobID = strings.TrimPrefix(loc, "/api/jobs/")
+		}
+		if rec.status >= 400 {
+			e.Error = errorMessage(rec.body.Bytes())
+		}
+		if err := al.Append(e); err != nil {
+			log.Printf("[audit] %v", err)
+		}
+	})
+}
+
+func mutating(method string) bool {
+	return method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions
+}
+
+func outcomeOf(status int) string {
+	switch {
+	case status == http.StatusAccepted:
+		return "accepted"
+	case status < 400:
+		return "ok"
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return "denied"
+	case status == http.StatusTooManyRequests:
+		return "rate-limited"
+	default:
+		return "error"
+	}
+}
+
+// errorMessage extracts the message of a {"error": ...} body, falling back
+// to the raw text.
+func errorMessage(body []byte) string {
+	var v struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &v) == nil && v.Error != "" {
+		return v.Error
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// auditRecorder captures the status code and the start of error bodies.
+type auditRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *auditRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status, rec.wroteHeader = code, true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *auditRecorder) Write(p []byte) (int, error) {
+	rec.wroteHeader = true
+	if rec.status >= 400 && rec.body.Len() < maxAuditErrorBody {
+		rec.body.Write(p[:min(len(p), maxAuditErrorBody-rec.body.Len())])
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+func (rec *auditRecorder) Unwrap() http.ResponseWriter { return rec.ResponseWriter }
+
+// auditInfo lets inner handlers name the user (and API token) of an
+// audited request, including the attempted username of a rejected login,
+// and attach per-item results to its entry.
+type auditInfo struct {
+	user    string
+	token   string
+	results any
+}
+
+type auditKey struct{}
+
+func setAuditUser(r *http.Request, user string) {
+	if info, ok := r.Context().Value(auditKey{}).(*auditInfo); ok {
+		info.user = user
+	}
+}
+
+func setAuditToken(r *http.Request, user, token string) {
+	if info, ok := r.Context().Value(auditKey{}).(*auditInfo); ok {
+		info.user, info.token = user, token
+	}
+}
+
+func setAuditResults(r *http.Request, results any) {
+	if info, ok := r.Context().Value(auditKey{}).(*auditInfo); ok {
+		info.results = results
+	}
+}
+
+// clientIP returns the address of the client, as resolved from trusted
+// proxies by clientIPMiddleware, or else of the connecting peer.
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// AuditHandler serves the audit log.
+type AuditHandler struct {
+	log *audit.Log
+}
+
+// Query returns audit entries, newest first. Query: user (exact), action
+// (substring, e.g. "restart"), from/to (RFC3339 or unix seconds) and limit
+// (default 100, max 1000).
+func (a *AuditHandler) Query(w http.ResponseWriter, r *http.Request) {
+	if a.log == nil {
+		writeError(w, http.StatusServiceUnavailable, "audit log unavailable")
+		return
+	}
+	q := r.URL.Query()
+	from, err := parseTimeParam(q.Get("from"), time.Time{})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+		return
+	}
+	to, err := parseTimeParam(q.Get("to"), time.Time{})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+		return
+	}
+	limit := 100
+	if s := q.Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = min(limit, 1000)
+	}
+
+	entries, err := a.log.Query(audit.Filter{
+		User:   q.Get("user"),
+		Action: q.Get("action"),
+		From:   from,
+		To:     to,
+		Limit:  limit,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+	writeJSON(w, entries)
+}
@@ -0,0 +1,354 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"arcticmon/internal/audit"
+	"arcticmon/internal/auth"
+	"arcticmon/internal/config"
+)
+
+// sessionCookie holds the login session token.
+const sessionCookie = "arcticmon_session"
+
+// publicPaths are served without authentication: the login page, the
+// assets it needs and the sign-in endpoints.
+var publicPaths = map[string]bool{
+	"/healthz":                true,
+	"/login.html":             true,
+	"/js/login.js":            true,
+	"/css/style.css":          true,
+	"/assets/favicon.svg":     true,
+	"/api/login":              true,
+	"/api/auth/config":        true,
+	"/api/auth/oidc/login":    true,
+	"/api/auth/oidc/callback": true,
+}
+
+// authMiddleware authenticates requests and stores the identity in the
+// request context. It accepts, in order: a bearer API token (limited to
+// its scopes), identity headers from a trusted reverse proxy, a session
+// cookie (local or OIDC login) and HTTP basic credentials. While no local
+// user, OIDC provider or proxy is configured authentication is disabled and
+// every request acts as an admin. Unauthenticated API calls get 401; pages
+// redirect to the login page. Failed token and password attempts count
+// towards the sign-in throttle.
+func authMiddleware(next http.Handler, cfg *config.Config, comp *Components) http.Handler {
+	users, sessions, tokens, throttle := comp.Users, comp.Sessions, comp.Tokens, comp.Throttle
+	proxy := newProxyAuth(cfg)
+	// ownerRole returns the role a token owner holds now. Local owners
+	// must still exist; single sign-on owners only exist at sign-in, which
+	// caps their tokens, so they keep the token role while their sign-in
+	// method is configured, for at most auth.ssoTokenTtl.
+	ownerRole := func(owner string) (auth.Role, bool) {
+		switch {
+		case strings.HasPrefix(owner, auth.OIDCUserPrefix):
+			return auth.RoleAdmin, cfg.OIDCIssuer != ""
+		case strings.HasPrefix(owner, auth.ProxyUserPrefix):
+			return auth.RoleAdmin, proxy != nil
+		}
+		u, ok := users.Get(owner)
+		return u.Role, ok
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if users.Len() == 0 && cfg.OIDCIssuer == "" && proxy == nil {
+			id := auth.Identity{Role: auth.RoleAdmin, Method: "none"}
+			next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), id)))
+			return
+		}
+
+		if secret, ok := bearerToken(r); ok {
+			keys := signInKeys(r, "")
+			if throttled(w, throttle, keys) {
+				return
+			}
+			tok, ok := tokens.Authenticate(secret, clientIP(r))
+			if !ok {
+				throttle.Fail(keys...)
+				writeError(w, http.StatusUnauthorized, "invalid or expired token")
+				return
+			}
+			setAuditToken(r, tok.Owner, tok.Name)
+			role, ok := ownerRole(tok.Owner)
+			// Also bounds tokens created before the limit existed or
+			// was lowered.
+			if auth.SingleSignOn(tok.Owner) && time.Since(tok.CreatedAt) > cfg.SSOTokenTTL {
+				ok = false
+			}
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "token owner no longer has access")
+				return
+			}
+			if !tok.Allows(r.Method, r.URL.Path) {
+				writeError(w, http.StatusForbidden, "token scope does not allow this request")
+				return
+			}
+			id := auth.Identity{User: tok.Owner, Role: tok.Role.Cap(role), Method: "token", Token: tok.Name}
+			next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), id)))
+			return
+		}
+		if id, found, ok := proxy.identity(r); found {
+			setAuditUser(r, id.User)
+			// The proxy asserts the groups on every request: keep the
+			// user's tokens within the role they map to now.
+			if !ok {
+				id.Role = ""
+			}
+			if err := tokens.CapOwner(id.User, id.Role); err != nil {
+				log.Printf("[auth] cap tokens of %s: %v", id.User, err)
+			}
+			if !ok {
+				writeError(w, http.StatusForbidden, "no dashboard role for this account")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), id)))
+			return
+		}
+
+		if c, err := r.Cookie(sessionCookie); err == nil {
+			if sess, ok := sessions.Lookup(c.Value); ok {
+				setAuditUser(r, sess.User)
+				id := auth.Identity{User: sess.User, Role: sess.Role, Method: "session"}
+				next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), id)))
+				return
+			}
+		}
+		if name, pass, ok := r.BasicAuth(); ok {
+			setAuditUser(r, name)
+			keys := signInKeys(r, name)
+			if throttled(w, throttle, keys) {
+				return
+			}
+			if u, ok := users.Authenticate(name, pass); ok {
+				throttle.Succeed(keys...)
+				id := auth.Identity{User: u.Name, Role: u.Role, Method: "basic"}
+				next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), id)))
+				return
+			}
+			throttle.Fail(keys...)
+		}
+
+		switch {
+		case r.URL.Path == "/metrics":
+			w.Header().Set("WWW-Authenticate", `Basic realm="Arctic Monitor"`)
+			writeError(w, http.StatusUnauthorized, "authentication required")
+		case strings.HasPrefix(r.URL.Path, "/api/"):
+			writeError(w, http.StatusUnauthorized, "authentication required")
+		default:
+			http.Redirect(w, r, "/login.html?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+		}
+	})
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// requireRole restricts a route to identities with at least role.
+func requireRole(role auth.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := auth.FromContext(r.Context())
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "authentication required")
+			return
+		}
+		if !id.Role.Allows(role) {
+			writeError(w, http.StatusForbidden, "requires the "+string(role)+" role")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requestUser returns the user a request is made on behalf of.
+func requestUser(r *http.Request) string {
+	if id, ok := auth.FromContext(r.Context()); ok {
+		return id.User
+	}
+	return ""
+}
+
+// AuthHandler serves login, logout and user management.
+type AuthHandler struct {
+	cfg      *config.Config
+	users    *auth.Users
+	sessions *auth.Sessions
+	tokens   *auth.Tokens
+	throttle *auth.Throttle
+	oidc     *auth.OIDC // nil unless single sign-on is configured
+	audit    *audit.Log
+}
+
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// decodeCredentials reads a JSON body or, for the login form, form fields.
+func decodeCredentials(r *http.Request) (credentials, error) {
+	var c credentials
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 64<<10)).Decode(&c)
+		return c, err
+	}
+	if err := r.ParseForm(); err != nil {
+		return c, err
+	}
+	c.Username, c.Password, c.Role = r.PostForm.Get("username"), r.PostForm.Get("password"), r.PostForm.Get("role")
+	return c, nil
+}
+
+// Login checks the credentials and starts a session cookie.
+func (a *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if a.users.Len() == 0 {
+		writeError(w, http.StatusBadRequest, "no local users are configured")
+		return
+	}
+	c, err := decodeCredentials(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	setAuditUser(r, c.Username)
+	keys := signInKeys(r, c.Username)
+	if throttled(w, a.throttle, keys) {
+		return
+	}
+	u, ok := a.users.Authenticate(c.Username, c.Password)
+	if !ok {
+		a.throttle.Fail(keys...)
+		writeError(w, http.StatusUnauthorized, "invalid username or password")
+		return
+	}
+	a.throttle.Succeed(keys...)
+
+	a.startSession(w, r, u.Name, u.Role)
+	writeJSON(w, auth.Identity{User: u.Name, Role: u.Role, Method: "session"})
+}
+
+// Logout ends the current session.
+func (a *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		a.sessions.Delete(c.Value)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Me returns the identity of the caller.
+func (a *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	id, _ := auth.FromContext(r.Context())
+	writeJSON(w, id)
+}
+
+// ListUsers returns all users without password hashes.
+func (a *AuthHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, a.users.List())
+}
+
+// CreateUser adds a user from {"username", "password", "role"}.
+func (a *AuthHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	c, err := decodeCredentials(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	role, err := auth.ParseRole(c.Role)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	u, err := a.users.Create(c.Username, c.Password, role)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(u)
+}
+
+// UpdateUser changes the password and/or role of user {name}, ends its
+// sessions and lowers its API tokens to the new role.
+func (a *AuthHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	c, err := decodeCredentials(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	var role auth.Role
+	if c.Role != "" {
+		if role, err = auth.ParseRole(c.Role); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	u, err := a.users.Update(name, c.Password, role)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	a.sessions.DeleteUser(name)
+	if err := a.tokens.CapOwner(name, u.Role); err != nil {
+		log.Printf("[auth] cap tokens of %s: %v", name, err)
+	}
+	writeJSON(w, u)
+}
+
+// DeleteUser removes user {name}, ends its sessions and revokes its API
+// tokens.
+func (a *AuthHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := a.users.Delete(name); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	a.sessions.DeleteUser(name)
+	if err := a.tokens.RevokeOwner(name); err != nil {
+		log.Printf("[auth] revoke tokens of %s: %v", name, err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrUnknownUser):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, auth.ErrLastAdmin):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+// secureRequest reports whether the client reached us over HTTPS, directly
+// or through the reverse proxy.
+func secureRequest(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,373 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"arcticmon/internal/jobs"
+)
+
+// stackContainer is a container of the media stack with its dependencies.
+type stackContainer struct {
+	ID      string
+	Name    string
+	Image   string
+	State   string
+	Service string
+
+	// NetworkOf is the container whose network namespace this one joins
+	// (network_mode: service:x), empty otherwise.
+	NetworkOf string
+	// DependsOn lists container names from network_mode and compose
+	// depends_on.
+	DependsOn []string
+}
+
+// containerResult is the outcome of one Docker operation on a container.
+type containerResult struct {
+	Name       string  `json:"name"`
+	Action     string  `json:"action"`
+	Status     string  `json:"status"` // ok, unchanged, error or skipped
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+// stackContainers lists the containers on the medianet network plus those
+// sharing the network namespace of one of them (qbittorrent and
+// flaresolverr run behind gluetun and have no network of their own).
+func (a *Actions) stackContainers(ctx context.Context) ([]stackContainer, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://docker/containers/json?all=true", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.docker.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("list containers: status %d", resp.StatusCode)
+	}
+
+	var raw []struct {
+		ID         string            `json:"Id"`
+		Names      []string          `json:"Names"`
+		Image      string            `json:"Image"`
+		State      string            `json:"State"`
+		Labels     map[string]string `json:"Labels"`
+		HostConfig struct {
+			NetworkMode string `json:"NetworkMode"`
+		} `json:"HostConfig"`
+		NetworkSettings struct {
+			Networks map[string]json.RawMessage `json:"Networks"`
+		} `json:"NetworkSettings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	byID := map[string]string{}
+	byService := map[string]string{}
+	for _, c := range raw {
+		if len(c.Names) == 0 {
+			continue
+		}
+		name := strings.TrimPrefix(c.Names[0], "/")
+		byID[c.ID] = name
+		if svc := c.Labels["com.docker.compose.service"]; svc != "" {
+			byService[svc] = name
+		}
+	}
+	resolve := func(ref string) string {
+		if name, ok := byID[ref]; ok {
+			return name
+		}
+		return ref
+	}
+
+	onNet := map[string]bool{}
+	for _, c := range raw {
+		if _, ok := c.NetworkSettings.Networks[medianet]; ok {
+			onNet[byID[c.ID]] = true
+		}
+	}
+
+	var out []stackContainer
+	for _, c := range raw {
+		if len(c.Names) == 0 {
+			continue
+		}
+		sc := stackContainer{
+			ID:      c.ID,
+			Name:    byID[c.ID],
+			Image:   c.Image,
+			State:   c.State,
+			Service: c.Labels["com.docker.compose.service"],
+		}
+		if ref, ok := strings.CutPrefix(c.HostConfig.NetworkMode, "container:"); ok {
+			sc.NetworkOf = resolve(ref)
+			sc.DependsOn = append(sc.DependsOn, sc.NetworkOf)
+		}
+		if !onNet[sc.Name] && !onNet[sc.NetworkOf] {
+			continue
+		}
+		// "gluetun:service_healthy:false,prowlarr:service_started:false"
+		for _, dep := range splitComma(c.Labels["com.docker.compose.depends_on"]) {
+			svc, _, _ := strings.Cut(dep, ":")
+			if name, ok := byService[svc]; ok && name != sc.NetworkOf {
+				sc.DependsOn = append(sc.DependsOn, name)
+			}
+		}
+		out = append(out, sc)
+	}
+	return dependencyOrder(out), nil
+}
+
+// dependencyOrder sorts containers so that dependencies come before their
+// dependents, alphabetically within a level. Cycles are appended as-is.
+func dependencyOrder(cs []stackContainer) []stackContainer {
+	sort.Slice(cs, func(i, j int) bool { return cs[i].Name < cs[j].Name })
+	index := map[string]bool{}
+	for _, c := range cs {
+		index[c.Name] = true
+	}
+
+	done := map[string]bool{}
+	var out []stackContainer
+	for len(out) < len(cs) {
+		progressed := false
+		for _, c := range cs {
+			if done[c.Name] {
+				continue
+			}
+			ready := true
+			for _, dep := range c.DependsOn {
+				if index[dep] && !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				done[c.Name] = true
+				out = append(out, c)
+				progressed = true
+			}
+		}
+		if !progressed {
+			for _, c := range cs {
+				if !done[c.Name] {
+					done[c.Name] = true
+					out = append(out, c)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// containerOp runs start, stop or restart on a container. A 304 from
+// Docker (already in the requested state) is reported as unchanged.
+func (a *Actions) containerOp(ctx context.Context, c stackContainer, op string) containerResult {
+	res := containerResult{Name: c.Name, Action: op}
+	if !a.cfg.ContainerManaged(c.Name) {
+		res.Status = "skipped"
+		res.Error = "protected by actions policy"
+		return res
+	}
+
+	start := time.Now()
+	u := fmt.Sprintf("http://docker/containers/%s/%s", c.ID, op)
+	if op != "start" {
+		u += "?t=10"
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", u, nil)
+	if err == nil {
+		var resp *http.Response
+		if resp, err = a.docker.Do(req); err == nil {
+			resp.Body.Close()
+			switch resp.StatusCode {
+			case http.StatusNoContent:
+				res.Status = "ok"
+			case http.StatusNotModified:
+				res.Status = "unchanged"
+			default:
+				err = fmt.Errorf("status %d", resp.StatusCode)
+			}
+		}
+	}
+	res.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		res.Status = "error"
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// ContainerAction starts, stops or restarts container {name}. Related
+// containers are handled in dependency order: start brings up stopped
+// dependencies first, stop takes down containers sharing its network
+// namespace first, and restart restarts those afterwards (they lose
+// connectivity when gluetun restarts).
+func (a *Actions) ContainerAction(w http.ResponseWriter, r *http.Request) {
+	name, action := r.PathValue("name"), r.PathValue("action")
+	if action != "start" && action != "stop" && action != "restart" {
+		writeError(w, http.StatusNotFound, "unknown action: "+action)
+		return
+	}
+	if !a.cfg.ContainerManaged(name) {
+		writeError(w, http.StatusForbidden, name+" is protected by the actions policy")
+		return
+	}
+
+	// Lease every container the plan may touch so that an action on a
+	// container sharing gluetun's network cannot run during one on gluetun.
+	containers, err := a.stackContainers(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "list containers: "+err.Error())
+		return
+	}
+	if !slices.ContainsFunc(containers, func(c stackContainer) bool { return c.Name == name }) {
+		writeError(w, http.StatusNotFound, "unknown service: "+name)
+		return
+	}
+	lease := containerLease(containers, name)
+
+	a.submit(w, r, action+"-service", lease, map[string]string{"service": name}, func(ctx context.Context, run *jobs.Run) (any, error) {
+		return a.containerAction(ctx, run, name, action)
+	})
+}
+
+func (a *Actions) containerAction(ctx context.Context, run *jobs.Run, name, action string) (any, error) {
+	containers, err := a.stackContainers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list containers: %w", err)
+	}
+	byName := map[string]stackContainer{}
+	for _, c := range containers {
+		byName[c.Name] = c
+	}
+	target, ok := byName[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown service: %s", name)
+	}
+
+	type step struct {
+		c  stackContainer
+		op string
+	}
+	var plan []step
+	switch action {
+	case "start":
+		for _, c := range containers {
+			if c.Name != name && c.State != "running" && dependsOn(byName, target, c.Name) {
+				plan = append(plan, step{c, "start"})
+			}
+		}
+		plan = append(plan, step{target, "start"})
+	case "stop":
+		for i := len(containers) - 1; i >= 0; i-- {
+			c := containers[i]
+			if c.State == "running" && sharesNetwork(byName, c, name) {
+				plan = append(plan, step{c, "stop"})
+			}
+		}
+		plan = append(plan, step{target, "stop"})
+	case "restart":
+		plan = append(plan, step{target, "restart"})
+		for _, c := range containers {
+			if c.State == "running" && sharesNetwork(byName, c, name) {
+				plan = append(plan, step{c, "restart"})
+			}
+		}
+	}
+
+	results := make([]containerResult, 0, len(plan))
+	var failure error
+	for _, s := range plan {
+		if failure != nil {
+			results = append(results, containerResult{Name: s.c.Name, Action: s.op, Status: "skipped", Error: "aborted after a previous failure"})
+			continue
+		}
+		run.Logf("%s %s", s.op, s.c.Name)
+		res := a.containerOp(ctx, s.c, s.op)
+		// Protected dependents are skipped, but a failed dependency start
+		// means the target cannot come up either.
+		if res.Status == "error" {
+			failure = fmt.Errorf("%s %s: %s", s.op, s.c.Name, res.Error)
+			run.Log(failure.Error())
+		}
+		results = append(results, res)
+	}
+
+	return map[string]any{"service": name, "action": action, "results": results}, failure
+}
+
+// containerLease returns the lease of an action on the named container: it
+// covers the containers it depends on, which start may bring up, and those
+// sharing its network namespace, which stop and restart take along,
+// whatever their current state.
+func containerLease(containers []stackContainer, name string) string {
+	byName := map[string]stackContainer{}
+	for _, c := range containers {
+		byName[c.Name] = c
+	}
+	names := []string{"container:" + name}
+	if target, ok := byName[name]; ok {
+		for _, c := range containers {
+			if c.Name != name && (dependsOn(byName, target, c.Name) || sharesNetwork(byName, c, name)) {
+				names = append(names, "container:"+c.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// dependsOn reports whether c transitively depends on the named container.
+func dependsOn(byName map[string]stackContainer, c stackContainer, name string) bool {
+	seen := map[string]bool{}
+	var walk func(stackContainer) bool
+	walk = func(c stackContainer) bool {
+		for _, dep := range c.DependsOn {
+			if dep == name {
+				return true
+			}
+			if d, ok := byName[dep]; ok && !seen[dep] {
+				seen[dep] = true
+				if walk(d) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return walk(c)
+}
+
+// sharesNetwork reports whether c (transitively) runs in the network
+// namespace of the named container.
+func sharesNetwork(byName map[string]stackContainer, c stackContainer, name string) bool {
+	for i := 0; c.NetworkOf != "" && i < len(byName); i++ {
+		if c.NetworkOf == name {
+			return true
+		}
+		c = byName[c.NetworkOf]
+	}
+	return false
+}
+
+func splitComma(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"slices"
+	"testing"
+)
+
+// testStack mirrors the media stack: qbittorrent and flaresolverr share
+// gluetun's network namespace.
+func testStack() []stackContainer {
+	return []stackContainer{
+		{Name: "sonarr", DependsOn: []string{"prowlarr", "qbittorrent"}},
+		{Name: "qbittorrent", NetworkOf: "gluetun", DependsOn: []string{"gluetun"}},
+		{Name: "prowlarr", DependsOn: []string{"flaresolverr"}},
+		{Name: "jellyfin"},
+		{Name: "gluetun"},
+		{Name: "flaresolverr", NetworkOf: "gluetun", DependsOn: []string{"gluetun", "redis"}},
+	}
+}
+
+func names(cs []stackContainer) []string {
+	var out []string
+	for _, c := range cs {
+		out = append(out, c.Name)
+	}
+	return out
+}
+
+func TestDependencyOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []stackContainer
+		want []string
+	}{
+		{
+			name: "stack",
+			in:   testStack(),
+			// redis is not in the stack, so it does not hold back
+			// flaresolverr.
+			want: []string{"gluetun", "jellyfin", "qbittorrent", "flaresolverr", "prowlarr", "sonarr"},
+		},
+		{
+			name: "cycle",
+			in: []stackContainer{
+				{Name: "b", DependsOn: []string{"a"}},
+				{Name: "a", DependsOn: []string{"b"}},
+				{Name: "c", DependsOn: []string{"a"}},
+				{Name: "d"},
+			},
+			want: []string{"d", "a", "b", "c"},
+		},
+		{name: "empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(dependencyOrder(tt.in)); !slices.Equal(got, tt.want) {
+				t.Errorf("dependencyOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerLease(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{
+			name: "gluetun",
+			want: "container:flaresolverr,container:gluetun,container:qbittorrent",
+		},
+		{
+			name: "qbittorrent",
+			want: "container:gluetun,container:qbittorrent",
+		},
+		{
+			name: "sonarr",
+			want: "container:flaresolverr,container:gluetun,container:prowlarr,container:qbittorrent,container:sonarr",
+		},
+		{name: "jellyfin", want: "container:jellyfin"},
+		{name: "unknown", want: "container:unknown"},
+	}
+	for _, tt := range tests {
+		if got := containerLease(testStack(), tt.name); got != tt.want {
+			t.Errorf("containerLease(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"arcticmon/internal/config"
+)
+
+// confirmTTL is how long a confirmation nonce for a destructive action
+// stays valid.
+const confirmTTL = time.Minute
+
+// csrfMiddleware rejects state-changing requests sent by another site.
+// Browsers resend session cookies and Basic credentials cross-site, so a
+// POST is only accepted when Sec-Fetch-Site says it came from this origin
+// or, for browsers without fetch metadata, when the Origin header matches
+// the dashboard's host or a trusted origin. Requests with neither header
+// (curl, scripts) and bearer-token requests, whose credentials a browser
+// never adds on its own, are let through.
+func csrfMiddleware(next http.Handler, cfg *config.Config) http.Handler {
+	trusted := make(map[string]bool, len(cfg.TrustedOrigins))
+	for _, o := range cfg.TrustedOrigins {
+		trusted[strings.ToLower(strings.TrimRight(o, "/"))] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, bearer := bearerToken(r); !mutating(r.Method) || bearer {
+			next.ServeHTTP(w, r)
+			return
+		}
+		origin := r.Header.Get("Origin")
+		sameOrigin := func() bool {
+			if origin == "" {
+				return false
+			}
+			if trusted[strings.ToLower(origin)] {
+				return true
+			}
+			u, err := url.Parse(origin)
+			return err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host)
+		}
+
+		ok := true
+		switch r.Header.Get("Sec-Fetch-Site") {
+		case "same-origin", "none":
+		case "":
+			ok = origin == "" || sameOrigin()
+		default: // same-site, cross-site
+			ok = sameOrigin()
+		}
+		if !ok {
+			writeError(w, http.StatusForbidden, "cross-site request rejected")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Confirmations holds the single-use nonces that destructive actions
+// require: the client first asks for a nonce, then repeats the action
+// with it in the X-Confirm-Nonce header (or ?confirm=) within confirmTTL.
+type Confirmations struct {
+	mu      sync.Mutex
+	pending map[string]confirmation
+}
+
+type confirmation struct {
+	user    string
+	action  string
+	expires time.Time
+}
+
+// NewConfirmations creates an empty nonce store.
+func NewConfirmations() *Confirmations {
+	return &Confirmations{pending: make(map[string]confirmation)}
+}
+
+// issue creates a nonce for user to run action.
+func (c *Confirmations) issue(user, action string) (string, time.Time) {
+	b := make([]byte, 16)
+	rand.Read(b)
+	nonce := hex.EncodeToString(b)
+	expires := time.Now().Add(confirmTTL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, p := range c.pending {
+		if now.After(p.expires) {
+			delete(c.pending, k)
+		}
+	}
+	c.pending[nonce] = confirmation{user: user, action: action, expires: expires}
+	return nonce, expires
+}
+
+// redeem consumes a nonce; it is valid only for the user and action it
+// was issued for, and only once. A valid nonce is returned so that it can
+// be given back if the action is not accepted.
+func (c *Confirmations) redeem(nonce, user, action string) (confirmation, bool) {
+	if nonce == "" {
+		return confirmation{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pending[nonce]
+	if !ok {
+		return confirmation{}, false
+	}
+	delete(c.pending, nonce)
+	return p, time.Now().Before(p.expires) &&
+		subtle.ConstantTimeCompare([]byte(p.user), []byte(user)) == 1 &&
+		p.action == action
+}
+
+// restore puts back a redeemed nonce whose action was refused.
+func (c *Confirmations) restore(nonce string, p confirmation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending[nonce] = p
+}
+
+// Confirm issues a confirmation nonce for action.
+func (c *Confirmations) Confirm(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nonce, expires := c.issue(requestUser(r), action)
+		writeJSON(w, map[string]any{"action": action, "nonce": nonce, "expiresAt": expires})
+	}
+}
+
+// require runs next only with a valid confirmation nonce for action. The
+// nonce is used up only if next accepts the action with 202; after a
+// refusal such as a lease conflict the client may retry with it.
+func (c *Confirmations) require(action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nonce := r.Header.Get("X-Confirm-Nonce")
+		if nonce == "" {
+			nonce = r.URL.Query().Get("confirm")
+		}
+		p, ok := c.redeem(nonce, requestUser(r), action)
+		if !ok {
+			writeError(w, http.StatusPreconditionRequired,
+				"confirmation required: POST /api/actions/"+action+"/confirm and retry with X-Confirm-Nonce")
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		next(rec, r)
+		if rec.status != http.StatusAccepted {
+			c.restore(nonce, p)
+		}
+	}
+}
+
+// statusRecorder captures the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(p)
+}
@@ -2,15 +2,21 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
+	"arcticmon/internal/collector"
+	"arcticmon/internal/history"
 	"arcticmon/internal/store"
 )
 
 // Handlers provides HTTP handlers for the JSON API.
 type Handlers struct {
-	store *store.Store
+	store   *store.Store
+	history *history.DB
+	orch    *collector.Orchestrator
 }
 
 func (h *Handlers) respondJSON(w http.ResponseWriter, data any) {
@@ -64,3 +70,140 @@ func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) SSHSecurity(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, h.store.Get().SSHSecurity)
 }
+
+func (h *Handlers) AuthSecurity(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, h.store.Get().AuthSecurity)
+}
+
+func (h *Handlers) Alerts(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, h.store.Get().Alerts)
+}
+
+func (h *Handlers) Collectors(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, h.store.Get().Collectors)
+}
+
+// RefreshCollector triggers an immediate collection for {name}.
+func (h *Handlers) RefreshCollector(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := h.orch.Refresh(name); err != nil {
+		if errors.Is(err, collector.ErrUnknownCollector) {
+			writeError(w, http.StatusNotFound, name+": "+err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"collector": name, "status": "queued"})
+}
+
+// HostHistory serves downsampled host metrics from the time-series store.
+// Query: metric (HostMetrics JSON field, e.g. cpuPercent), optional mount
+// or core for per-disk/per-core series, from/to (RFC3339 or unix seconds,
+// default last hour) and step (Go duration or seconds).
+func (h *Handlers) HostHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	metric := q.Get("metric")
+	if metric == "" {
+		writeError(w, http.StatusBadRequest, "metric is required")
+		return
+	}
+	series := "host." + metric
+	if mount := q.Get("mount"); mount != "" {
+		series += ":" + mount
+	} else if core := q.Get("core"); core != "" {
+		series += ":" + core
+	}
+	h.serveHistory(w, r, series, metric)
+}
+
+// ServiceHistory serves downsampled stats of container {name}. Query:
+// metric (ContainerStats JSON field: cpuPercent, memUsage, memPercent,
+// netRxRate, netTxRate, blockReadRate or blockWriteRate), from/to, step.
+func (h *Handlers) ServiceHistory(w http.ResponseWriter, r *http.Request) {
+	metric := r.URL.Query().Get("metric")
+	if metric == "" {
+		writeError(w, http.StatusBadRequest, "metric is required")
+		return
+	}
+	h.serveHistory(w, r, "service."+metric+":"+r.PathValue("name"), metric)
+}
+
+// serveHistory answers a range query on series using the from, to and
+// step query parameters.
+func (h *Handlers) serveHistory(w http.ResponseWriter, r *http.Request, series, metric string) {
+	q := r.URL.Query()
+	now := time.Now()
+	from, err := parseTimeParam(q.Get("from"), now.Add(-time.Hour))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+		return
+	}
+	to, err := parseTimeParam(q.Get("to"), now)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+		return
+	}
+	step, err := parseDurationParam(q.Get("step"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid step: "+err.Error())
+		return
+	}
+	if !to.After(from) {
+		writeError(w, http.StatusBadRequest, "to must be after from")
+		return
+	}
+
+	points, step := h.history.Query(series, from, to, step)
+	h.respondJSON(w, map[string]any{
+		"metric": metric,
+		"from":   from,
+		"to":     to,
+		"step":   step.Seconds(),
+		"points": points,
+	})
+}
+
+// parseTimeParam accepts RFC3339 or unix seconds; empty returns fallback.
+func parseTimeParam(s string, fallback time.Time) (time.Time, error) {
+	if s == "" {
+		return fallback, nil
+	}
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// parseDurationParam accepts a Go duration ("5m") or plain seconds.
+func parseDurationParam(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if secs, err := strconv.Atoi(s); err == nil {
+		return time.Duration(secs) * time.Second, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// ServiceEvents serves the Docker event timeline of container {name}.
+func (h *Handlers) ServiceEvents(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	tl, ok := h.store.ServiceEvents(name)
+	if !ok && !h.hasService(name) {
+		writeError(w, http.StatusNotFound, "unknown service: "+name)
+		return
+	}
+	h.respondJSON(w, tl)
+}
+
+func (h *Handlers) hasService(name string) bool {
+	for _, svc := range h.store.Get().Services {
+		if svc.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"arcticmon/internal/jobs"
+)
+
+// JobsHandler exposes the state of asynchronous action jobs.
+type JobsHandler struct {
+	jobs *jobs.Manager
+}
+
+// List returns recent jobs, newest first, without their logs.
+func (j *JobsHandler) List(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, j.jobs.List())
+}
+
+// Get returns job {id} with its captured output.
+func (j *JobsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	job, ok := j.jobs.Get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown job")
+		return
+	}
+	writeJSON(w, job)
+}
+
+// Events streams job {id} as Server-Sent Events: the full job first, then
+// "log" events per output line and "job" events on state changes. The
+// stream ends when the job finishes.
+func (j *JobsHandler) Events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	id := r.PathValue("id")
+	// The snapshot and the channel are taken together, so no line is lost
+	// or sent twice.
+	job, ch, stop, ok := j.jobs.Watch(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown job")
+		return
+	}
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	send := func(ev jobs.Event) {
+		msg, err := json.Marshal(ev)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(w, "data: %s\n\n", msg)
+		flusher.Flush()
+	}
+	send(jobs.Event{Event: "job", Data: job})
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			send(ev)
+		}
+	}
+}
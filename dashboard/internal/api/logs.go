@@ -0,0 +1,423 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"arcticmon/internal/config"
+)
+
+const (
+	defaultLogTail = 500
+	maxLogTail     = 5000
+	// maxLogStreams bounds concurrent followers; each holds a Docker
+	// connection open.
+	maxLogStreams = 10
+	// maxLogFrame guards against corrupt multiplexed frame headers.
+	maxLogFrame = 16 << 20
+)
+
+// errNotMedianet is returned for containers outside the stack network.
+var errNotMedianet = errors.New("container is not on the " + medianet + " network")
+
+// errStreamClosed stops reading the logs of a closed follow stream.
+var errStreamClosed = errors.New("stream closed")
+
+// LogsHandler serves container logs from the Docker socket.
+type LogsHandler struct {
+	docker    *http.Client
+	streams   chan struct{}
+	heartbeat time.Duration
+}
+
+// NewLogStreams creates the semaphore bounding concurrent log followers.
+// It outlives the router so a reload does not reset the count.
+func NewLogStreams() chan struct{} {
+	return make(chan struct{}, maxLogStreams)
+}
+
+func NewLogsHandler(cfg *config.Config, streams chan struct{}) *LogsHandler {
+	return &LogsHandler{
+		docker: &http.Client{
+			// No timeout: followed streams stay open; requests are bounded
+			// by their context instead.
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", cfg.DockerSocket)
+				},
+			},
+		},
+		streams:   streams,
+		heartbeat: cfg.SSEHeartbeat,
+	}
+}
+
+// LogLine is a single demultiplexed log line.
+type LogLine struct {
+	Time   time.Time `json:"time"`
+	Stream string    `json:"stream"`
+	Line   string    `json:"line"`
+}
+
+// logQuery holds the parsed query parameters shared by both endpoints.
+type logQuery struct {
+	tail   int
+	since  time.Time
+	stream string
+	match  func(string) bool
+}
+
+// Logs returns the last lines of container {name}. Query: tail (default
+// 500, max 5000), since (RFC3339, unix seconds or a duration like 15m),
+// stream (stdout or stderr), filter (substring) and regex. Filters apply
+// after tail, so fewer lines than requested may be returned.
+func (l *LogsHandler) Logs(w http.ResponseWriter, r *http.Request) {
+	q, err := parseLogQuery(r, defaultLogTail)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	name := r.PathValue("name")
+	body, tty, status, err := l.open(ctx, name, q, false)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	defer body.Close()
+
+	lines := []LogLine{}
+	err = demuxLogs(body, tty, func(line LogLine) error {
+		if q.accept(line) {
+			lines = append(lines, line)
+		}
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		writeError(w, http.StatusBadGateway, "read logs: "+err.Error())
+		return
+	}
+	writeJSON(w, map[string]any{"name": name, "lines": lines})
+}
+
+// Stream follows the logs of container {name} as Server-Sent Events, one
+// JSON LogLine per event. Accepts the same query as Logs (tail defaults
+// to 100).
+func (l *LogsHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	q, err := parseLogQuery(r, 100)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	select {
+	case l.streams <- struct{}{}:
+		defer func() { <-l.streams }()
+	default:
+		writeError(w, http.StatusServiceUnavailable, "too many log streams")
+		return
+	}
+
+	body, tty, status, err := l.open(r.Context(), r.PathValue("name"), q, true)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprintf(w, ": connected\n\n")
+	flusher.Flush()
+
+	// Lines are read in the background so that heartbeats keep proxies
+	// from closing a quiet stream.
+	lines := make(chan LogLine)
+	done := make(chan struct{})
+	go func() {
+		defer close(lines)
+		demuxLogs(body, tty, func(line LogLine) error {
+			if !q.accept(line) {
+				return nil
+			}
+			select {
+			case lines <- line:
+				return nil
+			case <-done:
+				return errStreamClosed
+			}
+		})
+	}()
+	defer close(done)
+
+	heartbeat := time.NewTicker(l.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprintf(w, ": ping\n\n")
+			flusher.Flush()
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			msg, err := json.Marshal(line)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", msg); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// logsContainer is the part of a container inspect open needs.
+type logsContainer struct {
+	ID     string `json:"Id"`
+	Config struct {
+		Tty bool `json:"Tty"`
+	} `json:"Config"`
+	HostConfig struct {
+		NetworkMode string `json:"NetworkMode"`
+	} `json:"HostConfig"`
+	NetworkSettings struct {
+		Networks map[string]json.RawMessage `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// open inspects the container, checks it belongs to the stack and starts
+// the Docker logs request. It returns the body, whether the container uses
+// a TTY (raw, non-multiplexed output) and an HTTP status for errors.
+func (l *LogsHandler) open(ctx context.Context, name string, q logQuery, follow bool) (io.ReadCloser, bool, int, error) {
+	info, status, err := l.inspect(ctx, name)
+	if err != nil {
+		return nil, false, status, err
+	}
+	// Like stackContainers, a container sharing the network namespace of
+	// one on medianet (qbittorrent behind gluetun) belongs to the stack.
+	if _, ok := info.NetworkSettings.Networks[medianet]; !ok {
+		ref, shared := strings.CutPrefix(info.HostConfig.NetworkMode, "container:")
+		if !shared {
+			return nil, false, http.StatusForbidden, errNotMedianet
+		}
+		parent, status, err := l.inspect(ctx, ref)
+		if status == http.StatusNotFound {
+			return nil, false, http.StatusForbidden, errNotMedianet
+		}
+		if err != nil {
+			return nil, false, status, err
+		}
+		if _, ok := parent.NetworkSettings.Networks[medianet]; !ok {
+			return nil, false, http.StatusForbidden, errNotMedianet
+		}
+	}
+
+	params := url.Values{
+		"stdout":     {"1"},
+		"stderr":     {"1"},
+		"timestamps": {"1"},
+		"tail":       {strconv.Itoa(q.tail)},
+	}
+	if !q.since.IsZero() {
+		params.Set("since", strconv.FormatInt(q.since.Unix(), 10))
+	}
+	if follow {
+		params.Set("follow", "1")
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET",
+		"http://docker/containers/"+info.ID+"/logs?"+params.Encode(), nil)
+	if err != nil {
+		return nil, false, http.StatusInternalServerError, err
+	}
+	logs, err := l.docker.Do(req)
+	if err != nil {
+		return nil, false, http.StatusBadGateway, fmt.Errorf("docker socket: %w", err)
+	}
+	if logs.StatusCode != http.StatusOK {
+		logs.Body.Close()
+		return nil, false, http.StatusBadGateway, fmt.Errorf("logs %s: status %d", name, logs.StatusCode)
+	}
+	return logs.Body, info.Config.Tty, 0, nil
+}
+
+// inspect returns the container's inspect data, or an error with an HTTP
+// status.
+func (l *LogsHandler) inspect(ctx context.Context, name string) (*logsContainer, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET",
+		"http://docker/containers/"+url.PathEscape(name)+"/json", nil)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	resp, err := l.docker.Do(req)
+	if err != nil {
+		return nil, http.StatusBadGateway, fmt.Errorf("docker socket: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, http.StatusNotFound, fmt.Errorf("unknown container: %s", name)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, http.StatusBadGateway, fmt.Errorf("inspect %s: status %d", name, resp.StatusCode)
+	}
+	var info logsContainer
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	return &info, 0, nil
+}
+
+func parseLogQuery(r *http.Request, tail int) (logQuery, error) {
+	v := r.URL.Query()
+	q := logQuery{tail: tail, stream: v.Get("stream")}
+
+	if s := v.Get("tail"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return q, fmt.Errorf("invalid tail: %q", s)
+		}
+		q.tail = min(n, maxLogTail)
+	}
+	if s := v.Get("since"); s != "" {
+		if d, err := time.ParseDuration(s); err == nil {
+			q.since = time.Now().Add(-d)
+		} else if t, err := parseTimeParam(s, time.Time{}); err == nil {
+			q.since = t
+		} else {
+			return q, fmt.Errorf("invalid since: %q", s)
+		}
+	}
+	switch q.stream {
+	case "", "stdout", "stderr":
+	default:
+		return q, fmt.Errorf("invalid stream: %q", q.stream)
+	}
+
+	filter := v.Get("filter")
+	var re *regexp.Regexp
+	if s := v.Get("regex"); s != "" {
+		var err error
+		if re, err = regexp.Compile(s); err != nil {
+			return q, fmt.Errorf("invalid regex: %w", err)
+		}
+	}
+	q.match = func(line string) bool {
+		if filter != "" && !strings.Contains(line, filter) {
+			return false
+		}
+		return re == nil || re.MatchString(line)
+	}
+	return q, nil
+}
+
+func (q logQuery) accept(l LogLine) bool {
+	if q.stream != "" && l.Stream != q.stream {
+		return false
+	}
+	return q.match(l.Line)
+}
+
+// demuxLogs splits a Docker logs body into lines. Without a TTY the body
+// is multiplexed into frames with an 8-byte header (stream type, three
+// zero bytes, big-endian payload size); lines can span frames so partial
+// lines are buffered per stream. With a TTY the body is raw stdout.
+func demuxLogs(r io.Reader, tty bool, fn func(LogLine) error) error {
+	if tty {
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadBytes('\n')
+			if len(line) > 0 {
+				if ferr := fn(parseLogLine("stdout", line)); ferr != nil {
+					return ferr
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var header [8]byte
+	partial := map[string][]byte{}
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			for stream, rest := range partial {
+				if len(rest) > 0 {
+					fn(parseLogLine(stream, rest))
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		stream := "stdout"
+		if header[0] == 2 {
+			stream = "stderr"
+		}
+		size := binary.BigEndian.Uint32(header[4:])
+		if size > maxLogFrame {
+			return fmt.Errorf("log frame too large (%d bytes)", size)
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return err
+		}
+
+		buf := append(partial[stream], payload...)
+		for {
+			i := bytes.IndexByte(buf, '\n')
+			if i < 0 {
+				break
+			}
+			if err := fn(parseLogLine(stream, buf[:i])); err != nil {
+				return err
+			}
+			buf = buf[i+1:]
+		}
+		partial[stream] = append([]byte(nil), buf...)
+	}
+}
+
+// parseLogLine splits the RFC3339Nano timestamp Docker prepends when
+// timestamps=1.
+func parseLogLine(stream string, raw []byte) LogLine {
+	s := strings.TrimRight(string(raw), "\r\n")
+	line := LogLine{Stream: stream, Line: s}
+	if ts, rest, ok := strings.Cut(s, " "); ok {
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			line.Time = t
+			line.Line = rest
+		}
+	}
+	return line
+}
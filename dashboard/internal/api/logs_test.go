@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// logFrame builds one multiplexed Docker log frame.
+func logFrame(stream byte, payload string) []byte {
+	b := make([]byte, 8, 8+len(payload))
+	b[0] = stream
+	binary.BigEndian.PutUint32(b[4:], uint32(len(payload)))
+	return append(b, payload...)
+}
+
+func TestDemuxLogs(t *testing.T) {
+	ts := "2024-05-01T10:00:00.123456789Z"
+	tests := []struct {
+		name    string
+		body    []byte
+		tty     bool
+		want    []LogLine
+		wantErr bool
+	}{
+		{
+			name: "one line per frame",
+			body: bytes.Join([][]byte{
+				logFrame(1, ts+" started\n"),
+				logFrame(2, ts+" warning: disk\n"),
+			}, nil),
+			want: []LogLine{
+				{Stream: "stdout", Line: "started"},
+				{Stream: "stderr", Line: "warning: disk"},
+			},
+		},
+		{
+			name: "line split across frames",
+			body: bytes.Join([][]byte{
+				logFrame(1, ts+" hel"),
+				logFrame(2, ts+" err\n"),
+				logFrame(1, "lo\n"+ts+" world\r\n"),
+			}, nil),
+			want: []LogLine{
+				{Stream: "stderr", Line: "err"},
+				{Stream: "stdout", Line: "hello"},
+				{Stream: "stdout", Line: "world"},
+			},
+		},
+		{
+			name: "unterminated last line",
+			body: logFrame(1, ts+" done"),
+			want: []LogLine{{Stream: "stdout", Line: "done"}},
+		},
+		{
+			name: "without timestamp",
+			body: logFrame(1, "plain line\n"),
+			want: []LogLine{{Stream: "stdout", Line: "plain line"}},
+		},
+		{
+			name: "tty",
+			body: []byte(ts + " one\n" + ts + " two"),
+			tty:  true,
+			want: []LogLine{
+				{Stream: "stdout", Line: "one"},
+				{Stream: "stdout", Line: "two"},
+			},
+		},
+		{
+			name:    "oversized frame",
+			body:    []byte{1, 0, 0, 0, 0xff, 0xff, 0xff, 0xff},
+			wantErr: true,
+		},
+		{
+			name:    "truncated payload",
+			body:    logFrame(1, "cut short\n")[:12],
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []LogLine
+			// One byte per read exercises the frame reassembly.
+			r := iotest.OneByteReader(bytes.NewReader(tt.body))
+			err := demuxLogs(r, tt.tty, func(l LogLine) error {
+				got = append(got, l)
+				return nil
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("demuxLogs() = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("demuxLogs() = %v", err)
+			}
+			for i := range got {
+				if got[i].Line != "plain line" && !got[i].Time.Equal(mustTime(ts)) {
+					t.Errorf("line %d time = %s, want %s", i, got[i].Time, ts)
+				}
+				got[i].Time = time.Time{}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("demuxLogs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDemuxLogsStopsOnCallbackError(t *testing.T) {
+	body := bytes.Join([][]byte{logFrame(1, "a\nb\n"), logFrame(1, "c\n")}, nil)
+	stop := errors.New("stop")
+	n := 0
+	err := demuxLogs(bytes.NewReader(body), false, func(LogLine) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("demuxLogs() = %v after %d lines, want the callback error after 1", err, n)
+	}
+	if err := demuxLogs(iotest.ErrReader(io.ErrUnexpectedEOF), true, func(LogLine) error { return nil }); err == nil {
+		t.Error("demuxLogs() on a failing reader = nil, want an error")
+	}
+}
+
+func mustTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
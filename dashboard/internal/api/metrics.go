@@ -0,0 +1,212 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"arcticmon/internal/store"
+)
+
+// MetricsHandler exposes the dashboard state in Prometheus text format.
+type MetricsHandler struct {
+	store *store.Store
+}
+
+func (m *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	p := &promWriter{w: bw, described: map[string]bool{}}
+
+	d := m.store.Get()
+
+	// Host
+	h := d.Host
+	p.gauge("arcticmon_host_cpu_percent", "Aggregate CPU usage percent.", h.CPUPercent)
+	for _, c := range h.CPUCores {
+		p.gauge("arcticmon_host_cpu_core_percent", "Per-core CPU usage percent.", c.Percent, "core", strconv.Itoa(c.ID))
+	}
+	p.gauge("arcticmon_host_memory_total_bytes", "Total memory in bytes.", float64(h.MemTotal))
+	p.gauge("arcticmon_host_memory_used_bytes", "Used memory in bytes.", float64(h.MemUsed))
+	p.gauge("arcticmon_host_swap_total_bytes", "Total swap in bytes.", float64(h.SwapTotal))
+	p.gauge("arcticmon_host_swap_used_bytes", "Used swap in bytes.", float64(h.SwapUsed))
+	for _, disk := range h.Disks {
+		p.gauge("arcticmon_host_disk_total_bytes", "Filesystem size in bytes.", float64(disk.Total), "mount", disk.Mount, "label", disk.Label)
+	}
+	for _, disk := range h.Disks {
+		p.gauge("arcticmon_host_disk_used_bytes", "Filesystem used bytes.", float64(disk.Used), "mount", disk.Mount, "label", disk.Label)
+	}
+	if h.GPU.Available {
+		p.gauge("arcticmon_host_gpu_utilization_percent", "GPU utilization percent.", float64(h.GPU.UtilPercent), "gpu", h.GPU.Name)
+		p.gauge("arcticmon_host_gpu_temperature_celsius", "GPU temperature.", float64(h.GPU.TempC), "gpu", h.GPU.Name)
+		p.gauge("arcticmon_host_gpu_memory_used_bytes", "GPU memory used in bytes.", float64(h.GPU.MemUsed), "gpu", h.GPU.Name)
+		p.gauge("arcticmon_host_gpu_memory_total_bytes", "GPU memory total in bytes.", float64(h.GPU.MemTotal), "gpu", h.GPU.Name)
+	}
+	p.gauge("arcticmon_host_ssh_sessions", "Active SSH sessions on the host.", float64(h.SSHSessions))
+
+	// Containers
+	for _, svc := range d.Services {
+		p.gauge("arcticmon_container_up", "1 if the container is running.", boolFloat(svc.Status == "running"), "name", svc.Name, "image", svc.Image)
+	}
+	for _, svc := range d.Services {
+		p.gauge("arcticmon_container_state", "Container state (1 for the current state).", 1, "name", svc.Name, "state", svc.Status)
+	}
+	for _, svc := range d.Services {
+		p.gauge("arcticmon_container_health", "Container health (1 for the current health).", 1, "name", svc.Name, "health", svc.Health)
+	}
+	for _, svc := range d.Services {
+		if svc.Stats != nil {
+			p.gauge("arcticmon_container_cpu_percent", "Container CPU usage percent of one core.", svc.Stats.CPUPercent, "name", svc.Name)
+		}
+	}
+	for _, svc := range d.Services {
+		if svc.Stats != nil {
+			p.gauge("arcticmon_container_memory_usage_bytes", "Container memory usage excluding page cache.", float64(svc.Stats.MemUsage), "name", svc.Name)
+		}
+	}
+	for _, svc := range d.Services {
+		if svc.Stats != nil && svc.Stats.MemLimit > 0 {
+			p.gauge("arcticmon_container_memory_limit_bytes", "Container memory limit.", float64(svc.Stats.MemLimit), "name", svc.Name)
+		}
+	}
+	for _, svc := range d.Services {
+		if svc.Stats != nil {
+			p.counter("arcticmon_container_network_receive_bytes_total", "Bytes received by the container.", float64(svc.Stats.NetRx), "name", svc.Name)
+		}
+	}
+	for _, svc := range d.Services {
+		if svc.Stats != nil {
+			p.counter("arcticmon_container_network_transmit_bytes_total", "Bytes sent by the container.", float64(svc.Stats.NetTx), "name", svc.Name)
+		}
+	}
+	for _, svc := range d.Services {
+		if svc.Stats != nil {
+			p.counter("arcticmon_container_block_read_bytes_total", "Bytes read from block devices.", float64(svc.Stats.BlockRead), "name", svc.Name)
+		}
+	}
+	for _, svc := range d.Services {
+		if svc.Stats != nil {
+			p.counter("arcticmon_container_block_write_bytes_total", "Bytes written to block devices.", float64(svc.Stats.BlockWrite), "name", svc.Name)
+		}
+	}
+	for _, svc := range d.Services {
+		p.counter("arcticmon_container_restarts_total", "Restarts observed from Docker events.", float64(svc.RestartCount), "name", svc.Name)
+	}
+
+	// qBittorrent
+	t := d.Torrents
+	p.gauge("arcticmon_qbittorrent_download_speed_bytes", "Global download speed in bytes/s.", float64(t.DLSpeed))
+	p.gauge("arcticmon_qbittorrent_upload_speed_bytes", "Global upload speed in bytes/s.", float64(t.UPSpeed))
+	p.gauge("arcticmon_qbittorrent_ratio_average", "Average share ratio.", t.Ratio)
+	p.gauge("arcticmon_qbittorrent_torrents", "Torrent counts by group.", float64(t.TotalCount), "group", "total")
+	p.gauge("arcticmon_qbittorrent_torrents", "Torrent counts by group.", float64(t.ActiveCount), "group", "active")
+	p.gauge("arcticmon_qbittorrent_torrents", "Torrent counts by group.", float64(t.SeedingCount), "group", "seeding")
+
+	// Download queues
+	queue := map[string]int{"Radarr": 0, "Sonarr": 0, "SABnzbd": 0}
+	for _, dl := range d.Downloads {
+		queue[dl.Source]++
+	}
+	sources := make([]string, 0, len(queue))
+	for src := range queue {
+		sources = append(sources, src)
+	}
+	sort.Strings(sources)
+	for _, src := range sources {
+		p.gauge("arcticmon_download_queue_items", "Queued downloads by source.", float64(queue[src]), "source", src)
+	}
+
+	// Unmanic
+	p.gauge("arcticmon_transcode_pending", "Pending Unmanic tasks.", float64(d.Transcodes.Pending))
+	for _, wk := range d.Transcodes.Workers {
+		p.gauge("arcticmon_transcode_worker_busy", "1 if the Unmanic worker is processing a file.", boolFloat(wk.Status == "working"), "worker", wk.ID)
+	}
+	for _, wk := range d.Transcodes.Workers {
+		p.gauge("arcticmon_transcode_worker_progress_percent", "Unmanic worker progress percent.", wk.Progress, "worker", wk.ID)
+	}
+
+	// Library
+	p.gauge("arcticmon_library_items", "Jellyfin library item counts.", float64(d.Library.Movies), "type", "movies")
+	p.gauge("arcticmon_library_items", "Jellyfin library item counts.", float64(d.Library.Series), "type", "series")
+	p.gauge("arcticmon_library_items", "Jellyfin library item counts.", float64(d.Library.Episodes), "type", "episodes")
+	p.gauge("arcticmon_library_items", "Jellyfin library item counts.", float64(d.Library.Music), "type", "music")
+
+	// SSH auth
+	ssh := d.SSHSecurity
+	for _, row := range []struct {
+		result, window string
+		n              int
+	}{
+		{"failed", "24h", ssh.Failed24h}, {"failed", "7d", ssh.Failed7d}, {"failed", "30d", ssh.Failed30d},
+		{"accepted", "24h", ssh.Accepted24h}, {"accepted", "7d", ssh.Accepted7d}, {"accepted", "30d", ssh.Accepted30d},
+	} {
+		p.gauge("arcticmon_ssh_auth_events", "SSH authentication events in the trailing window.", float64(row.n), "result", row.result, "window", row.window)
+	}
+
+	// Collectors
+	stats := d.Collectors
+	for _, st := range stats {
+		p.gauge("arcticmon_collector_up", "1 if the last collection succeeded.", boolFloat(st.State == "ok"), "collector", st.Name, "state", st.State)
+	}
+	for _, st := range stats {
+		p.gauge("arcticmon_collector_duration_seconds", "Duration of the last collection.", st.LastDurationMs/1000, "collector", st.Name)
+	}
+	for _, st := range stats {
+		p.gauge("arcticmon_collector_consecutive_failures", "Failures since the last successful collection.", float64(st.ConsecutiveFailures), "collector", st.Name)
+	}
+	for _, st := range stats {
+		p.counter("arcticmon_collector_runs_total", "Collections attempted.", float64(st.Runs), "collector", st.Name)
+	}
+	for _, st := range stats {
+		p.counter("arcticmon_collector_errors_total", "Collections that returned an error.", float64(st.Errors), "collector", st.Name)
+	}
+}
+
+// promWriter writes the Prometheus text exposition format, emitting HELP
+// and TYPE once per metric family. Samples of a family must be contiguous.
+type promWriter struct {
+	w         *bufio.Writer
+	described map[string]bool
+}
+
+func (p *promWriter) gauge(name, help string, v float64, labels ...string) {
+	p.sample(name, "gauge", help, v, labels)
+}
+
+func (p *promWriter) counter(name, help string, v float64, labels ...string) {
+	p.sample(name, "counter", help, v, labels)
+}
+
+func (p *promWriter) sample(name, typ, help string, v float64, labels []string) {
+	if !p.described[name] {
+		p.described[name] = true
+		fmt.Fprintf(p.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	p.w.WriteString(name)
+	if len(labels) > 0 {
+		p.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				p.w.WriteByte(',')
+			}
+			fmt.Fprintf(p.w, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+		}
+		p.w.WriteByte('}')
+	}
+	fmt.Fprintf(p.w, " %s\n", strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
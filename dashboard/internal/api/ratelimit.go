@@ -0,0 +1,151 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"arcticmon/internal/jobs"
+)
+
+// maxBuckets bounds the number of tracked users before full buckets are
+// dropped.
+const maxBuckets = 1024
+
+// RateLimiter gives each user a token bucket of burst actions that refills
+// one token per interval, so one user's actions never block another's.
+type RateLimiter struct {
+	mu       sync.Mutex
+	burst    int
+	interval time.Duration
+	buckets  map[string]*bucket
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewRateLimiter creates a limiter allowing burst actions per user, refilled
+// one every interval.
+func NewRateLimiter(burst int, interval time.Duration) *RateLimiter {
+	return &RateLimiter{
+		burst:    burst,
+		interval: interval,
+		buckets:  make(map[string]*bucket),
+	}
+}
+
+// SetLimits applies a reloaded burst and interval; buckets keep their
+// tokens, capped at the new burst on their next refill.
+func (rl *RateLimiter) SetLimits(burst int, interval time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.burst, rl.interval = burst, interval
+}
+
+// limits returns the current burst and interval.
+func (rl *RateLimiter) limits() (int, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.burst, rl.interval
+}
+
+// limitKey identifies the caller: the user, or the client IP when
+// authentication is disabled.
+func limitKey(r *http.Request) string {
+	if user := requestUser(r); user != "" {
+		return "user:" + user
+	}
+	return "ip:" + clientIP(r)
+}
+
+// refillLocked returns key's bucket topped up to now.
+func (rl *RateLimiter) refillLocked(key string, now time.Time) *bucket {
+	b := rl.buckets[key]
+	if b == nil {
+		if len(rl.buckets) >= maxBuckets {
+			rl.pruneLocked(now)
+		}
+		b = &bucket{tokens: float64(rl.burst), updated: now}
+		rl.buckets[key] = b
+		return b
+	}
+	b.tokens = min(float64(rl.burst), b.tokens+float64(now.Sub(b.updated))/float64(rl.interval))
+	b.updated = now
+	return b
+}
+
+func (rl *RateLimiter) pruneLocked(now time.Time) {
+	full := time.Duration(rl.burst) * rl.interval
+	for key, b := range rl.buckets {
+		if now.Sub(b.updated) >= full {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// take spends a token of key, or returns how long until one is available.
+func (rl *RateLimiter) take(key string) (bool, time.Duration) {
+	now := time.Now()
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	b := rl.refillLocked(key, now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) * float64(rl.interval))
+}
+
+// peek returns the whole tokens left for key and the time until the next
+// one.
+func (rl *RateLimiter) peek(key string) (int, time.Duration) {
+	now := time.Now()
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	b := rl.refillLocked(key, now)
+	if b.tokens >= float64(rl.burst) {
+		return rl.burst, 0
+	}
+	frac := b.tokens - float64(int(b.tokens))
+	return int(b.tokens), time.Duration((1 - frac) * float64(rl.interval))
+}
+
+func (rl *RateLimiter) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := rl.take(limitKey(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+			writeError(w, http.StatusTooManyRequests, "rate limited, try again later")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// actionsStatus reports the jobs holding the action lease and the
+// caller's remaining action budget.
+func actionsStatus(jm *jobs.Manager, rl *RateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		holders := jm.Holders()
+		remaining, next := rl.peek(limitKey(r))
+		burst, interval := rl.limits()
+		status := map[string]any{
+			"busy":    len(holders) > 0,
+			"holders": holders,
+			"rateLimit": map[string]any{
+				"burst":          burst,
+				"interval":       interval.String(),
+				"remaining":      remaining,
+				"nextTokenInSec": int((next + time.Second - 1) / time.Second),
+			},
+		}
+		for _, h := range holders {
+			if h.Lease == jobs.GlobalLease {
+				status["lease"] = h
+			}
+		}
+		writeJSON(w, status)
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterTake(t *testing.T) {
+	tests := []struct {
+		name     string
+		burst    int
+		interval time.Duration
+		takes    int
+		want     []bool
+	}{
+		{name: "within burst", burst: 3, interval: time.Hour, takes: 3, want: []bool{true, true, true}},
+		{name: "beyond burst", burst: 2, interval: time.Hour, takes: 4, want: []bool{true, true, false, false}},
+		{name: "burst of one", burst: 1, interval: time.Hour, takes: 2, want: []bool{true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := NewRateLimiter(tt.burst, tt.interval)
+			for i := 0; i < tt.takes; i++ {
+				ok, wait := rl.take("user:alice")
+				if ok != tt.want[i] {
+					t.Fatalf("take %d = %v, want %v", i, ok, tt.want[i])
+				}
+				if !ok && (wait <= 0 || wait > tt.interval) {
+					t.Errorf("take %d wait = %s, want within %s", i, wait, tt.interval)
+				}
+			}
+			// Budgets are per user.
+			if ok, _ := rl.take("user:bob"); !ok {
+				t.Error("another user was limited")
+			}
+		})
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	rl := NewRateLimiter(2, 20*time.Millisecond)
+	rl.take("k")
+	rl.take("k")
+	if ok, _ := rl.take("k"); ok {
+		t.Fatal("take succeeded on an empty bucket")
+	}
+	time.Sleep(25 * time.Millisecond)
+	if ok, _ := rl.take("k"); !ok {
+		t.Fatal("bucket did not refill")
+	}
+	// Refills stop at the burst.
+	time.Sleep(100 * time.Millisecond)
+	if n, next := rl.peek("k"); n != 2 || next != 0 {
+		t.Errorf("peek() = %d, %s, want 2, 0", n, next)
+	}
+}
+
+func TestRateLimiterSetLimits(t *testing.T) {
+	rl := NewRateLimiter(2, time.Hour)
+	rl.take("k")
+	rl.take("k")
+
+	// A reload keeps the spent budget and applies the new burst.
+	rl.SetLimits(5, time.Hour)
+	if ok, _ := rl.take("k"); ok {
+		t.Error("SetLimits() refilled a spent bucket")
+	}
+	if n, _ := rl.peek("fresh"); n != 5 {
+		t.Errorf("new bucket holds %d, want the new burst of 5", n)
+	}
+	if burst, interval := rl.limits(); burst != 5 || interval != time.Hour {
+		t.Errorf("limits() = %d, %s", burst, interval)
+	}
+}
+
+func TestRateLimiterWrap(t *testing.T) {
+	rl := NewRateLimiter(1, time.Minute)
+	h := rl.wrap(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	codes := []int{http.StatusAccepted, http.StatusTooManyRequests}
+	for i, want := range codes {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest("POST", "/api/actions/restart-stack", nil))
+		if rec.Code != want {
+			t.Fatalf("request %d: status %d, want %d", i, rec.Code, want)
+		}
+		if want == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "60" {
+			t.Errorf("Retry-After = %q, want 60", rec.Header().Get("Retry-After"))
+		}
+	}
+}
@@ -1,21 +1,43 @@
 package api
 
 import (
-	"crypto/subtle"
 	"embed"
 	"io/fs"
 	"net/http"
-	"sync"
-	"time"
 
+	"arcticmon/internal/audit"
+	"arcticmon/internal/auth"
+	"arcticmon/internal/collector"
 	"arcticmon/internal/config"
+	"arcticmon/internal/history"
+	"arcticmon/internal/jobs"
 	"arcticmon/internal/store"
 )
 
-// NewRouter creates the HTTP mux with all routes registered.
-func NewRouter(s *store.Store, cfg *config.Config, webFS embed.FS) http.Handler {
+// Components are the long-lived parts of the API whose state must survive
+// the router being rebuilt on config reload.
+type Components struct {
+	Jobs     *jobs.Manager
+	Audit    *audit.Log
+	Users    *auth.Users
+	Sessions *auth.Sessions
+	Tokens   *auth.Tokens
+	Throttle *auth.Throttle
+	// Limiter holds the per-user action budgets; apply reloaded limits
+	// with SetLimits.
+	Limiter *RateLimiter
+	// Confirm holds the pending confirmation nonces of host actions.
+	Confirm *Confirmations
+	// LogStreams bounds concurrent log followers (see NewLogStreams).
+	LogStreams chan struct{}
+}
+
+// NewRouter creates the HTTP mux with all routes registered. Every route
+// needs at least the viewer role; those that change state need operator
+// or admin, as marked below.
+func NewRouter(s *store.Store, hist *history.DB, orch *collector.Orchestrator, comp *Components, cfg *config.Config, webFS embed.FS) http.Handler {
 	mux := http.NewServeMux()
-	h := &Handlers{store: s}
+	h := &Handlers{store: s, history: hist, orch: orch}
 
 	// Unauthenticated health endpoint for Docker healthcheck
 	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
@@ -23,11 +45,50 @@ func NewRouter(s *store.Store, cfg *config.Config, webFS embed.FS) http.Handler
 		w.Write([]byte("ok"))
 	})
 
+	// Login and user management
+	ah := &AuthHandler{cfg: cfg, users: comp.Users, sessions: comp.Sessions, tokens: comp.Tokens, throttle: comp.Throttle, audit: comp.Audit}
+	if cfg.OIDCIssuer != "" {
+		ah.oidc = auth.NewOIDC(auth.OIDCConfig{
+			Issuer:        cfg.OIDCIssuer,
+			ClientID:      cfg.OIDCClientID,
+			ClientSecret:  cfg.OIDCClientSecret,
+			RedirectURL:   cfg.OIDCRedirectURL,
+			Scopes:        cfg.OIDCScopes,
+			UsernameClaim: cfg.OIDCUsernameClaim,
+			GroupsClaim:   cfg.OIDCGroupsClaim,
+		})
+	}
+	mux.HandleFunc("GET /api/auth/config", ah.AuthConfig)
+	mux.HandleFunc("GET /api/auth/oidc/login", ah.OIDCLogin)
+	mux.HandleFunc("GET /api/auth/oidc/callback", ah.OIDCCallback)
+	mux.HandleFunc("POST /api/login", ah.Login)
+	mux.HandleFunc("POST /api/logout", ah.Logout)
+	mux.HandleFunc("GET /api/me", ah.Me)
+	mux.HandleFunc("GET /api/users", requireRole(auth.RoleAdmin, ah.ListUsers))
+	mux.HandleFunc("POST /api/users", requireRole(auth.RoleAdmin, ah.CreateUser))
+	mux.HandleFunc("PUT /api/users/{name}", requireRole(auth.RoleAdmin, ah.UpdateUser))
+	mux.HandleFunc("DELETE /api/users/{name}", requireRole(auth.RoleAdmin, ah.DeleteUser))
+
+	// API tokens
+	tokh := &TokensHandler{tokens: comp.Tokens, ssoTTL: cfg.SSOTokenTTL}
+	mux.HandleFunc("GET /api/tokens", tokh.List)
+	mux.HandleFunc("POST /api/tokens", tokh.Create)
+	mux.HandleFunc("DELETE /api/tokens/{id}", tokh.Revoke)
+
 	// API routes
 	mux.HandleFunc("GET /api/overview", h.Overview)
 	mux.HandleFunc("GET /api/services", h.Services)
+	mux.HandleFunc("GET /api/services/{name}/events", h.ServiceEvents)
+	logs := NewLogsHandler(cfg, comp.LogStreams)
+	mux.HandleFunc("GET /api/services/{name}/logs", logs.Logs)
+	mux.HandleFunc("GET /api/services/{name}/logs/stream", logs.Stream)
 	mux.HandleFunc("GET /api/streams", h.Streams)
 	mux.HandleFunc("GET /api/torrents", h.Torrents)
+	torh := &TorrentsHandler{store: s, orch: orch}
+	mux.HandleFunc("GET /api/torrents/list", torh.List)
+	mux.HandleFunc("GET /api/torrents/policy", torh.Policy)
+	mux.HandleFunc("POST /api/torrents/alt-speed", requireRole(auth.RoleOperator, torh.AltSpeed))
+	mux.HandleFunc("POST /api/torrents/{action}", requireRole(auth.RoleOperator, torh.Control))
 	mux.HandleFunc("GET /api/downloads", h.Downloads)
 	mux.HandleFunc("GET /api/requests", h.Requests)
 	mux.HandleFunc("GET /api/host", h.Host)
@@ -35,17 +96,43 @@ func NewRouter(s *store.Store, cfg *config.Config, webFS embed.FS) http.Handler
 	mux.HandleFunc("GET /api/library", h.Library)
 	mux.HandleFunc("GET /api/health", h.Health)
 	mux.HandleFunc("GET /api/ssh-security", h.SSHSecurity)
-
-	// Actions (rate-limited)
-	rl := newRateLimiter(30 * time.Second)
-	actions := NewActions(cfg)
-	mux.HandleFunc("POST /api/actions/restart-stack", rl.wrap(actions.RestartStack))
-	mux.HandleFunc("POST /api/actions/restart-vm", rl.wrap(actions.RestartVM))
-	mux.HandleFunc("POST /api/actions/update-stack", rl.wrap(actions.UpdateStack))
-	mux.HandleFunc("POST /api/actions/update-system", rl.wrap(actions.UpdateSystem))
+	mux.HandleFunc("GET /api/auth-security", h.AuthSecurity)
+	mux.HandleFunc("GET /api/alerts", h.Alerts)
+	mux.HandleFunc("GET /api/collectors", h.Collectors)
+	mux.HandleFunc("POST /api/collectors/{name}/refresh", requireRole(auth.RoleOperator, h.RefreshCollector))
+	mux.HandleFunc("GET /api/history/host", h.HostHistory)
+	mux.HandleFunc("GET /api/history/services/{name}", h.ServiceHistory)
+
+	// Actions (rate-limited per user; conflicting actions are refused
+	// while one holds the lease). Host-level actions are admin only and
+	// need a confirmation nonce from their /confirm endpoint.
+	rl := comp.Limiter
+	actions := NewActions(cfg, comp.Jobs)
+	mux.HandleFunc("GET /api/actions/status", actionsStatus(comp.Jobs, rl))
+	confirm := comp.Confirm
+	mux.HandleFunc("POST /api/actions/restart-stack", requireRole(auth.RoleOperator, rl.wrap(actions.RestartStack)))
+	mux.HandleFunc("POST /api/actions/restart-vm/confirm", requireRole(auth.RoleAdmin, confirm.Confirm("restart-vm")))
+	mux.HandleFunc("POST /api/actions/restart-vm", requireRole(auth.RoleAdmin, rl.wrap(confirm.require("restart-vm", actions.RestartVM))))
+	mux.HandleFunc("POST /api/actions/update-stack", requireRole(auth.RoleOperator, rl.wrap(actions.UpdateStack)))
+	mux.HandleFunc("POST /api/actions/update-system/confirm", requireRole(auth.RoleAdmin, confirm.Confirm("update-system")))
+	mux.HandleFunc("POST /api/actions/update-system", requireRole(auth.RoleAdmin, rl.wrap(confirm.require("update-system", actions.UpdateSystem))))
+	mux.HandleFunc("POST /api/services/{name}/{action}", requireRole(auth.RoleOperator, rl.wrap(actions.ContainerAction)))
+
+	// Action jobs
+	jh := &JobsHandler{jobs: comp.Jobs}
+	mux.HandleFunc("GET /api/jobs", jh.List)
+	mux.HandleFunc("GET /api/jobs/{id}", jh.Get)
+	mux.HandleFunc("GET /api/jobs/{id}/events", jh.Events)
+
+	// Audit log
+	audh := &AuditHandler{log: comp.Audit}
+	mux.HandleFunc("GET /api/audit", requireRole(auth.RoleAdmin, audh.Query))
+
+	// Prometheus exporter
+	mux.Handle("GET /metrics", &MetricsHandler{store: s})
 
 	// SSE
-	sse := &SSEHandler{store: s}
+	sse := &SSEHandler{store: s, heartbeat: cfg.SSEHeartbeat}
 	mux.HandleFunc("GET /api/events", sse.ServeHTTP)
 
 	// Static files (embedded)
@@ -53,9 +140,16 @@ func NewRouter(s *store.Store, cfg *config.Config, webFS embed.FS) http.Handler
 	fileServer := http.FileServer(http.FS(webSub))
 	mux.Handle("/", fileServer)
 
-	// Chain middleware: security headers → basic auth (skips /healthz)
+	// Chain middleware: security headers → client IP (X-Forwarded-For from
+	// trusted proxies) → audit → CSRF (origin checks on state-changing
+	// requests) → auth (token, proxy headers, session or basic; skips
+	// /healthz and the login page). Audit wraps the checks so rejected
+	// requests are recorded too.
 	var handler http.Handler = mux
-	handler = basicAuthMiddleware(handler, cfg)
+	handler = authMiddleware(handler, cfg, comp)
+	handler = csrfMiddleware(handler, cfg)
+	handler = auditMiddleware(handler, comp.Audit)
+	handler = clientIPMiddleware(handler, cfg)
 	handler = securityHeadersMiddleware(handler)
 
 	return handler
@@ -71,60 +165,3 @@ func securityHeadersMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
-
-// basicAuthMiddleware protects all endpoints except /healthz.
-func basicAuthMiddleware(next http.Handler, cfg *config.Config) http.Handler {
-	user := cfg.DashboardUser
-	pass := cfg.DashboardPass
-	if user == "" || pass == "" {
-		// No credentials configured, skip auth
-		return next
-	}
-
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Skip auth for healthcheck
-		if r.URL.Path == "/healthz" {
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		u, p, ok := r.BasicAuth()
-		if !ok ||
-			subtle.ConstantTimeCompare([]byte(u), []byte(user)) != 1 ||
-			subtle.ConstantTimeCompare([]byte(p), []byte(pass)) != 1 {
-			w.Header().Set("WWW-Authenticate", `Basic realm="Arctic Monitor"`)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
-
-// rateLimiter tracks last call time per endpoint.
-type rateLimiter struct {
-	mu       sync.Mutex
-	cooldown time.Duration
-	lastCall map[string]time.Time
-}
-
-func newRateLimiter(cooldown time.Duration) *rateLimiter {
-	return &rateLimiter{
-		cooldown: cooldown,
-		lastCall: make(map[string]time.Time),
-	}
-}
-
-func (rl *rateLimiter) wrap(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		rl.mu.Lock()
-		last, exists := rl.lastCall[r.URL.Path]
-		if exists && time.Since(last) < rl.cooldown {
-			rl.mu.Unlock()
-			http.Error(w, `{"error":"rate limited, try again later"}`, http.StatusTooManyRequests)
-			return
-		}
-		rl.lastCall[r.URL.Path] = time.Now()
-		rl.mu.Unlock()
-		next(w, r)
-	}
-}
@@ -1,15 +1,24 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"arcticmon/internal/store"
 )
 
 // SSEHandler serves Server-Sent Events for real-time dashboard updates.
+// Each event has an increasing id and is named after the part of the state
+// it updates ("host", "streams"...). ?topics=host,streams limits the stream
+// to those events. A client reconnecting with Last-Event-ID (or
+// ?lastEventId=) gets the events it missed replayed; new clients, and
+// those too far behind, first get a "snapshot" event with the full state.
 type SSEHandler struct {
-	store *store.Store
+	store     *store.Store
+	heartbeat time.Duration
 }
 
 func (s *SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -19,32 +28,69 @@ func (s *SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	after, _ := strconv.ParseUint(lastID, 10, 64)
+	topics := splitComma(r.URL.Query().Get("topics"))
+
+	sub := s.store.Subscribe(topics, after)
+	if sub == nil {
+		http.Error(w, "too many connections", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.store.Unsubscribe(sub)
+
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no")
 
-	ch := s.store.Subscribe()
-	if ch == nil {
-		http.Error(w, "too many connections", http.StatusServiceUnavailable)
-		return
+	fmt.Fprintf(w, "retry: 3000\n: connected\n\n")
+	if after == 0 || sub.Missed {
+		// The snapshot supersedes whatever is left to replay.
+		sub.Replay = nil
+		snap := s.store.Snapshot()
+		if len(topics) > 0 {
+			filtered := make(map[string]any, len(topics))
+			for _, t := range topics {
+				if v, ok := snap[t]; ok {
+					filtered[t] = v
+				}
+			}
+			snap = filtered
+		}
+		if data, err := json.Marshal(snap); err == nil {
+			writeEvent(w, store.Event{ID: sub.LastID, Name: "snapshot", Data: data})
+		}
+	}
+	for _, ev := range sub.Replay {
+		writeEvent(w, ev)
 	}
-	defer s.store.Unsubscribe(ch)
-
-	// Send initial keepalive
-	fmt.Fprintf(w, ": connected\n\n")
 	flusher.Flush()
 
+	heartbeat := time.NewTicker(s.heartbeat)
+	defer heartbeat.Stop()
 	for {
 		select {
 		case <-r.Context().Done():
 			return
-		case msg, ok := <-ch:
+		case <-heartbeat.C:
+			fmt.Fprintf(w, ": ping\n\n")
+			flusher.Flush()
+		case ev, ok := <-sub.C:
 			if !ok {
+				// Dropped for falling behind: the client reconnects and
+				// replays from its last event ID.
 				return
 			}
-			fmt.Fprintf(w, "data: %s\n\n", msg)
+			writeEvent(w, ev)
 			flusher.Flush()
 		}
 	}
 }
+
+func writeEvent(w http.ResponseWriter, ev store.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Name, ev.Data)
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"arcticmon/internal/audit"
+	"arcticmon/internal/auth"
+	"arcticmon/internal/config"
+)
+
+// oidcCookie carries the state, nonce and PKCE verifier of a login in
+// progress between the redirect to the provider and the callback.
+const oidcCookie = "arcticmon_oidc"
+
+type oidcPending struct {
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"`
+	Next     string `json:"r"`
+}
+
+// AuthConfig tells the login page which sign-in methods are available.
+func (a *AuthHandler) AuthConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]bool{
+		"local": a.users.Len() > 0,
+		"oidc":  a.oidc != nil,
+	})
+}
+
+// OIDCLogin redirects the browser to the identity provider.
+func (a *AuthHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if a.oidc == nil {
+		writeError(w, http.StatusNotFound, "single sign-on is not configured")
+		return
+	}
+	state, nonce, verifier := auth.NewPKCE()
+	target, err := a.oidc.AuthURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("[oidc] %v", err)
+		writeError(w, http.StatusBadGateway, "identity provider unavailable")
+		return
+	}
+
+	data, _ := json.Marshal(oidcPending{State: state, Nonce: nonce, Verifier: verifier, Next: localPath(r.URL.Query().Get("next"))})
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(data),
+		Path:     "/api/auth/oidc",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// OIDCCallback completes the login: it checks the state, redeems the code
+// and starts a session with the role mapped from the user's groups.
+func (a *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if a.oidc == nil {
+		writeError(w, http.StatusNotFound, "single sign-on is not configured")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcCookie, Path: "/api/auth/oidc", MaxAge: -1})
+
+	fail := func(user, msg string) {
+		a.record(r, "login/oidc", user, "denied", msg)
+		http.Redirect(w, r, "/login.html?error="+url.QueryEscape(msg), http.StatusSeeOther)
+	}
+
+	var pending oidcPending
+	c, err := r.Cookie(oidcCookie)
+	if err == nil {
+		var data []byte
+		if data, err = base64.RawURLEncoding.DecodeString(c.Value); err == nil {
+			err = json.Unmarshal(data, &pending)
+		}
+	}
+	q := r.URL.Query()
+	if err != nil || pending.State == "" || subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(pending.State)) != 1 {
+		fail("", "login expired, please try again")
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		fail("", "identity provider: "+e)
+		return
+	}
+
+	login, err := a.oidc.Exchange(r.Context(), q.Get("code"), pending.Verifier, pending.Nonce)
+	if err != nil {
+		log.Printf("[oidc] %v", err)
+		fail("", "sign-in failed")
+		return
+	}
+	user := auth.OIDCUserPrefix + login.User
+	role, ok := auth.RoleForGroups(login.Groups, a.cfg.GroupRoles, a.cfg.DefaultRole)
+	if !ok {
+		// Revoke the tokens of a user who lost every mapped group.
+		if err := a.tokens.CapOwner(user, ""); err != nil {
+			log.Printf("[oidc] revoke tokens of %s: %v", user, err)
+		}
+		fail(user, "no dashboard role for this account")
+		return
+	}
+	// Keep the user's API tokens within the groups they have now.
+	if err := a.tokens.CapOwner(user, role); err != nil {
+		log.Printf("[oidc] cap tokens of %s: %v", user, err)
+	}
+
+	a.startSession(w, r, user, role)
+	a.record(r, "login/oidc", user, "ok", "")
+	http.Redirect(w, r, pending.Next, http.StatusSeeOther)
+}
+
+// startSession creates a session and sets its cookie.
+func (a *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, user string, role auth.Role) {
+	token, sess := a.sessions.Create(user, role, a.cfg.SessionTTL)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  sess.ExpiresAt,
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// record adds an audit entry for a GET endpoint that changes state, which
+// the audit middleware does not see.
+func (a *AuthHandler) record(r *http.Request, action, user, outcome, msg string) {
+	if a.audit == nil {
+		return
+	}
+	err := a.audit.Append(audit.Entry{
+		Time:    time.Now(),
+		User:    user,
+		IP:      clientIP(r),
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Action:  action,
+		Outcome: outcome,
+		Error:   msg,
+	})
+	if err != nil {
+		log.Printf("[audit] %v", err)
+	}
+}
+
+// proxyAuth trusts identity headers set by an authenticating reverse proxy
+// (Authelia, Authentik, oauth2-proxy...) when the request comes from one
+// of its networks.
+type proxyAuth struct {
+	nets         []netip.Prefix
+	userHeader   string
+	groupsHeader string
+	groupRoles   map[string]string
+	defaultRole  string
+}
+
+func newProxyAuth(cfg *config.Config) *proxyAuth {
+	p := &proxyAuth{
+		userHeader:   cfg.ProxyUserHeader,
+		groupsHeader: cfg.ProxyGroupsHeader,
+		groupRoles:   cfg.GroupRoles,
+		defaultRole:  cfg.DefaultRole,
+	}
+	for _, n := range cfg.ProxyNetworks {
+		if prefix, err := netip.ParsePrefix(n); err == nil {
+			p.nets = append(p.nets, prefix.Masked())
+		}
+	}
+	if len(p.nets) == 0 {
+		return nil
+	}
+	return p
+}
+
+// trusted reports whether the request comes directly from a proxy network.
+func (p *proxyAuth) trusted(r *http.Request) bool {
+	addr, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := addr.Addr().Unmap()
+	for _, n := range p.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// identity returns the proxy-asserted identity; found is false when the
+// request is not from a trusted proxy or carries no user header.
+func (p *proxyAuth) identity(r *http.Request) (id auth.Identity, found, ok bool) {
+	if p == nil || !p.trusted(r) {
+		return auth.Identity{}, false, false
+	}
+	user := strings.TrimSpace(r.Header.Get(p.userHeader))
+	if user == "" {
+		return auth.Identity{}, false, false
+	}
+	var groups []string
+	if p.groupsHeader != "" {
+		groups = splitComma(r.Header.Get(p.groupsHeader))
+	}
+	role, ok := auth.RoleForGroups(groups, p.groupRoles, p.defaultRole)
+	return auth.Identity{User: auth.ProxyUserPrefix + user, Role: role, Method: "proxy"}, true, ok
+}
+
+// localPath keeps post-login redirects on this site.
+func localPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"arcticmon/internal/auth"
+	"arcticmon/internal/config"
+)
+
+type clientIPKey struct{}
+
+// clientIPMiddleware resolves the client address for audit entries, token
+// usage and sign-in throttling. X-Forwarded-For is honoured only when the
+// connecting peer is a trusted proxy: the client is the right-most
+// forwarded address that is not itself a trusted proxy.
+func clientIPMiddleware(next http.Handler, cfg *config.Config) http.Handler {
+	var nets []netip.Prefix
+	for _, n := range cfg.TrustedProxies {
+		if prefix, err := netip.ParsePrefix(n); err == nil {
+			nets = append(nets, prefix.Masked())
+		}
+	}
+	trusted := func(ip netip.Addr) bool {
+		for _, n := range nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peer, err := netip.ParseAddrPort(r.RemoteAddr)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ip := peer.Addr().Unmap()
+		if trusted(ip) {
+			hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+			for i := len(hops) - 1; i >= 0; i-- {
+				hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+				if err != nil {
+					break
+				}
+				ip = hop.Unmap()
+				if !trusted(ip) {
+					break
+				}
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip.String())))
+	})
+}
+
+// signInKeys are the throttle keys of a password attempt: the client IP
+// and, when given, the username.
+func signInKeys(r *http.Request, user string) []auth.ThrottleKey {
+	keys := []auth.ThrottleKey{{Kind: "ip", Key: clientIP(r)}}
+	if user != "" {
+		keys = append(keys, auth.ThrottleKey{Kind: "user", Key: strings.ToLower(user)})
+	}
+	return keys
+}
+
+// throttled answers 429 with Retry-After while keys must wait or are
+// locked out.
+func throttled(w http.ResponseWriter, th *auth.Throttle, keys []auth.ThrottleKey) bool {
+	wait, locked := th.Check(keys...)
+	if wait <= 0 {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+	msg := "too many failed sign-ins, try again later"
+	if locked {
+		msg = "locked out after too many failed sign-ins, try again later"
+	}
+	writeError(w, http.StatusTooManyRequests, msg)
+	return true
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"arcticmon/internal/auth"
+)
+
+// TokensHandler manages named, scoped API tokens. Users manage their own
+// tokens; admins see and revoke everyone's.
+type TokensHandler struct {
+	tokens *auth.Tokens
+	ssoTTL time.Duration
+}
+
+// List returns the caller's tokens, or all tokens for admins (optionally
+// filtered with ?owner=).
+func (t *TokensHandler) List(w http.ResponseWriter, r *http.Request) {
+	id, _ := auth.FromContext(r.Context())
+	owner := id.User
+	if id.Role.Allows(auth.RoleAdmin) {
+		owner = r.URL.Query().Get("owner")
+	}
+	writeJSON(w, t.tokens.List(owner))
+}
+
+// Create issues a token from {"name", "scopes", "role", "expiresIn" or
+// "expiresAt"} and returns its secret once. The role defaults to, and may
+// not exceed, the caller's. Tokens cannot create tokens. Tokens of single
+// sign-on identities expire within auth.ssoTokenTtl.
+func (t *TokensHandler) Create(w http.ResponseWriter, r *http.Request) {
+	id, _ := auth.FromContext(r.Context())
+	if id.Method == "token" {
+		writeError(w, http.StatusForbidden, "API tokens cannot create tokens")
+		return
+	}
+
+	var req struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		Role      string     `json:"role"`
+		ExpiresIn string     `json:"expiresIn"`
+		ExpiresAt *time.Time `json:"expiresAt"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	role := id.Role
+	if req.Role != "" {
+		var err error
+		if role, err = auth.ParseRole(req.Role); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !id.Role.Allows(role) {
+			writeError(w, http.StatusForbidden, "cannot grant a role above your own")
+			return
+		}
+	}
+
+	expiresAt := req.ExpiresAt
+	if req.ExpiresIn != "" {
+		d, err := parseDurationParam(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid expiresIn")
+			return
+		}
+		at := time.Now().Add(d)
+		expiresAt = &at
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		writeError(w, http.StatusBadRequest, "expiry must be in the future")
+		return
+	}
+	if auth.SingleSignOn(id.User) {
+		limit := time.Now().Add(t.ssoTTL)
+		if expiresAt == nil || expiresAt.After(limit) {
+			expiresAt = &limit
+		}
+	}
+
+	tok, secret, err := t.tokens.Create(req.Name, id.User, role, req.Scopes, expiresAt)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"token": tok, "secret": secret})
+}
+
+// Revoke deletes token {id}. Only its owner or an admin may revoke it.
+func (t *TokensHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	id, _ := auth.FromContext(r.Context())
+	tok, ok := t.tokens.Get(r.PathValue("id"))
+	if !ok || (tok.Owner != id.User && !id.Role.Allows(auth.RoleAdmin)) {
+		writeError(w, http.StatusNotFound, auth.ErrUnknownToken.Error())
+		return
+	}
+	if err := t.tokens.Revoke(tok.ID); err != nil {
+		if errors.Is(err, auth.ErrUnknownToken) {
+			writeError(w, http.StatusNotFound, err.Error())
+		} else {
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
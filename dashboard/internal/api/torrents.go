@@ -0,0 +1,370 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"arcticmon/internal/collector"
+	"arcticmon/internal/models"
+	"arcticmon/internal/store"
+)
+
+// TorrentsHandler serves the full qBittorrent torrent list and controls
+// torrents through the qBittorrent collector's session.
+type TorrentsHandler struct {
+	store *store.Store
+	orch  *collector.Orchestrator
+}
+
+const (
+	// maxTorrentHashes bounds the torrents of one control request.
+	maxTorrentHashes = 100
+	// torrentCommandTimeout bounds a whole control request.
+	torrentCommandTimeout = 30 * time.Second
+)
+
+// torrentCommands maps control actions to their WebUI API endpoints,
+// newest first: qBittorrent 5 renamed pause/resume to stop/start.
+var torrentCommands = map[string][]string{
+	"pause":      {"/api/v2/torrents/stop", "/api/v2/torrents/pause"},
+	"resume":     {"/api/v2/torrents/start", "/api/v2/torrents/resume"},
+	"recheck":    {"/api/v2/torrents/recheck"},
+	"reannounce": {"/api/v2/torrents/reannounce"},
+	"delete":     {"/api/v2/torrents/delete"},
+	"category":   {"/api/v2/torrents/setCategory"},
+}
+
+// torrentResult is the outcome of a control action on one torrent.
+type torrentResult struct {
+	Hash   string `json:"hash"`
+	Name   string `json:"name,omitempty"`
+	Status string `json:"status"` // ok, error or not found
+	Error  string `json:"error,omitempty"`
+}
+
+// torrentStates groups raw qBittorrent states like its own sidebar filters.
+// Both the 4.x "paused" and 5.x "stopped" state names are listed.
+var torrentStates = map[string][]string{
+	"downloading": {"downloading", "forcedDL", "metaDL", "forcedMetaDL", "stalledDL", "queuedDL", "checkingDL", "allocating"},
+	"seeding":     {"uploading", "forcedUP", "stalledUP", "queuedUP", "checkingUP"},
+	"paused":      {"pausedDL", "pausedUP", "stoppedDL", "stoppedUP"},
+	"stopped":     {"pausedDL", "pausedUP", "stoppedDL", "stoppedUP"},
+	"stalled":     {"stalledDL", "stalledUP"},
+	"checking":    {"checkingDL", "checkingUP", "checkingResumeData"},
+	"errored":     {"error", "missingFiles"},
+}
+
+// torrentSorts are the sort keys of List, each an ascending comparison.
+var torrentSorts = map[string]func(a, b *models.Torrent) bool{
+	"name":     func(a, b *models.Torrent) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) },
+	"state":    func(a, b *models.Torrent) bool { return a.State < b.State },
+	"category": func(a, b *models.Torrent) bool { return a.Category < b.Category },
+	"size":     func(a, b *models.Torrent) bool { return a.Size < b.Size },
+	"progress": func(a, b *models.Torrent) bool { return a.Progress < b.Progress },
+	"dlspeed":  func(a, b *models.Torrent) bool { return a.DLSpeed < b.DLSpeed },
+	"upspeed":  func(a, b *models.Torrent) bool { return a.UPSpeed < b.UPSpeed },
+	"ratio":    func(a, b *models.Torrent) bool { return a.Ratio < b.Ratio },
+	"added":    func(a, b *models.Torrent) bool { return a.AddedOn.Before(b.AddedOn) },
+	"activity": func(a, b *models.Torrent) bool { return a.LastActivity.Before(b.LastActivity) },
+	"seeds":    func(a, b *models.Torrent) bool { return a.Seeds < b.Seeds },
+	"leechs":   func(a, b *models.Torrent) bool { return a.Leechs < b.Leechs },
+	// Unknown ETAs (-1) sort last.
+	"eta": func(a, b *models.Torrent) bool { return uint64(a.ETA) < uint64(b.ETA) },
+}
+
+// List returns a page of torrents. Query: state (downloading, seeding,
+// completed, paused/stopped, active, inactive, stalled, checking, errored
+// or a raw qBittorrent state), category, tag, search (name substring),
+// sort (name, state, category, size, progress, dlspeed, upspeed, ratio,
+// eta, added, activity, seeds or leechs; "-" prefix for descending,
+// default "-added"), page (from 1) and pageSize (default 50, max 500).
+func (t *TorrentsHandler) List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	sortKey, desc := q.Get("sort"), false
+	if sortKey == "" {
+		sortKey = "-added"
+	}
+	if strings.HasPrefix(sortKey, "-") {
+		sortKey, desc = sortKey[1:], true
+	}
+	less, ok := torrentSorts[sortKey]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "unknown sort: "+sortKey)
+		return
+	}
+	page, pageSize := 1, 50
+	if s := q.Get("page"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "invalid page")
+			return
+		}
+		page = n
+	}
+	if s := q.Get("pageSize"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "invalid pageSize")
+			return
+		}
+		pageSize = min(n, 500)
+	}
+
+	state, category, tag := q.Get("state"), q.Get("category"), q.Get("tag")
+	search := strings.ToLower(q.Get("search"))
+	var matched []*models.Torrent
+	list := t.store.TorrentList()
+	for i := range list {
+		tor := &list[i]
+		if (state != "" && !torrentInState(tor, state)) ||
+			(category != "" && tor.Category != category) ||
+			(tag != "" && !slices.Contains(tor.Tags, tag)) ||
+			(search != "" && !strings.Contains(strings.ToLower(tor.Name), search)) {
+			continue
+		}
+		matched = append(matched, tor)
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		if desc {
+			return less(matched[j], matched[i])
+		}
+		return less(matched[i], matched[j])
+	})
+
+	out := []models.Torrent{}
+	for _, tor := range matched[min((page-1)*pageSize, len(matched)):min(page*pageSize, len(matched))] {
+		out = append(out, *tor)
+	}
+	writeJSON(w, map[string]any{
+		"total":    len(matched),
+		"page":     page,
+		"pageSize": pageSize,
+		"pages":    (len(matched) + pageSize - 1) / pageSize,
+		"torrents": out,
+	})
+}
+
+// Policy returns the last torrent policy report: the torrents flagged as
+// stalled, dead or past their seeding targets, and recent remediations.
+// POST /api/collectors/torrent-policy/refresh runs the policy again.
+func (t *TorrentsHandler) Policy(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, t.store.TorrentPolicy())
+}
+
+func torrentInState(t *models.Torrent, state string) bool {
+	switch state {
+	case "completed":
+		return t.Progress >= 100
+	case "active":
+		return t.DLSpeed > 0 || t.UPSpeed > 0
+	case "inactive":
+		return t.DLSpeed == 0 && t.UPSpeed == 0
+	}
+	if states, ok := torrentStates[state]; ok {
+		return slices.Contains(states, t.State)
+	}
+	return t.State == state
+}
+
+// qbit returns the qBittorrent session, answering 503 when qBittorrent is
+// disabled or has no credentials.
+func (t *TorrentsHandler) qbit(w http.ResponseWriter) *collector.QbitTransferCollector {
+	q := t.orch.Qbit()
+	if q == nil {
+		writeError(w, http.StatusServiceUnavailable, "qbittorrent collector is disabled")
+		return nil
+	}
+	return q
+}
+
+// Control runs {action} (pause, resume, recheck, reannounce, delete or
+// category) on the torrents listed in a {"hashes": [...]} body or the
+// comma-separated hashes query. delete takes deleteFiles=true to remove
+// the downloaded data too; category takes category= (empty to clear it).
+// Torrents are handled one at a time so each gets its own result, which
+// is returned and recorded in the audit log.
+func (t *TorrentsHandler) Control(w http.ResponseWriter, r *http.Request) {
+	action := r.PathValue("action")
+	paths, ok := torrentCommands[action]
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown action: "+action)
+		return
+	}
+	query := r.URL.Query()
+	form := url.Values{}
+	switch action {
+	case "delete":
+		deleteFiles, err := strconv.ParseBool(query.Get("deleteFiles"))
+		if query.Get("deleteFiles") != "" && err != nil {
+			writeError(w, http.StatusBadRequest, "invalid deleteFiles")
+			return
+		}
+		form.Set("deleteFiles", strconv.FormatBool(deleteFiles))
+	case "category":
+		if !query.Has("category") {
+			writeError(w, http.StatusBadRequest, "category is required")
+			return
+		}
+		form.Set("category", query.Get("category"))
+	}
+
+	hashes, err := torrentHashes(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q := t.qbit(w)
+	if q == nil {
+		return
+	}
+
+	names := map[string]string{}
+	for _, tor := range t.store.TorrentList() {
+		names[tor.Hash] = tor.Name
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), torrentCommandTimeout)
+	defer cancel()
+	results := make([]torrentResult, 0, len(hashes))
+	succeeded, failed := 0, 0
+	for _, hash := range hashes {
+		res := torrentResult{Hash: hash, Name: names[hash]}
+		if _, known := names[hash]; !known {
+			res.Status = "not found"
+			results = append(results, res)
+			continue
+		}
+		form.Set("hashes", hash)
+		err := collector.ErrQbitUnsupported
+		for len(paths) > 0 {
+			if _, err = q.Command(ctx, paths[0], form); !errors.Is(err, collector.ErrQbitUnsupported) || len(paths) == 1 {
+				break
+			}
+			// Older API: skip the newer endpoint for the other torrents.
+			paths = paths[1:]
+		}
+		if err != nil {
+			res.Status, res.Error = "error", err.Error()
+			failed++
+		} else {
+			res.Status = "ok"
+			succeeded++
+		}
+		results = append(results, res)
+	}
+	setAuditResults(r, results)
+	if succeeded > 0 {
+		t.orch.Refresh("qbittorrent")
+	}
+
+	resp := map[string]any{
+		"action":    action,
+		"succeeded": succeeded,
+		"failed":    failed,
+		"results":   results,
+	}
+	if succeeded == 0 && failed > 0 {
+		// Report the first error so the audit entry records the failure.
+		resp["error"] = results[slices.IndexFunc(results, func(r torrentResult) bool { return r.Status == "error" })].Error
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// AltSpeed toggles the alternative speed limits, or sets them with
+// enabled=true|false, and returns the resulting mode.
+func (t *TorrentsHandler) AltSpeed(w http.ResponseWriter, r *http.Request) {
+	var want *bool
+	if s := r.URL.Query().Get("enabled"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid enabled")
+			return
+		}
+		want = &b
+	}
+	q := t.qbit(w)
+	if q == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), torrentCommandTimeout)
+	defer cancel()
+	enabled, err := q.AltSpeedLimits(ctx)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if want == nil || *want != enabled {
+		if _, err := q.Command(ctx, "/api/v2/transfer/toggleSpeedLimitsMode", nil); err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		enabled = !enabled
+		t.orch.Refresh("qbittorrent")
+	}
+	resp := map[string]bool{"altSpeedLimits": enabled}
+	setAuditResults(r, resp)
+	writeJSON(w, resp)
+}
+
+// torrentHashes reads the target torrents of a control request, lowercased
+// and deduplicated.
+func torrentHashes(r *http.Request) ([]string, error) {
+	var raw []string
+	if r.ContentLength != 0 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var body struct {
+			Hashes []string `json:"hashes"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 64<<10)).Decode(&body); err != nil {
+			return nil, errors.New("invalid request body")
+		}
+		raw = body.Hashes
+	} else {
+		raw = splitComma(r.URL.Query().Get("hashes"))
+	}
+
+	var hashes []string
+	for _, h := range raw {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if !validHash(h) {
+			return nil, errors.New("invalid torrent hash: " + h)
+		}
+		if !slices.Contains(hashes, h) {
+			hashes = append(hashes, h)
+		}
+	}
+	switch {
+	case len(hashes) == 0:
+		return nil, errors.New("no torrent hashes given")
+	case len(hashes) > maxTorrentHashes:
+		return nil, errors.New("too many torrents, at most " + strconv.Itoa(maxTorrentHashes) + " per request")
+	}
+	return hashes, nil
+}
+
+// validHash accepts v1 (SHA-1) and v2 (SHA-256) info hashes.
+func validHash(h string) bool {
+	if len(h) != 40 && len(h) != 64 {
+		return false
+	}
+	for _, c := range h {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,570 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"arcticmon/internal/jobs"
+)
+
+const (
+	// healthTimeout bounds the wait for a recreated container to report
+	// healthy before it is rolled back.
+	healthTimeout = 3 * time.Minute
+	// settleTime is how long a container without a healthcheck must stay
+	// running to be considered up.
+	settleTime = 10 * time.Second
+)
+
+// updateResult is the per-service outcome of a stack update. Status is one
+// of unchanged, updated, recreated (network parent was recreated or
+// rolled back), rolled-back, failed or skipped.
+type updateResult struct {
+	Name      string  `json:"name"`
+	Image     string  `json:"image"`
+	OldDigest string  `json:"oldDigest,omitempty"`
+	NewDigest string  `json:"newDigest,omitempty"`
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	Duration  float64 `json:"durationSeconds"`
+}
+
+// containerInspect is the part of GET /containers/{id}/json needed to
+// recreate a container. Config and HostConfig are kept as generic maps so
+// every setting survives the round trip.
+type containerInspect struct {
+	ID              string         `json:"Id"`
+	Name            string         `json:"Name"`
+	Image           string         `json:"Image"`
+	Config          map[string]any `json:"Config"`
+	HostConfig      map[string]any `json:"HostConfig"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			Aliases    []string       `json:"Aliases"`
+			IPAMConfig map[string]any `json:"IPAMConfig"`
+			Links      []string       `json:"Links"`
+			DriverOpts map[string]any `json:"DriverOpts"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+	State struct {
+		Status  string `json:"Status"`
+		Running bool   `json:"Running"`
+		Health  *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+	} `json:"State"`
+}
+
+type imageInspect struct {
+	ID          string         `json:"Id"`
+	RepoDigests []string       `json:"RepoDigests"`
+	Config      map[string]any `json:"Config"`
+}
+
+// UpdateStack pulls the image of every managed stack container and
+// recreates only those whose image changed, in dependency order, with
+// their inspected configuration. A recreated container that does not
+// become healthy is replaced by the previous one. Containers sharing the
+// network namespace of a recreated or rolled-back container are recreated
+// against its current ID as well; stopped ones are left stopped.
+func (a *Actions) UpdateStack(w http.ResponseWriter, r *http.Request) {
+	a.submit(w, r, "update-stack", jobs.GlobalLease, nil, a.updateStack)
+}
+
+func (a *Actions) updateStack(ctx context.Context, run *jobs.Run) (any, error) {
+	containers, err := a.stackContainers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list containers: %w", err)
+	}
+
+	var results []updateResult
+	failed := 0
+	// Each image reference is pulled once, even if shared.
+	pulled := map[string]*imageInspect{}
+	// Current IDs of recreated or restored containers, so network-namespace
+	// dependents can be pointed at them.
+	recreated := map[string]string{}
+	for _, c := range containers {
+		_, parentRecreated := recreated[c.NetworkOf]
+		if c.State != "running" && !parentRecreated {
+			continue
+		}
+		res := updateResult{Name: c.Name, Image: c.Image}
+		if !a.cfg.ContainerManaged(c.Name) {
+			res.Status = "skipped"
+			res.Error = "protected by actions policy"
+			results = append(results, res)
+			continue
+		}
+
+		start := time.Now()
+		if c.State != "running" {
+			res = a.repointStopped(ctx, run, c, recreated)
+		} else {
+			res = a.updateContainer(ctx, run, c, pulled, recreated)
+		}
+		res.Duration = time.Since(start).Seconds()
+		if res.Status == "failed" || res.Status == "rolled-back" {
+			failed++
+			run.Logf("%s: %s: %s", c.Name, res.Status, res.Error)
+		} else {
+			run.Logf("%s: %s", c.Name, res.Status)
+		}
+		results = append(results, res)
+	}
+
+	pruned := a.pruneImages(ctx)
+	run.Log(pruned)
+	result := map[string]any{"results": results, "pruned": pruned}
+	if failed > 0 {
+		return result, fmt.Errorf("%d containers failed to update", failed)
+	}
+	return result, nil
+}
+
+// updateContainer pulls the image c was created from and recreates c if
+// the image changed or its network parent was recreated, rolling back on
+// failure.
+func (a *Actions) updateContainer(ctx context.Context, run *jobs.Run, c stackContainer, pulled map[string]*imageInspect, recreated map[string]string) updateResult {
+	res := updateResult{Name: c.Name, Image: c.Image}
+	fail := func(err error) updateResult {
+		res.Status = "failed"
+		res.Error = err.Error()
+		return res
+	}
+
+	old, err := a.inspectContainer(ctx, c.ID)
+	if err != nil {
+		return fail(err)
+	}
+	// The list API shows a bare image ID once the tag has moved on; the
+	// reference the container was created from is in its config.
+	if ref, _ := old.Config["Image"].(string); ref != "" {
+		res.Image = ref
+	}
+	oldImg, err := a.inspectImage(ctx, old.Image)
+	if err != nil {
+		return fail(err)
+	}
+	img, ok := pulled[res.Image]
+	if !ok {
+		run.Logf("pulling %s", res.Image)
+		if img, err = a.pullImage(ctx, res.Image); err != nil {
+			return fail(fmt.Errorf("pull: %w", err))
+		}
+		pulled[res.Image] = img
+	}
+	res.OldDigest = imageDigest(oldImg)
+	res.NewDigest = imageDigest(img)
+
+	parentID, parentRecreated := recreated[c.NetworkOf]
+	if old.Image == img.ID && !parentRecreated {
+		res.Status = "unchanged"
+		return res
+	}
+
+	run.Logf("recreating %s (%.19s -> %.19s)", c.Name, res.OldDigest, res.NewDigest)
+	networkMode := ""
+	if parentRecreated {
+		networkMode = "container:" + parentID
+	}
+	newID, err := a.recreate(ctx, old, oldImg, networkMode)
+	switch {
+	case err == nil && old.Image == img.ID:
+		res.Status = "recreated"
+	case err == nil:
+		res.Status = "updated"
+	case newID == "":
+		// The old container was restarted under its own ID, but with a
+		// new network namespace that its dependents must join again.
+		recreated[c.Name] = old.ID
+		return fail(err)
+	default:
+		res.Status = "rolled-back"
+		res.Error = err.Error()
+		if rbErr := a.rollback(ctx, old, newID); rbErr != nil {
+			res.Status = "failed"
+			res.Error += "; rollback: " + rbErr.Error()
+			return res
+		}
+		recreated[c.Name] = old.ID
+		return res
+	}
+	recreated[c.Name] = newID
+	return res
+}
+
+// repointStopped recreates a stopped container whose network parent was
+// recreated or restored, without starting it, so that it references the
+// parent's current ID instead of a removed container.
+func (a *Actions) repointStopped(ctx context.Context, run *jobs.Run, c stackContainer, recreated map[string]string) updateResult {
+	res := updateResult{Name: c.Name, Image: c.Image}
+	fail := func(err error) updateResult {
+		res.Status = "failed"
+		res.Error = err.Error()
+		return res
+	}
+
+	old, err := a.inspectContainer(ctx, c.ID)
+	if err != nil {
+		return fail(err)
+	}
+	if ref, _ := old.Config["Image"].(string); ref != "" {
+		res.Image = ref
+	}
+	oldImg, err := a.inspectImage(ctx, old.Image)
+	if err != nil {
+		return fail(err)
+	}
+	res.OldDigest = imageDigest(oldImg)
+	res.NewDigest = res.OldDigest
+
+	parentID := recreated[c.NetworkOf]
+	run.Logf("recreating stopped %s in the network namespace of %s", c.Name, c.NetworkOf)
+	body := createBody(old, oldImg, "container:"+parentID)
+
+	name := strings.TrimPrefix(old.Name, "/")
+	backup := name + "-arcticmon-old"
+	if err := a.dockerCall(ctx, "POST",
+		"/containers/"+old.ID+"/rename?name="+url.QueryEscape(backup), nil, nil); err != nil {
+		return fail(fmt.Errorf("rename: %w", err))
+	}
+	if err := a.dockerCall(ctx, "POST",
+		"/containers/create?name="+url.QueryEscape(name), body, nil); err != nil {
+		a.dockerCall(ctx, "POST",
+			"/containers/"+old.ID+"/rename?name="+url.QueryEscape(name), nil, nil)
+		return fail(fmt.Errorf("create: %w", err))
+	}
+	a.dockerCall(ctx, "DELETE", "/containers/"+old.ID, nil, nil)
+	res.Status = "recreated"
+	return res
+}
+
+// recreate renames and stops the old container, then creates and starts a
+// replacement with the same configuration from its image reference, which
+// now resolves to the pulled image. It returns the new
+// container ID once created; an error with a non-empty ID means the new
+// container must be rolled back.
+func (a *Actions) recreate(ctx context.Context, old *containerInspect, oldImg *imageInspect, networkMode string) (string, error) {
+	name := strings.TrimPrefix(old.Name, "/")
+	backup := name + "-arcticmon-old"
+
+	body := createBody(old, oldImg, networkMode)
+
+	if err := a.dockerCall(ctx, "POST",
+		"/containers/"+old.ID+"/rename?name="+url.QueryEscape(backup), nil, nil); err != nil {
+		return "", fmt.Errorf("rename: %w", err)
+	}
+	if err := a.dockerCall(ctx, "POST", "/containers/"+old.ID+"/stop?t=10", nil, nil); err != nil {
+		a.restoreOld(ctx, old)
+		return "", fmt.Errorf("stop: %w", err)
+	}
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := a.dockerCall(ctx, "POST",
+		"/containers/create?name="+url.QueryEscape(name), body, &created); err != nil {
+		a.restoreOld(ctx, old)
+		return "", fmt.Errorf("create: %w", err)
+	}
+	if err := a.dockerCall(ctx, "POST", "/containers/"+created.ID+"/start", nil, nil); err != nil {
+		return created.ID, fmt.Errorf("start: %w", err)
+	}
+	if err := a.waitHealthy(ctx, created.ID); err != nil {
+		return created.ID, err
+	}
+
+	// The update succeeded; if removal fails the stopped backup is merely
+	// left behind.
+	a.dockerCall(ctx, "DELETE", "/containers/"+old.ID, nil, nil)
+	return created.ID, nil
+}
+
+// rollback removes the failed replacement and brings the old container back.
+func (a *Actions) rollback(ctx context.Context, old *containerInspect, newID string) error {
+	if err := a.dockerCall(ctx, "DELETE", "/containers/"+newID+"?force=1", nil, nil); err != nil {
+		return fmt.Errorf("remove new container: %w", err)
+	}
+	return a.restoreOld(ctx, old)
+}
+
+// restoreOld gives the old container back its name and starts it.
+func (a *Actions) restoreOld(ctx context.Context, old *containerInspect) error {
+	name := strings.TrimPrefix(old.Name, "/")
+	if err := a.dockerCall(ctx, "POST",
+		"/containers/"+old.ID+"/rename?name="+url.QueryEscape(name), nil, nil); err != nil {
+		return fmt.Errorf("rename: %w", err)
+	}
+	if err := a.dockerCall(ctx, "POST", "/containers/"+old.ID+"/start", nil, nil); err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	return nil
+}
+
+// waitHealthy waits until the container's healthcheck reports healthy, or
+// for containers without one, until it has stayed running for settleTime.
+func (a *Actions) waitHealthy(ctx context.Context, id string) error {
+	deadline := time.Now().Add(healthTimeout)
+	var runningSince time.Time
+	for {
+		c, err := a.inspectContainer(ctx, id)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !c.State.Running:
+			return fmt.Errorf("container %s after start", c.State.Status)
+		case c.State.Health != nil:
+			switch c.State.Health.Status {
+			case "healthy":
+				return nil
+			case "unhealthy":
+				return fmt.Errorf("healthcheck failed")
+			}
+		default:
+			if runningSince.IsZero() {
+				runningSince = time.Now()
+			} else if time.Since(runningSince) >= settleTime {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not healthy after %s", healthTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// createBody builds the /containers/create request from the inspected
+// container. Settings equal to the old image's defaults (env, labels,
+// command...) are dropped so the new image's defaults apply.
+func createBody(old *containerInspect, oldImg *imageInspect, networkMode string) map[string]any {
+	cfg := map[string]any{}
+	for k, v := range old.Config {
+		cfg[k] = v
+	}
+	for _, key := range []string{"Cmd", "Entrypoint", "WorkingDir", "User", "Healthcheck", "StopSignal"} {
+		if v, ok := cfg[key]; ok && reflect.DeepEqual(v, oldImg.Config[key]) {
+			delete(cfg, key)
+		}
+	}
+	cfg["Env"] = subtractList(cfg["Env"], oldImg.Config["Env"])
+	cfg["Labels"] = subtractMap(cfg["Labels"], oldImg.Config["Labels"])
+	cfg["Image"] = old.Config["Image"]
+	// Docker defaults the hostname to the short container ID; keep it only
+	// if it was set explicitly.
+	if h, _ := cfg["Hostname"].(string); h != "" && strings.HasPrefix(old.ID, h) {
+		delete(cfg, "Hostname")
+	}
+
+	host := map[string]any{}
+	for k, v := range old.HostConfig {
+		host[k] = v
+	}
+	if networkMode != "" {
+		host["NetworkMode"] = networkMode
+	}
+	mode, _ := host["NetworkMode"].(string)
+	sharedNet := strings.HasPrefix(mode, "container:")
+	if sharedNet {
+		// Hostname and ports belong to the namespace owner.
+		delete(cfg, "Hostname")
+		delete(cfg, "ExposedPorts")
+	}
+	cfg["HostConfig"] = host
+
+	endpoints := map[string]any{}
+	for name, n := range old.NetworkSettings.Networks {
+		var aliases []string
+		for _, alias := range n.Aliases {
+			if !strings.HasPrefix(old.ID, alias) {
+				aliases = append(aliases, alias)
+			}
+		}
+		endpoints[name] = map[string]any{
+			"Aliases":    aliases,
+			"IPAMConfig": n.IPAMConfig,
+			"Links":      n.Links,
+			"DriverOpts": n.DriverOpts,
+		}
+	}
+	if len(endpoints) > 0 && !sharedNet {
+		cfg["NetworkingConfig"] = map[string]any{"EndpointsConfig": endpoints}
+	}
+	return cfg
+}
+
+func subtractList(v, base any) any {
+	list, _ := v.([]any)
+	baseList, _ := base.([]any)
+	if list == nil {
+		return v
+	}
+	inBase := map[any]bool{}
+	for _, e := range baseList {
+		inBase[e] = true
+	}
+	out := []any{}
+	for _, e := range list {
+		if !inBase[e] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func subtractMap(v, base any) any {
+	m, _ := v.(map[string]any)
+	baseMap, _ := base.(map[string]any)
+	if m == nil {
+		return v
+	}
+	out := map[string]any{}
+	for k, val := range m {
+		if bv, ok := baseMap[k]; !ok || bv != val {
+			out[k] = val
+		}
+	}
+	return out
+}
+
+// pullImage pulls ref and returns the resulting local image. Pull errors
+// are reported inside the 200 progress stream, so every message is read.
+func (a *Actions) pullImage(ctx context.Context, ref string) (*imageInspect, error) {
+	if strings.Contains(ref, "@") {
+		// Pinned by digest: nothing to pull.
+		return a.inspectImage(ctx, ref)
+	}
+	repo, tag := ref, "latest"
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		repo, tag = ref[:i], ref[i+1:]
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST",
+		"http://docker/images/create?fromImage="+url.QueryEscape(repo)+"&tag="+url.QueryEscape(tag), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.stream.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, dockerError(resp)
+	}
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if msg.Error != "" {
+			return nil, fmt.Errorf("%s", msg.Error)
+		}
+	}
+	return a.inspectImage(ctx, ref)
+}
+
+// pruneImages removes unused images left behind by the update.
+func (a *Actions) pruneImages(ctx context.Context) string {
+	var result struct {
+		SpaceReclaimed uint64 `json:"SpaceReclaimed"`
+	}
+	filters := url.QueryEscape(`{"dangling":["false"]}`)
+	if err := a.dockerCall(ctx, "POST", "/images/prune?filters="+filters, nil, &result); err != nil {
+		return "Prune failed: " + err.Error()
+	}
+	if result.SpaceReclaimed == 0 {
+		return "No unused images to prune"
+	}
+	return fmt.Sprintf("Pruned %d MB of unused images", result.SpaceReclaimed/(1024*1024))
+}
+
+func (a *Actions) inspectContainer(ctx context.Context, id string) (*containerInspect, error) {
+	var c containerInspect
+	if err := a.dockerCall(ctx, "GET", "/containers/"+id+"/json", nil, &c); err != nil {
+		return nil, fmt.Errorf("inspect container: %w", err)
+	}
+	return &c, nil
+}
+
+func (a *Actions) inspectImage(ctx context.Context, ref string) (*imageInspect, error) {
+	var img imageInspect
+	if err := a.dockerCall(ctx, "GET", "/images/"+ref+"/json", nil, &img); err != nil {
+		return nil, fmt.Errorf("inspect image: %w", err)
+	}
+	return &img, nil
+}
+
+// imageDigest returns the registry digest of an image, falling back to
+// its local ID for images that were never pulled.
+func imageDigest(img *imageInspect) string {
+	for _, d := range img.RepoDigests {
+		if _, digest, ok := strings.Cut(d, "@"); ok {
+			return digest
+		}
+	}
+	return img.ID
+}
+
+// dockerCall sends a JSON request to the Docker API and decodes the
+// response into out (if non-nil). Non-2xx responses become errors carrying
+// Docker's message.
+func (a *Actions) dockerCall(ctx context.Context, method, path string, body, out any) error {
+	var rd io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, "http://docker"+path, rd)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := a.docker.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified {
+		return dockerError(resp)
+	}
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+func dockerError(resp *http.Response) error {
+	var msg struct {
+		Message string `json:"message"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&msg)
+	if msg.Message != "" {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, msg.Message)
+	}
+	return fmt.Errorf("status %d", resp.StatusCode)
+}
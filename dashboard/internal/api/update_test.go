@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCreateBody(t *testing.T) {
+	var img imageInspect
+	mustUnmarshal(t, `{
+		"Id": "sha256:old",
+		"Config": {
+			"Cmd": ["/init"],
+			"Entrypoint": null,
+			"WorkingDir": "/app",
+			"User": "",
+			"Env": ["PATH=/usr/bin", "LANG=C.UTF-8"],
+			"Labels": {"org.opencontainers.image.version": "1.0", "maintainer": "upstream"}
+		}
+	}`, &img)
+
+	tests := []struct {
+		name        string
+		container   string
+		networkMode string
+		check       func(t *testing.T, body map[string]any)
+	}{
+		{
+			name: "image defaults are left to the new image",
+			container: `{
+				"Id": "0123456789abcdef",
+				"Name": "/sonarr",
+				"Image": "sha256:old",
+				"Config": {
+					"Image": "lscr.io/linuxserver/sonarr:latest",
+					"Hostname": "0123456789ab",
+					"Cmd": ["/init"],
+					"WorkingDir": "/config",
+					"User": "",
+					"Env": ["PATH=/usr/bin", "LANG=C.UTF-8", "PUID=1000", "TZ=Europe/Paris"],
+					"Labels": {"org.opencontainers.image.version": "1.0", "maintainer": "compose", "com.docker.compose.service": "sonarr"},
+					"ExposedPorts": {"8989/tcp": {}}
+				},
+				"HostConfig": {"NetworkMode": "mediaserver_medianet", "RestartPolicy": {"Name": "unless-stopped"}},
+				"NetworkSettings": {"Networks": {"mediaserver_medianet": {"Aliases": ["sonarr", "0123456789ab"], "IPAMConfig": null}}}
+			}`,
+			check: func(t *testing.T, body map[string]any) {
+				for _, key := range []string{"Cmd", "User", "Hostname"} {
+					if _, ok := body[key]; ok {
+						t.Errorf("%s = %v, want it left to the image", key, body[key])
+					}
+				}
+				want := map[string]any{
+					"Image":      "lscr.io/linuxserver/sonarr:latest",
+					"WorkingDir": "/config",
+					"Env":        []any{"PUID=1000", "TZ=Europe/Paris"},
+					"Labels":     map[string]any{"maintainer": "compose", "com.docker.compose.service": "sonarr"},
+				}
+				for key, v := range want {
+					if !reflect.DeepEqual(body[key], v) {
+						t.Errorf("%s = %#v, want %#v", key, body[key], v)
+					}
+				}
+				if _, ok := body["ExposedPorts"]; !ok {
+					t.Error("ExposedPorts dropped")
+				}
+				host := body["HostConfig"].(map[string]any)
+				if host["NetworkMode"] != "mediaserver_medianet" {
+					t.Errorf("NetworkMode = %v", host["NetworkMode"])
+				}
+				endpoints := body["NetworkingConfig"].(map[string]any)["EndpointsConfig"].(map[string]any)
+				aliases := endpoints["mediaserver_medianet"].(map[string]any)["Aliases"]
+				if !reflect.DeepEqual(aliases, []string{"sonarr"}) {
+					t.Errorf("Aliases = %v, want the short ID alias dropped", aliases)
+				}
+			},
+		},
+		{
+			name: "explicit hostname is kept",
+			container: `{
+				"Id": "0123456789abcdef",
+				"Config": {"Image": "pihole/pihole", "Hostname": "pihole", "Env": null},
+				"HostConfig": {"NetworkMode": "host"}
+			}`,
+			check: func(t *testing.T, body map[string]any) {
+				if body["Hostname"] != "pihole" {
+					t.Errorf("Hostname = %v, want pihole", body["Hostname"])
+				}
+				if body["Env"] != nil {
+					t.Errorf("Env = %v, want nil", body["Env"])
+				}
+				if _, ok := body["NetworkingConfig"]; ok {
+					t.Error("NetworkingConfig set without networks")
+				}
+			},
+		},
+		{
+			name: "network parent recreated",
+			container: `{
+				"Id": "fedcba9876543210",
+				"Config": {"Image": "qbittorrent", "Hostname": "fedcba987654", "ExposedPorts": {"8080/tcp": {}}},
+				"HostConfig": {"NetworkMode": "container:oldgluetun"},
+				"NetworkSettings": {"Networks": {}}
+			}`,
+			networkMode: "container:newgluetun",
+			check: func(t *testing.T, body map[string]any) {
+				host := body["HostConfig"].(map[string]any)
+				if host["NetworkMode"] != "container:newgluetun" {
+					t.Errorf("NetworkMode = %v, want container:newgluetun", host["NetworkMode"])
+				}
+				for _, key := range []string{"Hostname", "ExposedPorts", "NetworkingConfig"} {
+					if _, ok := body[key]; ok {
+						t.Errorf("%s set in a shared network namespace", key)
+					}
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var old containerInspect
+			mustUnmarshal(t, tt.container, &old)
+			tt.check(t, createBody(&old, &img, tt.networkMode))
+		})
+	}
+}
+
+func mustUnmarshal(t *testing.T, s string, v any) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(s), v); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,218 @@
+// Package audit keeps an append-only, size-rotated JSON-lines log of
+// mutating API requests and action outcomes.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxSize is the size at which the active file is rotated.
+	DefaultMaxSize = 10 << 20
+	// DefaultMaxFiles is the number of rotated files kept besides the
+	// active one.
+	DefaultMaxFiles = 5
+)
+
+// Entry is a single audit record.
+type Entry struct {
+	Time       time.Time         `json:"time"`
+	User       string            `json:"user,omitempty"`
+	Token      string            `json:"token,omitempty"`
+	IP         string            `json:"ip,omitempty"`
+	Method     string            `json:"method,omitempty"`
+	Path       string            `json:"path,omitempty"`
+	Action     string            `json:"action"`
+	Params     map[string]string `json:"params,omitempty"`
+	Status     int               `json:"status,omitempty"`
+	Outcome    string            `json:"outcome"`
+	Error      string            `json:"error,omitempty"`
+	JobID      string            `json:"jobId,omitempty"`
+	Results    any               `json:"results,omitempty"`
+	DurationMs float64           `json:"durationMs,omitempty"`
+}
+
+// Filter selects entries in Query. Zero fields match everything; Action
+// matches as a substring.
+type Filter struct {
+	User   string
+	Action string
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
+
+// Log appends entries to path, rotating it to path.1 ... path.N.
+type Log struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// Open opens (or creates) the audit log at path for appending.
+func Open(path string, maxSize int64, maxFiles int) (*Log, error) {
+	l := &Log{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := l.openLocked(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) openLocked() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size = f, st.Size()
+	return nil
+}
+
+// Append writes e, filling in the time if unset, and syncs it to disk.
+func (l *Log) Append(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotateLocked(); err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+	}
+	n, err := l.f.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return l.f.Sync()
+}
+
+// rotateLocked shifts path.i to path.i+1, dropping the oldest, and starts a
+// new active file.
+func (l *Log) rotateLocked() error {
+	l.f.Close()
+	os.Remove(fmt.Sprintf("%s.%d", l.path, l.maxFiles))
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	if l.maxFiles > 0 {
+		if err := os.Rename(l.path, l.path+".1"); err != nil {
+			return err
+		}
+	} else {
+		os.Remove(l.path)
+	}
+	return l.openLocked()
+}
+
+// Query returns matching entries, newest first, across the active and
+// rotated files.
+func (l *Log) Query(f Filter) ([]Entry, error) {
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+
+	// Open the files under the lock only: the handles keep reading the
+	// same data if a rotation renames or removes them mid-scan, so writers
+	// are not blocked while the files are read.
+	var files []*os.File
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	l.mu.Lock()
+	for i := 0; i <= l.maxFiles; i++ {
+		name := l.path
+		if i > 0 {
+			name = fmt.Sprintf("%s.%d", l.path, i)
+		}
+		file, err := os.Open(name)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			l.mu.Unlock()
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	l.mu.Unlock()
+
+	var out []Entry
+	// Files are newest first; entries within a file are oldest first.
+	for _, file := range files {
+		entries, older, err := readFile(file, f)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(entries) - 1; i >= 0; i-- {
+			out = append(out, entries[i])
+			if len(out) >= f.Limit {
+				return out, nil
+			}
+		}
+		// Rotated files only hold older entries.
+		if older {
+			break
+		}
+	}
+	return out, nil
+}
+
+// readFile returns the entries of one file matching f, oldest first, and
+// whether the file reaches back before f.From.
+func readFile(file *os.File, f Filter) (out []Entry, older bool, err error) {
+	sc := bufio.NewScanner(file)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		var e Entry
+		if json.Unmarshal(sc.Bytes(), &e) != nil {
+			continue
+		}
+		if f.User != "" && e.User != f.User {
+			continue
+		}
+		if f.Action != "" && !strings.Contains(e.Action, f.Action) {
+			continue
+		}
+		if !f.From.IsZero() && e.Time.Before(f.From) {
+			older = true
+			continue
+		}
+		if !f.To.IsZero() && e.Time.After(f.To) {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out, older, sc.Err()
+}
+
+// Close closes the active file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRotationAndQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	// Each entry is about 90 bytes, so a file holds two.
+	l, err := Open(path, 200, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		user := "alice"
+		if i%2 == 1 {
+			user = "bob"
+		}
+		err := l.Append(Entry{
+			Time:    base.Add(time.Duration(i) * time.Minute),
+			User:    user,
+			Action:  fmt.Sprintf("restart-%02d", i),
+			Outcome: "ok",
+		})
+		if err != nil {
+			t.Fatalf("Append %d = %v", i, err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("%s: %v", filepath.Base(name), err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("audit.log.3 kept beyond maxFiles: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{
+			name:   "all, newest first",
+			filter: Filter{},
+			want:   []string{"restart-19", "restart-18", "restart-17", "restart-16", "restart-15", "restart-14"},
+		},
+		{
+			name:   "limit across files",
+			filter: Filter{Limit: 3},
+			want:   []string{"restart-19", "restart-18", "restart-17"},
+		},
+		{
+			name:   "user",
+			filter: Filter{User: "alice"},
+			want:   []string{"restart-18", "restart-16", "restart-14"},
+		},
+		{
+			name:   "action substring",
+			filter: Filter{Action: "-1"},
+			want:   []string{"restart-19", "restart-18", "restart-17", "restart-16", "restart-15", "restart-14"},
+		},
+		{
+			name:   "action not found",
+			filter: Filter{Action: "update"},
+		},
+		{
+			name:   "time range",
+			filter: Filter{From: base.Add(15 * time.Minute), To: base.Add(17 * time.Minute)},
+			want:   []string{"restart-17", "restart-16", "restart-15"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := l.Query(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Action)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Query(%+v) = %v, want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAppendsToExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	for i := 0; i < 2; i++ {
+		l, err := Open(path, DefaultMaxSize, DefaultMaxFiles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Append(Entry{Action: fmt.Sprint("run-", i), Outcome: "ok"}); err != nil {
+			t.Fatal(err)
+		}
+		l.Close()
+	}
+
+	l, err := Open(path, DefaultMaxSize, DefaultMaxFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	entries, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != "run-1" || entries[0].Time.IsZero() {
+		t.Errorf("Query() = %+v, want both runs, newest first, with times", entries)
+	}
+}
@@ -0,0 +1,83 @@
+// Package auth holds dashboard users, their roles and login sessions.
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Role grants access to a set of routes. Each role includes the
+// permissions of the ones below it.
+type Role string
+
+const (
+	// RoleViewer may read every dashboard view.
+	RoleViewer Role = "viewer"
+	// RoleOperator may also restart, start, stop and update containers
+	// and refresh collectors.
+	RoleOperator Role = "operator"
+	// RoleAdmin may also reboot or upgrade the host, read the audit log
+	// and manage users.
+	RoleAdmin Role = "admin"
+)
+
+var roleRank = map[Role]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// ParseRole validates a role name.
+func ParseRole(s string) (Role, error) {
+	r := Role(s)
+	if _, ok := roleRank[r]; !ok {
+		return "", fmt.Errorf("unknown role %q (viewer, operator or admin)", s)
+	}
+	return r, nil
+}
+
+// Allows reports whether r includes the permissions of required.
+func (r Role) Allows(required Role) bool {
+	return roleRank[r] >= roleRank[required]
+}
+
+// Cap returns r lowered to limit when it is higher.
+func (r Role) Cap(limit Role) Role {
+	if roleRank[r] > roleRank[limit] {
+		return limit
+	}
+	return r
+}
+
+// Identity is the authenticated principal of a request.
+type Identity struct {
+	User string `json:"user"`
+	Role Role   `json:"role"`
+	// Method is how the request authenticated: "session", "basic",
+	// "proxy", "token" or "none" when authentication is disabled.
+	Method string `json:"method"`
+	// Token is the name of the API token used, if any.
+	Token string `json:"token,omitempty"`
+}
+
+// Prefixes of the user names given to single sign-on identities, so that
+// they never match a local account of the same name.
+const (
+	OIDCUserPrefix  = "oidc:"
+	ProxyUserPrefix = "proxy:"
+)
+
+// SingleSignOn reports whether user names a single sign-on identity.
+func SingleSignOn(user string) bool {
+	return strings.HasPrefix(user, OIDCUserPrefix) || strings.HasPrefix(user, ProxyUserPrefix)
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a context carrying id.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity stored by WithIdentity.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
@@ -0,0 +1,448 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// clockSkew is tolerated on ID token expiry and issue times.
+	clockSkew = time.Minute
+	// jwksMinRefresh limits how often an unknown key ID triggers a JWKS
+	// refetch.
+	jwksMinRefresh = time.Minute
+)
+
+// OIDCConfig configures an OpenID Connect relying party.
+type OIDCConfig struct {
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	UsernameClaim string
+	GroupsClaim   string
+}
+
+// OIDC implements the authorization code flow with PKCE against a
+// provider found through OpenID discovery. Discovery and signing keys are
+// fetched lazily and cached.
+type OIDC struct {
+	cfg    OIDCConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	discovery   *oidcDiscovery
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCLogin is the outcome of a successful login.
+type OIDCLogin struct {
+	User   string
+	Groups []string
+}
+
+func NewOIDC(cfg OIDCConfig) *OIDC {
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	return &OIDC{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// NewPKCE returns a random state, nonce and PKCE code verifier.
+func NewPKCE() (state, nonce, verifier string) {
+	return randomString(24), randomString(24), randomString(48)
+}
+
+// AuthURL returns the provider URL the browser is redirected to.
+func (o *OIDC) AuthURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	d, err := o.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {o.cfg.ClientID},
+		"redirect_uri":          {o.cfg.RedirectURL},
+		"scope":                 {strings.Join(o.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange redeems an authorization code, verifies the returned ID token
+// against the expected nonce and extracts the username and groups.
+func (o *OIDC) Exchange(ctx context.Context, code, verifier, nonce string) (OIDCLogin, error) {
+	d, err := o.discover(ctx)
+	if err != nil {
+		return OIDCLogin{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {o.cfg.RedirectURL},
+		"code_verifier": {verifier},
+		"client_id":     {o.cfg.ClientID},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return OIDCLogin{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if o.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(o.cfg.ClientID), url.QueryEscape(o.cfg.ClientSecret))
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return OIDCLogin{}, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	var tok struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tok); err != nil {
+		return OIDCLogin{}, fmt.Errorf("token response: status %d: %w", resp.StatusCode, err)
+	}
+	if tok.Error != "" {
+		return OIDCLogin{}, fmt.Errorf("token request: %s %s", tok.Error, tok.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || tok.IDToken == "" {
+		return OIDCLogin{}, fmt.Errorf("token request: status %d without id_token", resp.StatusCode)
+	}
+
+	claims, err := o.verify(ctx, tok.IDToken, d.Issuer)
+	if err != nil {
+		return OIDCLogin{}, fmt.Errorf("id token: %w", err)
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return OIDCLogin{}, errors.New("id token: nonce mismatch")
+	}
+
+	login := OIDCLogin{User: claimString(claims, o.cfg.UsernameClaim)}
+	if login.User == "" {
+		login.User = claimString(claims, "sub")
+	}
+	switch g := claims[o.cfg.GroupsClaim].(type) {
+	case []any:
+		for _, v := range g {
+			if s, ok := v.(string); ok {
+				login.Groups = append(login.Groups, s)
+			}
+		}
+	case string:
+		login.Groups = strings.Fields(strings.ReplaceAll(g, ",", " "))
+	}
+	return login, nil
+}
+
+// verify checks the signature, issuer, audience and lifetime of a JWT and
+// returns its claims.
+func (o *OIDC) verify(ctx context.Context, token, issuer string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	key, err := o.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("claims: %w", err)
+	}
+	if iss, _ := claims["iss"].(string); iss != issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if !audienceContains(claims["aud"], o.cfg.ClientID) {
+		return nil, errors.New("token not issued for this client")
+	}
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return nil, errors.New("token expired")
+	}
+	if iat, ok := claims["iat"].(float64); ok && time.Unix(int64(iat), 0).After(now.Add(clockSkew)) {
+		return nil, errors.New("token issued in the future")
+	}
+	return claims, nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	var h hash.Hash
+	var ch crypto.Hash
+	switch alg[2:] {
+	case "256":
+		h, ch = sha256.New(), crypto.SHA256
+	case "384":
+		h, ch = sha512.New384(), crypto.SHA384
+	case "512":
+		h, ch = sha512.New(), crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(k, ch, digest, sig)
+		case "PS":
+			return rsa.VerifyPSS(k, ch, digest, sig, nil)
+		}
+	case *ecdsa.PublicKey:
+		if alg[:2] == "ES" && len(sig)%2 == 0 {
+			r := new(big.Int).SetBytes(sig[:len(sig)/2])
+			s := new(big.Int).SetBytes(sig[len(sig)/2:])
+			if ecdsa.Verify(k, digest, r, s) {
+				return nil
+			}
+			return errors.New("invalid signature")
+		}
+	}
+	return fmt.Errorf("algorithm %q does not match the signing key", alg)
+}
+
+// discover fetches and caches the provider metadata.
+func (o *OIDC) discover(ctx context.Context) (*oidcDiscovery, error) {
+	o.mu.Lock()
+	d := o.discovery
+	o.mu.Unlock()
+	if d != nil {
+		return d, nil
+	}
+
+	d = &oidcDiscovery{}
+	if err := o.getJSON(ctx, o.cfg.Issuer+"/.well-known/openid-configuration", d); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != o.cfg.Issuer {
+		return nil, fmt.Errorf("discovery: issuer %q does not match %q", d.Issuer, o.cfg.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("discovery: incomplete provider metadata")
+	}
+	o.mu.Lock()
+	o.discovery = d
+	o.mu.Unlock()
+	return d, nil
+}
+
+// key returns the signing key kid, refetching the JWKS when it is unknown
+// (the provider rotated its keys).
+func (o *OIDC) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	o.mu.Lock()
+	k, ok := o.lookupKeyLocked(kid)
+	stale := time.Since(o.keysFetched) > jwksMinRefresh
+	o.mu.Unlock()
+	if ok {
+		return k, nil
+	}
+	if !stale {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	d, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := o.getJSON(ctx, d.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, j := range set.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		if pk, err := j.publicKey(); err == nil {
+			keys[j.Kid] = pk
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.keys, o.keysFetched = keys, time.Now()
+	if k, ok := o.lookupKeyLocked(kid); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKeyLocked finds kid; a token without kid matches a single key.
+func (o *OIDC) lookupKeyLocked(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(o.keys) == 1 {
+		for _, k := range o.keys {
+			return k, true
+		}
+	}
+	k, ok := o.keys[kid]
+	return k, ok
+}
+
+func (o *OIDC) getJSON(ctx context.Context, u string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: status %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+// jwk is a JSON Web Key (RSA or EC public key).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (j jwk) publicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err1 := base64.RawURLEncoding.DecodeString(j.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(j.E)
+		if err1 != nil || err2 != nil || len(e) > 4 {
+			return nil, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err1 := base64.RawURLEncoding.DecodeString(j.X)
+		y, err2 := base64.RawURLEncoding.DecodeString(j.Y)
+		if err1 != nil || err2 != nil {
+			return nil, errors.New("invalid EC key")
+		}
+		pk := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pk.X, pk.Y) {
+			return nil, errors.New("invalid EC key")
+		}
+		return pk, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", j.Kty)
+}
+
+// RoleForGroups returns the highest role mapped from groups, or
+// defaultRole when none matches. ok is false if the result is no role.
+func RoleForGroups(groups []string, mapping map[string]string, defaultRole string) (Role, bool) {
+	best := Role(defaultRole)
+	for _, g := range groups {
+		if r, ok := mapping[g]; ok && roleRank[Role(r)] > roleRank[best] {
+			best = Role(r)
+		}
+	}
+	_, ok := roleRank[best]
+	return best, ok
+}
+
+func audienceContains(aud any, clientID string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == clientID
+	case []any:
+		for _, v := range a {
+			if v == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func claimString(claims map[string]any, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+func decodeSegment(seg string, out any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Session is a logged-in browser session.
+type Session struct {
+	User      string    `json:"user"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Sessions keeps login sessions in memory, keyed by the SHA-256 of their
+// token. Sessions do not survive a restart.
+type Sessions struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+func NewSessions() *Sessions {
+	return &Sessions{sessions: make(map[string]*Session)}
+}
+
+// Create starts a session for user valid for ttl and returns its token.
+func (s *Sessions) Create(user string, role Role, ttl time.Duration) (string, Session) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	sess := &Session{User: user, Role: role, CreatedAt: now, ExpiresAt: now.Add(ttl)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked(now)
+	s.sessions[tokenKey(token)] = sess
+	return token, *sess
+}
+
+// Lookup returns the live session for token.
+func (s *Sessions) Lookup(token string) (Session, bool) {
+	if token == "" {
+		return Session{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := tokenKey(token)
+	sess, ok := s.sessions[key]
+	if !ok {
+		return Session{}, false
+	}
+	if time.Now().After(sess.ExpiresAt) {
+		delete(s.sessions, key)
+		return Session{}, false
+	}
+	return *sess, true
+}
+
+// Delete ends the session for token.
+func (s *Sessions) Delete(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, tokenKey(token))
+}
+
+// DeleteUser ends all sessions of user, after a password or role change
+// or account removal.
+func (s *Sessions) DeleteUser(user string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, sess := range s.sessions {
+		if sess.User == user {
+			delete(s.sessions, key)
+		}
+	}
+}
+
+func (s *Sessions) expireLocked(now time.Time) {
+	for key, sess := range s.sessions {
+		if now.After(sess.ExpiresAt) {
+			delete(s.sessions, key)
+		}
+	}
+}
+
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
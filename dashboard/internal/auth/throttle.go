@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"arcticmon/internal/models"
+)
+
+const (
+	// freeFailures are allowed before sign-ins from a key are delayed.
+	freeFailures = 3
+	// baseDelay doubles with each further failure, up to maxDelay.
+	baseDelay = time.Second
+	maxDelay  = time.Minute
+	// maxLockouts is how many lockout events are kept for the panel.
+	maxLockouts = 50
+	// maxTracked bounds the number of keys before stale ones are pruned.
+	maxTracked = 4096
+)
+
+// Throttle slows down password guessing. Failed sign-ins are counted per
+// key ("ip" and "user"); after freeFailures each further failure makes the
+// key wait an exponentially growing delay before the next attempt, and
+// after maxFailures the key is locked out. Counters reset after a quiet
+// period as long as the lockout.
+type Throttle struct {
+	maxFailures int
+	lockout     time.Duration
+	// OnChange is called, outside the lock, after failures and lockouts.
+	OnChange func(models.AuthSecurityData)
+	// OnLockout is called, outside the lock, when a key gets locked out.
+	OnLockout func(models.AuthLockout)
+
+	mu       sync.Mutex
+	keys     map[string]*attempts
+	failures []time.Time // failed sign-ins of the last 24h
+	lockouts []models.AuthLockout
+}
+
+type attempts struct {
+	kind, key   string
+	failures    int
+	last        time.Time
+	notBefore   time.Time
+	lockedUntil time.Time
+}
+
+// ThrottleKey identifies what a sign-in attempt is counted against.
+type ThrottleKey struct {
+	Kind string // "ip" or "user"
+	Key  string
+}
+
+// NewThrottle locks keys out for lockout after maxFailures failures.
+func NewThrottle(maxFailures int, lockout time.Duration) *Throttle {
+	return &Throttle{maxFailures: maxFailures, lockout: lockout, keys: make(map[string]*attempts)}
+}
+
+// Check returns how long the caller must wait before trying again; zero
+// means the attempt may proceed. locked is true during a lockout.
+func (t *Throttle) Check(keys ...ThrottleKey) (wait time.Duration, locked bool) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, k := range keys {
+		a := t.keys[k.Kind+":"+k.Key]
+		if a == nil {
+			continue
+		}
+		t.expireLocked(a, now)
+		if d := a.lockedUntil.Sub(now); d > 0 {
+			wait, locked = max(wait, d), true
+		}
+		if d := a.notBefore.Sub(now); d > 0 {
+			wait = max(wait, d)
+		}
+	}
+	return wait, locked
+}
+
+// Fail records a failed sign-in against every key.
+func (t *Throttle) Fail(keys ...ThrottleKey) {
+	now := time.Now()
+	var locked []models.AuthLockout
+
+	t.mu.Lock()
+	if len(t.keys) > maxTracked {
+		t.pruneLocked(now)
+	}
+	t.failures = append(t.failures, now)
+	for _, k := range keys {
+		id := k.Kind + ":" + k.Key
+		a := t.keys[id]
+		if a == nil {
+			a = &attempts{kind: k.Kind, key: k.Key}
+			t.keys[id] = a
+		}
+		t.expireLocked(a, now)
+		a.failures++
+		a.last = now
+		switch {
+		case a.failures >= t.maxFailures:
+			a.lockedUntil = now.Add(t.lockout)
+			a.notBefore = time.Time{}
+			ev := models.AuthLockout{Time: now, Kind: a.kind, Key: a.key, Failures: a.failures, Until: a.lockedUntil}
+			t.lockouts = append([]models.AuthLockout{ev}, t.lockouts...)
+			if len(t.lockouts) > maxLockouts {
+				t.lockouts = t.lockouts[:maxLockouts]
+			}
+			a.failures = 0
+			locked = append(locked, ev)
+		case a.failures > freeFailures:
+			a.notBefore = now.Add(min(baseDelay<<(a.failures-freeFailures-1), maxDelay))
+		}
+	}
+	snap := t.snapshotLocked(now)
+	t.mu.Unlock()
+
+	if t.OnLockout != nil {
+		for _, ev := range locked {
+			t.OnLockout(ev)
+		}
+	}
+	if t.OnChange != nil {
+		t.OnChange(snap)
+	}
+}
+
+// Succeed clears the failures of keys after a successful sign-in.
+func (t *Throttle) Succeed(keys ...ThrottleKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, k := range keys {
+		id := k.Kind + ":" + k.Key
+		if a := t.keys[id]; a != nil && !time.Now().Before(a.lockedUntil) {
+			delete(t.keys, id)
+		}
+	}
+}
+
+// Snapshot returns failure counts, active lockouts and recent events.
+func (t *Throttle) Snapshot() models.AuthSecurityData {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.snapshotLocked(time.Now())
+}
+
+func (t *Throttle) snapshotLocked(now time.Time) models.AuthSecurityData {
+	cutoff := now.Add(-24 * time.Hour)
+	i := sort.Search(len(t.failures), func(i int) bool { return t.failures[i].After(cutoff) })
+	t.failures = t.failures[i:]
+
+	d := models.AuthSecurityData{
+		Failed24h: len(t.failures),
+		Locked:    []models.AuthLockout{},
+		Lockouts:  append([]models.AuthLockout{}, t.lockouts...),
+	}
+	for _, ev := range t.lockouts {
+		if a := t.keys[ev.Kind+":"+ev.Key]; a != nil && a.lockedUntil.Equal(ev.Until) && now.Before(ev.Until) {
+			d.Locked = append(d.Locked, ev)
+		}
+	}
+	return d
+}
+
+// expireLocked ends a past lockout and forgets failures after a quiet
+// period.
+func (t *Throttle) expireLocked(a *attempts, now time.Time) {
+	if !a.lockedUntil.IsZero() && !now.Before(a.lockedUntil) {
+		a.lockedUntil = time.Time{}
+	}
+	if now.Sub(a.last) > t.lockout {
+		a.failures = 0
+		a.notBefore = time.Time{}
+	}
+}
+
+func (t *Throttle) pruneLocked(now time.Time) {
+	for id, a := range t.keys {
+		if now.Sub(a.last) > t.lockout && !now.Before(a.lockedUntil) {
+			delete(t.keys, id)
+		}
+	}
+}
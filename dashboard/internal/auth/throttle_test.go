@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"arcticmon/internal/models"
+)
+
+func TestThrottleDelays(t *testing.T) {
+	const lockout = time.Hour
+	ip := ThrottleKey{Kind: "ip", Key: "192.0.2.1"}
+	tests := []struct {
+		failures int
+		wait     time.Duration
+		locked   bool
+	}{
+		{failures: 1, wait: 0},
+		{failures: freeFailures, wait: 0},
+		{failures: freeFailures + 1, wait: baseDelay},
+		{failures: freeFailures + 2, wait: 2 * baseDelay},
+		{failures: freeFailures + 3, wait: 4 * baseDelay},
+		{failures: 10, wait: lockout, locked: true},
+	}
+	for _, tt := range tests {
+		th := NewThrottle(10, lockout)
+		for i := 0; i < tt.failures; i++ {
+			th.Fail(ip)
+		}
+		wait, locked := th.Check(ip)
+		if locked != tt.locked || wait > tt.wait || wait < tt.wait-time.Second/2 {
+			t.Errorf("after %d failures: Check() = %s, %v, want %s, %v", tt.failures, wait, locked, tt.wait, tt.locked)
+		}
+	}
+
+	// The delay is capped.
+	th := NewThrottle(100, lockout)
+	for i := 0; i < 20; i++ {
+		th.Fail(ip)
+	}
+	if wait, _ := th.Check(ip); wait > maxDelay {
+		t.Errorf("wait %s exceeds maxDelay", wait)
+	}
+}
+
+func TestThrottleKeys(t *testing.T) {
+	ip := ThrottleKey{Kind: "ip", Key: "192.0.2.1"}
+	user := ThrottleKey{Kind: "user", Key: "alice"}
+	other := ThrottleKey{Kind: "ip", Key: "192.0.2.2"}
+
+	th := NewThrottle(5, time.Hour)
+	var lockouts []models.AuthLockout
+	th.OnLockout = func(ev models.AuthLockout) { lockouts = append(lockouts, ev) }
+	for i := 0; i < 5; i++ {
+		th.Fail(ip, user)
+	}
+	if len(lockouts) != 2 {
+		t.Fatalf("OnLockout called %d times, want once per key", len(lockouts))
+	}
+	if _, locked := th.Check(other); locked {
+		t.Error("an unrelated key is locked")
+	}
+	if _, locked := th.Check(other, user); !locked {
+		t.Error("a locked key does not lock the attempt")
+	}
+
+	// A successful sign-in does not lift a lockout.
+	th.Succeed(ip, user)
+	if _, locked := th.Check(ip); !locked {
+		t.Error("Succeed() lifted a lockout")
+	}
+	snap := th.Snapshot()
+	if snap.Failed24h != 5 || len(snap.Locked) != 2 || len(snap.Lockouts) != 2 {
+		t.Errorf("Snapshot() = %+v", snap)
+	}
+}
+
+func TestThrottleResets(t *testing.T) {
+	ip := ThrottleKey{Kind: "ip", Key: "192.0.2.1"}
+
+	th := NewThrottle(10, time.Hour)
+	for i := 0; i < freeFailures+1; i++ {
+		th.Fail(ip)
+	}
+	th.Succeed(ip)
+	if wait, _ := th.Check(ip); wait != 0 {
+		t.Errorf("wait after Succeed() = %s, want 0", wait)
+	}
+
+	// Lockouts end, and failures are forgotten, after the lockout period.
+	th = NewThrottle(2, 20*time.Millisecond)
+	th.Fail(ip)
+	th.Fail(ip)
+	if _, locked := th.Check(ip); !locked {
+		t.Fatal("not locked after maxFailures")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if wait, locked := th.Check(ip); locked || wait != 0 {
+		t.Errorf("Check() after the lockout = %s, %v", wait, locked)
+	}
+	th.Fail(ip)
+	if _, locked := th.Check(ip); locked {
+		t.Error("failures before the lockout still counted")
+	}
+}
@@ -0,0 +1,317 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TokenPrefix starts every API token so leaked tokens are easy to
+	// recognise and grep for.
+	TokenPrefix = "amt_"
+	// lastUsedPersist throttles writes of token usage to disk.
+	lastUsedPersist = time.Minute
+)
+
+// Token scopes. A scope is "read" (GET and HEAD requests), "actions"
+// (POST to /api/actions/, /api/services/, /api/collectors/ and
+// /api/torrents/), or a
+// route: an optional method followed by a path pattern in path.Match
+// syntax, e.g. "GET /api/torrents" or "POST /api/services/*/restart".
+const (
+	ScopeRead    = "read"
+	ScopeActions = "actions"
+)
+
+// ErrUnknownToken is returned when revoking a missing token.
+var ErrUnknownToken = errors.New("unknown token")
+
+// Token is a named bearer token. Only the SHA-256 of the secret is kept.
+type Token struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Owner      string     `json:"owner"`
+	Role       Role       `json:"role"`
+	Scopes     []string   `json:"scopes"`
+	Hint       string     `json:"hint"` // first characters of the secret
+	Hash       string     `json:"hash,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	LastUsedIP string     `json:"lastUsedIp,omitempty"`
+}
+
+// Expired reports whether the token is past its expiry.
+func (t *Token) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && now.After(*t.ExpiresAt)
+}
+
+// Allows reports whether one of the token's scopes covers a request.
+func (t *Token) Allows(method, urlPath string) bool {
+	for _, s := range t.Scopes {
+		if scopeAllows(s, method, urlPath) {
+			return true
+		}
+	}
+	return false
+}
+
+func scopeAllows(scope, method, urlPath string) bool {
+	switch scope {
+	case ScopeRead:
+		return method == "GET" || method == "HEAD"
+	case ScopeActions:
+		return method == "POST" && (strings.HasPrefix(urlPath, "/api/actions/") ||
+			strings.HasPrefix(urlPath, "/api/services/") ||
+			strings.HasPrefix(urlPath, "/api/collectors/") ||
+			strings.HasPrefix(urlPath, "/api/torrents/"))
+	}
+	m, pattern, ok := strings.Cut(scope, " ")
+	if !ok {
+		m, pattern = "", scope
+	}
+	if m != "" && m != method && !(m == "GET" && method == "HEAD") {
+		return false
+	}
+	matched, _ := path.Match(pattern, urlPath)
+	return matched
+}
+
+// CheckScope validates a scope string.
+func CheckScope(scope string) error {
+	if scope == ScopeRead || scope == ScopeActions {
+		return nil
+	}
+	m, pattern, ok := strings.Cut(scope, " ")
+	if !ok {
+		pattern = scope
+	} else if m != strings.ToUpper(m) || m == "" {
+		return fmt.Errorf("scope %q: method must be upper case", scope)
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("scope %q: expected read, actions or [METHOD] /path/pattern", scope)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("scope %q: %w", scope, err)
+	}
+	return nil
+}
+
+// Tokens is the persisted API token store.
+type Tokens struct {
+	path string
+
+	mu     sync.Mutex
+	tokens []*Token
+	saved  time.Time
+}
+
+// OpenTokens loads the token store from path; a missing file yields an
+// empty store.
+func OpenTokens(path string) (*Tokens, error) {
+	t := &Tokens{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &t.tokens); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return t, nil
+}
+
+// Create issues a token and returns it with its secret, which is not
+// stored and cannot be retrieved again.
+func (t *Tokens) Create(name, owner string, role Role, scopes []string, expiresAt *time.Time) (Token, string, error) {
+	if name == "" {
+		return Token{}, "", errors.New("name is required")
+	}
+	if len(scopes) == 0 {
+		return Token{}, "", errors.New("at least one scope is required")
+	}
+	for _, s := range scopes {
+		if err := CheckScope(s); err != nil {
+			return Token{}, "", err
+		}
+	}
+
+	secret := TokenPrefix + randomString(32)
+	tok := &Token{
+		ID:        randomString(9),
+		Name:      name,
+		Owner:     owner,
+		Role:      role,
+		Scopes:    scopes,
+		Hint:      secret[:len(TokenPrefix)+4],
+		Hash:      hashToken(secret),
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tokens = append(t.tokens, tok)
+	if err := t.saveLocked(); err != nil {
+		t.tokens = t.tokens[:len(t.tokens)-1]
+		return Token{}, "", err
+	}
+	return tok.public(), secret, nil
+}
+
+// Authenticate looks up a secret and records its use from ip.
+func (t *Tokens) Authenticate(secret, ip string) (Token, bool) {
+	if !strings.HasPrefix(secret, TokenPrefix) {
+		return Token{}, false
+	}
+	h := hashToken(secret)
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, tok := range t.tokens {
+		if subtle.ConstantTimeCompare([]byte(tok.Hash), []byte(h)) != 1 {
+			continue
+		}
+		if tok.Expired(now) {
+			return Token{}, false
+		}
+		tok.LastUsedAt = &now
+		tok.LastUsedIP = ip
+		if now.Sub(t.saved) > lastUsedPersist {
+			t.saveLocked()
+		}
+		return tok.public(), true
+	}
+	return Token{}, false
+}
+
+// List returns the tokens of owner (all tokens if owner is empty), newest
+// first, without hashes.
+func (t *Tokens) List(owner string) []Token {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := []Token{}
+	for i := len(t.tokens) - 1; i >= 0; i-- {
+		if owner == "" || t.tokens[i].Owner == owner {
+			out = append(out, t.tokens[i].public())
+		}
+	}
+	return out
+}
+
+// Get returns a token without its hash.
+func (t *Tokens) Get(id string) (Token, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, tok := range t.tokens {
+		if tok.ID == id {
+			return tok.public(), true
+		}
+	}
+	return Token{}, false
+}
+
+// Revoke deletes a token.
+func (t *Tokens) Revoke(id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, tok := range t.tokens {
+		if tok.ID == id {
+			t.tokens = append(t.tokens[:i:i], t.tokens[i+1:]...)
+			return t.saveLocked()
+		}
+	}
+	return ErrUnknownToken
+}
+
+// RevokeOwner deletes every token of owner, when the account is removed.
+func (t *Tokens) RevokeOwner(owner string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	kept := t.tokens[:0:0]
+	for _, tok := range t.tokens {
+		if tok.Owner != owner {
+			kept = append(kept, tok)
+		}
+	}
+	if len(kept) == len(t.tokens) {
+		return nil
+	}
+	t.tokens = kept
+	return t.saveLocked()
+}
+
+// CapOwner lowers the role of owner's tokens to role, or revokes them when
+// role is empty, after the owner lost privileges.
+func (t *Tokens) CapOwner(owner string, role Role) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	kept := t.tokens[:0:0]
+	changed := false
+	for _, tok := range t.tokens {
+		if tok.Owner == owner {
+			if role == "" {
+				changed = true
+				continue
+			}
+			if capped := tok.Role.Cap(role); capped != tok.Role {
+				tok.Role = capped
+				changed = true
+			}
+		}
+		kept = append(kept, tok)
+	}
+	if !changed {
+		return nil
+	}
+	t.tokens = kept
+	return t.saveLocked()
+}
+
+// Flush persists pending last-used updates.
+func (t *Tokens) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.saveLocked()
+}
+
+func (t *Tokens) saveLocked() error {
+	sort.SliceStable(t.tokens, func(i, j int) bool { return t.tokens[i].CreatedAt.Before(t.tokens[j].CreatedAt) })
+	data, err := json.MarshalIndent(t.tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		return err
+	}
+	t.saved = time.Now()
+	return nil
+}
+
+func (tok *Token) public() Token {
+	p := *tok
+	p.Hash = ""
+	p.Scopes = append([]string(nil), tok.Scopes...)
+	return p
+}
+
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScopeAllows(t *testing.T) {
+	tests := []struct {
+		scope, method, path string
+		want                bool
+	}{
+		{ScopeRead, "GET", "/api/services", true},
+		{ScopeRead, "HEAD", "/api/services", true},
+		{ScopeRead, "POST", "/api/services/sonarr/restart", false},
+		{ScopeActions, "POST", "/api/services/sonarr/restart", true},
+		{ScopeActions, "POST", "/api/actions/update-stack", true},
+		{ScopeActions, "POST", "/api/collectors/docker/refresh", true},
+		{ScopeActions, "POST", "/api/torrents/abc/recheck", true},
+		{ScopeActions, "GET", "/api/services", false},
+		{ScopeActions, "POST", "/api/tokens", false},
+		{ScopeActions, "POST", "/api/users", false},
+		{"GET /api/torrents", "GET", "/api/torrents", true},
+		{"GET /api/torrents", "HEAD", "/api/torrents", true},
+		{"GET /api/torrents", "GET", "/api/torrents/abc", false},
+		{"GET /api/torrents", "POST", "/api/torrents", false},
+		{"POST /api/services/*/restart", "POST", "/api/services/sonarr/restart", true},
+		{"POST /api/services/*/restart", "POST", "/api/services/sonarr/stop", false},
+		// path.Match: * does not cross a slash.
+		{"POST /api/services/*", "POST", "/api/services/sonarr/restart", false},
+		{"/api/history/*", "GET", "/api/history/host", true},
+		{"/api/history/*", "DELETE", "/api/history/host", true},
+	}
+	for _, tt := range tests {
+		if got := scopeAllows(tt.scope, tt.method, tt.path); got != tt.want {
+			t.Errorf("scopeAllows(%q, %s %s) = %v, want %v", tt.scope, tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCheckScope(t *testing.T) {
+	tests := []struct {
+		scope string
+		ok    bool
+	}{
+		{ScopeRead, true},
+		{ScopeActions, true},
+		{"GET /api/torrents", true},
+		{"/api/services/*/logs", true},
+		{"get /api/torrents", false},
+		{"write", false},
+		{"GET api/torrents", false},
+		{"GET /api/[", false},
+	}
+	for _, tt := range tests {
+		if err := CheckScope(tt.scope); (err == nil) != tt.ok {
+			t.Errorf("CheckScope(%q) = %v, want ok %v", tt.scope, err, tt.ok)
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	store, err := OpenTokens(filepath.Join(t.TempDir(), "tokens.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Minute)
+
+	if _, _, err := store.Create("ci", "alice", RoleOperator, nil, nil); err == nil {
+		t.Error("Create() without scopes succeeded")
+	}
+	if _, _, err := store.Create("ci", "alice", RoleOperator, []string{"bogus"}, nil); err == nil {
+		t.Error("Create() with an invalid scope succeeded")
+	}
+	tok, secret, err := store.Create("ci", "alice", RoleOperator, []string{ScopeActions}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, expiredSecret, err := store.Create("old", "alice", RoleOperator, []string{ScopeRead}, &past)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Hash != "" {
+		t.Error("Create() returned the hash")
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		ok     bool
+	}{
+		{"valid", secret, true},
+		{"expired", expiredSecret, false},
+		{"wrong secret", secret + "x", false},
+		{"missing prefix", secret[len(TokenPrefix):], false},
+	}
+	for _, tt := range tests {
+		got, ok := store.Authenticate(tt.secret, "192.0.2.1")
+		if ok != tt.ok {
+			t.Errorf("%s: Authenticate() ok = %v, want %v", tt.name, ok, tt.ok)
+		}
+		if ok && (got.ID != tok.ID || got.LastUsedIP != "192.0.2.1") {
+			t.Errorf("%s: Authenticate() = %+v", tt.name, got)
+		}
+	}
+
+	// Demoting the owner lowers the token; removing access revokes it.
+	if err := store.CapOwner("alice", RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.Get(tok.ID); got.Role != RoleViewer {
+		t.Errorf("role after CapOwner(viewer) = %s", got.Role)
+	}
+	if err := store.CapOwner("alice", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.Get(tok.ID); got.Role != RoleViewer {
+		t.Errorf("CapOwner(admin) raised the role to %s", got.Role)
+	}
+	if err := store.CapOwner("alice", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.List("alice"); len(got) != 0 {
+		t.Errorf("tokens left after CapOwner(\"\"): %+v", got)
+	}
+	if _, ok := store.Authenticate(secret, ""); ok {
+		t.Error("revoked token still authenticates")
+	}
+}
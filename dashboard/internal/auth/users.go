@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// minPasswordLen is the shortest password accepted for new users.
+const minPasswordLen = 8
+
+var (
+	// ErrUnknownUser is returned for operations on a missing user.
+	ErrUnknownUser = errors.New("unknown user")
+	// ErrLastAdmin prevents removing or demoting the only admin.
+	ErrLastAdmin = errors.New("at least one admin is required")
+)
+
+// dummyHash is compared against for unknown users so that login timing
+// does not reveal which usernames exist.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("arcticmon"), bcrypt.DefaultCost)
+
+// User is a dashboard account. PasswordHash is a bcrypt hash and is never
+// exposed by the API.
+type User struct {
+	Name         string    `json:"name"`
+	Role         Role      `json:"role"`
+	PasswordHash string    `json:"passwordHash,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// Users is the persisted user store.
+type Users struct {
+	path string
+
+	mu    sync.Mutex
+	users map[string]*User
+}
+
+// OpenUsers loads the user store from path; a missing file yields an empty
+// store.
+func OpenUsers(path string) (*Users, error) {
+	u := &Users{path: path, users: make(map[string]*User)}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		var list []*User
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, user := range list {
+			u.users[user.Name] = user
+		}
+	}
+	return u, nil
+}
+
+// Len returns the number of users. Authentication is disabled while the
+// store is empty.
+func (u *Users) Len() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.users)
+}
+
+// Authenticate checks a username and password.
+func (u *Users) Authenticate(name, password string) (User, bool) {
+	u.mu.Lock()
+	user, ok := u.users[name]
+	var snap User
+	if ok {
+		snap = *user
+	}
+	u.mu.Unlock()
+
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return User{}, false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(snap.PasswordHash), []byte(password)) != nil {
+		return User{}, false
+	}
+	snap.PasswordHash = ""
+	return snap, true
+}
+
+// Get returns a user without its password hash.
+func (u *Users) Get(name string) (User, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	user, ok := u.users[name]
+	if !ok {
+		return User{}, false
+	}
+	snap := *user
+	snap.PasswordHash = ""
+	return snap, true
+}
+
+// List returns all users sorted by name, without password hashes.
+func (u *Users) List() []User {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	out := make([]User, 0, len(u.users))
+	for _, user := range u.users {
+		snap := *user
+		snap.PasswordHash = ""
+		out = append(out, snap)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Create adds a new user.
+func (u *Users) Create(name, password string, role Role) (User, error) {
+	if name == "" {
+		return User{}, errors.New("username is required")
+	}
+	if strings.Contains(name, ":") {
+		// Reserved for the oidc: and proxy: namespaces of external users.
+		return User{}, errors.New("username must not contain ':'")
+	}
+	if err := checkPassword(password); err != nil {
+		return User{}, err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, exists := u.users[name]; exists {
+		return User{}, fmt.Errorf("user %s already exists", name)
+	}
+	return u.addLocked(name, hash, role)
+}
+
+// Seed creates an admin from the legacy DASHBOARD_USER/DASHBOARD_PASS
+// credentials while the store is empty, so existing setups keep working.
+// It reports whether the user was created.
+func (u *Users) Seed(name, password string) (bool, error) {
+	if name == "" || password == "" || u.Len() > 0 {
+		return false, nil
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return false, err
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.users) > 0 {
+		return false, nil
+	}
+	_, err = u.addLocked(name, hash, RoleAdmin)
+	return err == nil, err
+}
+
+func (u *Users) addLocked(name, hash string, role Role) (User, error) {
+	now := time.Now()
+	user := &User{Name: name, Role: role, PasswordHash: hash, CreatedAt: now, UpdatedAt: now}
+	u.users[name] = user
+	if err := u.saveLocked(); err != nil {
+		delete(u.users, name)
+		return User{}, err
+	}
+	snap := *user
+	snap.PasswordHash = ""
+	return snap, nil
+}
+
+// Update changes the password and/or role of a user; empty values are left
+// unchanged.
+func (u *Users) Update(name, password string, role Role) (User, error) {
+	var hash string
+	if password != "" {
+		if err := checkPassword(password); err != nil {
+			return User{}, err
+		}
+		var err error
+		if hash, err = hashPassword(password); err != nil {
+			return User{}, err
+		}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	user, ok := u.users[name]
+	if !ok {
+		return User{}, ErrUnknownUser
+	}
+	if role != "" && role != RoleAdmin && user.Role == RoleAdmin && u.adminsLocked() == 1 {
+		return User{}, ErrLastAdmin
+	}
+	prev := *user
+	if hash != "" {
+		user.PasswordHash = hash
+	}
+	if role != "" {
+		user.Role = role
+	}
+	user.UpdatedAt = time.Now()
+	if err := u.saveLocked(); err != nil {
+		*user = prev
+		return User{}, err
+	}
+	snap := *user
+	snap.PasswordHash = ""
+	return snap, nil
+}
+
+// Delete removes a user.
+func (u *Users) Delete(name string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	user, ok := u.users[name]
+	if !ok {
+		return ErrUnknownUser
+	}
+	if user.Role == RoleAdmin && u.adminsLocked() == 1 {
+		return ErrLastAdmin
+	}
+	delete(u.users, name)
+	if err := u.saveLocked(); err != nil {
+		u.users[name] = user
+		return err
+	}
+	return nil
+}
+
+func (u *Users) adminsLocked() int {
+	n := 0
+	for _, user := range u.users {
+		if user.Role == RoleAdmin {
+			n++
+		}
+	}
+	return n
+}
+
+// saveLocked writes the store atomically; the file holds password hashes
+// and is only readable by the owner.
+func (u *Users) saveLocked() error {
+	list := make([]*User, 0, len(u.users))
+	for _, user := range u.users {
+		list = append(list, user)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := u.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, u.path)
+}
+
+func checkPassword(password string) error {
+	if len(password) < minPasswordLen {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLen)
+	}
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
@@ -31,7 +31,7 @@ func (b *BazarrCollector) Name() string { return "bazarr" }
 
 func (b *BazarrCollector) Collect(ctx context.Context) error {
 	if b.cfg.BazarrAPIKey == "" {
-		return nil
+		return ErrNotConfigured
 	}
 
 	movieCount, err := b.getWantedCount(ctx, "/api/movies/wanted")
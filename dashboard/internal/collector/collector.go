@@ -2,13 +2,23 @@ package collector
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math/rand"
+	"sync"
 	"time"
 
 	"arcticmon/internal/config"
+	"arcticmon/internal/history"
+	"arcticmon/internal/models"
 	"arcticmon/internal/store"
 )
 
+// ErrNotConfigured is returned by collectors whose credentials or endpoint
+// are missing, so they are reported as unconfigured rather than healthy.
+var ErrNotConfigured = errors.New("not configured")
+
 // Collector is the interface all data collectors implement.
 type Collector interface {
 	Name() string
@@ -17,62 +27,269 @@ type Collector interface {
 
 // Orchestrator manages all collectors and their polling loops.
 type Orchestrator struct {
-	store *store.Store
-	cfg   *config.Config
+	store   *store.Store
+	cfg     *config.Config
+	history *history.DB
+
+	runMu   sync.Mutex
+	parent  context.Context
+	cancel  context.CancelFunc
+	runners map[string]*runner
+
+	// qbit is kept across reloads so its session and sync state survive
+	// them, and so are the torrent policy history in torrents.
+	qbit     *QbitTransferCollector
+	torrents *torrentTracker
+
+	// Per-collector locks outlive reloads so an old loop still finishing a
+	// collection never overlaps with its replacement.
+	locksMu sync.Mutex
+	locks   map[string]*sync.Mutex
 }
 
+// runner is the scheduling state of one collector loop.
+type runner struct {
+	c        Collector
+	interval time.Duration
+	timeout  time.Duration
+	refresh  chan struct{}
+}
+
+const maxBackoff = 10 * time.Minute
+
+// ErrUnknownCollector is returned by Refresh for names that are not running.
+var ErrUnknownCollector = errors.New("unknown or disabled collector")
+
 // NewOrchestrator creates a new orchestrator.
-func NewOrchestrator(s *store.Store, cfg *config.Config) *Orchestrator {
-	return &Orchestrator{store: s, cfg: cfg}
+func NewOrchestrator(s *store.Store, cfg *config.Config, hist *history.DB) *Orchestrator {
+	return &Orchestrator{
+		store:    s,
+		cfg:      cfg,
+		history:  hist,
+		locks:    make(map[string]*sync.Mutex),
+		qbit:     NewQbitTransferCollector(cfg, s),
+		torrents: newTorrentTracker(),
+	}
 }
 
 // Start launches all collector goroutines. Call cancel on the context to stop.
 func (o *Orchestrator) Start(ctx context.Context) {
-	fast := 10 * time.Second
-	medium := 30 * time.Second
-	slow := 60 * time.Second
-
-	// Fast polling (10s)
-	o.run(ctx, NewHostCollector(o.cfg, o.store), fast)
-	o.run(ctx, NewDockerCollector(o.cfg, o.store), fast)
-	o.run(ctx, NewJellyfinSessionCollector(o.cfg, o.store), fast)
-	o.run(ctx, NewQbitTransferCollector(o.cfg, o.store), fast)
-	o.run(ctx, NewUnmanicCollector(o.cfg, o.store), fast)
-
-	// Medium polling (30s)
-	o.run(ctx, NewSeerrCollector(o.cfg, o.store), medium)
-	o.run(ctx, NewRadarrCollector(o.cfg, o.store), medium)
-	o.run(ctx, NewSonarrCollector(o.cfg, o.store), medium)
-	o.run(ctx, NewSabnzbdCollector(o.cfg, o.store), medium)
-
-	// Slow polling (60s)
-	o.run(ctx, NewJellyfinLibraryCollector(o.cfg, o.store), slow)
-	o.run(ctx, NewProwlarrCollector(o.cfg, o.store), slow)
-	o.run(ctx, NewBazarrCollector(o.cfg, o.store), slow)
+	o.runMu.Lock()
+	defer o.runMu.Unlock()
+	o.parent = ctx
+	o.startLocked()
+}
+
+// Reload stops the running collectors and starts new ones built from cfg.
+// Collector status and run counters are preserved across reloads.
+func (o *Orchestrator) Reload(cfg *config.Config) {
+	o.runMu.Lock()
+	defer o.runMu.Unlock()
+	if o.cancel != nil {
+		o.cancel()
+	}
+	o.cfg = cfg
+	o.startLocked()
+}
+
+// Refresh triggers an immediate collection. Requests made while a
+// collection is already running are coalesced into one follow-up run.
+func (o *Orchestrator) Refresh(name string) error {
+	o.runMu.Lock()
+	r, ok := o.runners[name]
+	o.runMu.Unlock()
+	if !ok {
+		return ErrUnknownCollector
+	}
+	select {
+	case r.refresh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Qbit returns the running qBittorrent collector, whose session the
+// torrent control actions share, or nil when it is disabled.
+func (o *Orchestrator) Qbit() *QbitTransferCollector {
+	o.runMu.Lock()
+	defer o.runMu.Unlock()
+	if _, ok := o.runners["qbittorrent"]; !ok {
+		return nil
+	}
+	return o.qbit
+}
+
+// startLocked builds the collectors from o.cfg. Caller must hold o.runMu.
+func (o *Orchestrator) startLocked() {
+	ctx, cancel := context.WithCancel(o.parent)
+	o.cancel = cancel
+	o.runners = make(map[string]*runner)
+
+	o.run(ctx, NewHostCollector(o.cfg, o.store, o.history))
+	o.run(ctx, NewDockerCollector(o.cfg, o.store, o.history))
+	if o.cfg.CollectorEnabled("docker") {
+		events := NewDockerEventWatcher(o.cfg, o.store, func() {
+			o.Refresh("docker")
+			// A recreated container may have lost network_mode.
+			o.Refresh("vpn-leak")
+		})
+		go events.Run(ctx)
+	}
+	o.run(ctx, NewJellyfinSessionCollector(o.cfg, o.store))
+	o.qbit.SetConfig(o.cfg)
+	o.run(ctx, o.qbit)
+	o.run(ctx, NewTorrentPolicy(o.cfg, o.store, o.qbit, o.torrents))
+	o.run(ctx, NewGluetunCollector(o.cfg, o.store, o.qbit))
+	o.run(ctx, NewVPNLeakCollector(o.cfg, o.store))
+	o.run(ctx, NewUnmanicCollector(o.cfg, o.store))
+	o.run(ctx, NewSeerrCollector(o.cfg, o.store))
+	o.run(ctx, NewRadarrCollector(o.cfg, o.store))
+	o.run(ctx, NewSonarrCollector(o.cfg, o.store))
+	o.run(ctx, NewSabnzbdCollector(o.cfg, o.store))
+	o.run(ctx, NewJellyfinLibraryCollector(o.cfg, o.store))
+	o.run(ctx, NewProwlarrCollector(o.cfg, o.store))
+	o.run(ctx, NewBazarrCollector(o.cfg, o.store))
 	piholeLookup := NewPiholeLookup(o.cfg)
-	o.run(ctx, NewSSHSecurityCollector(o.cfg, o.store, piholeLookup), slow)
+	o.run(ctx, NewSSHSecurityCollector(o.cfg, o.store, piholeLookup))
 }
 
-// run starts a goroutine that calls the collector on a ticker.
-func (o *Orchestrator) run(ctx context.Context, c Collector, interval time.Duration) {
-	go func() {
-		// Initial collection
-		if err := c.Collect(ctx); err != nil {
-			log.Printf("[%s] initial collect error: %v", c.Name(), err)
+// run starts the polling loop of a collector using its configured interval
+// and timeout. Collectors disabled in the config are not started. Caller
+// must hold o.runMu.
+func (o *Orchestrator) run(ctx context.Context, c Collector) {
+	name := c.Name()
+	if !o.cfg.CollectorEnabled(name) {
+		log.Printf("[%s] disabled by config", name)
+		o.store.UpdateCollector(name, func(st *models.CollectorStatus) {
+			st.State = "disabled"
+			st.Enabled = false
+			st.IntervalSeconds = 0
+		})
+		return
+	}
+
+	r := &runner{
+		c:        c,
+		interval: o.cfg.Interval(name),
+		timeout:  o.cfg.Timeout(name),
+		refresh:  make(chan struct{}, 1),
+	}
+	o.runners[name] = r
+	o.store.UpdateCollector(name, func(st *models.CollectorStatus) {
+		if !st.Enabled {
+			st.State = "pending"
 		}
+		st.Enabled = true
+		st.IntervalSeconds = r.interval.Seconds()
+	})
+
+	go o.loop(ctx, r)
+}
 
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
+// loop collects immediately, then waits for the next interval (with
+// jitter), exponential backoff after failures, or a refresh request.
+func (o *Orchestrator) loop(ctx context.Context, r *runner) {
+	name := r.c.Name()
+	failures := 0
+	timer := time.NewTimer(0)
+	defer timer.Stop()
 
-		for {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-r.refresh:
+			timer.Stop()
 			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				if err := c.Collect(ctx); err != nil {
-					log.Printf("[%s] collect error: %v", c.Name(), err)
-				}
+			case <-timer.C:
+			default:
 			}
 		}
-	}()
+
+		err := o.collect(ctx, r)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err == nil, errors.Is(err, ErrNotConfigured):
+			failures = 0
+		default:
+			failures++
+			log.Printf("[%s] collect error (attempt %d): %v", name, failures, err)
+		}
+		timer.Reset(nextDelay(r.interval, failures))
+	}
+}
+
+// nextDelay returns the interval with ±10% jitter, doubled for each
+// consecutive failure up to maxBackoff (never below the interval).
+func nextDelay(interval time.Duration, failures int) time.Duration {
+	d := interval
+	for i := 0; i < failures && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = max(maxBackoff, interval)
+	}
+	jitter := time.Duration((rand.Float64()*0.2 - 0.1) * float64(d))
+	return d + jitter
+}
+
+// lock returns the long-lived mutex serialising runs of a collector.
+func (o *Orchestrator) lock(name string) *sync.Mutex {
+	o.locksMu.Lock()
+	defer o.locksMu.Unlock()
+	mu, ok := o.locks[name]
+	if !ok {
+		mu = &sync.Mutex{}
+		o.locks[name] = mu
+	}
+	return mu
+}
+
+// collect runs a single collection under the collector's timeout and
+// records its outcome in the store.
+func (o *Orchestrator) collect(ctx context.Context, r *runner) error {
+	c := r.c
+	mu := o.lock(c.Name())
+	mu.Lock()
+	defer mu.Unlock()
+
+	runCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.Collect(runCtx)
+	dur := time.Since(start)
+	if ctx.Err() != nil {
+		// Stopped by shutdown or reload; not a collector failure.
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s: %w", r.timeout, err)
+	}
+
+	o.store.UpdateCollector(c.Name(), func(st *models.CollectorStatus) {
+		st.LastRun = &start
+		st.LastDurationMs = float64(dur.Microseconds()) / 1000
+		st.Runs++
+		switch {
+		case errors.Is(err, ErrNotConfigured):
+			st.State = "unconfigured"
+			st.LastError = ""
+			st.ConsecutiveFailures = 0
+		case err != nil:
+			st.State = "error"
+			st.LastError = err.Error()
+			st.LastErrorAt = &start
+			st.ConsecutiveFailures++
+			st.Errors++
+		default:
+			st.State = "ok"
+			st.LastSuccess = &start
+			st.ConsecutiveFailures = 0
+		}
+	})
+
+	return err
 }
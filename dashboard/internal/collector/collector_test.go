@@ -0,0 +1,33 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextDelay(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		failures int
+		want     time.Duration // before jitter
+	}{
+		{interval: 10 * time.Second, failures: 0, want: 10 * time.Second},
+		{interval: 10 * time.Second, failures: 1, want: 20 * time.Second},
+		{interval: 10 * time.Second, failures: 3, want: 80 * time.Second},
+		{interval: 10 * time.Second, failures: 6, want: maxBackoff},
+		{interval: 10 * time.Second, failures: 1000, want: maxBackoff},
+		{interval: 5 * time.Minute, failures: 1, want: maxBackoff},
+		// An interval above the cap is never shortened by failures.
+		{interval: time.Hour, failures: 0, want: time.Hour},
+		{interval: time.Hour, failures: 2, want: time.Hour},
+	}
+	for _, tt := range tests {
+		lo := time.Duration(float64(tt.want) * 0.9)
+		hi := time.Duration(float64(tt.want) * 1.1)
+		for i := 0; i < 50; i++ {
+			if got := nextDelay(tt.interval, tt.failures); got < lo || got > hi {
+				t.Fatalf("nextDelay(%s, %d) = %s, want %s ±10%%", tt.interval, tt.failures, got, tt.want)
+			}
+		}
+	}
+}
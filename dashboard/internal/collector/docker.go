@@ -7,24 +7,42 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"arcticmon/internal/config"
+	"arcticmon/internal/history"
 	"arcticmon/internal/models"
 	"arcticmon/internal/store"
 )
 
 // DockerCollector collects container health/status via the Docker socket.
 type DockerCollector struct {
-	cfg    *config.Config
-	store  *store.Store
-	client *http.Client
+	cfg     *config.Config
+	store   *store.Store
+	history *history.DB
+	client  *http.Client
+
+	// Previous I/O counters per container ID, for rate computation.
+	prev map[string]ioSample
+}
+
+// ioSample is a snapshot of a container's cumulative I/O counters.
+type ioSample struct {
+	t                         time.Time
+	rx, tx, blkRead, blkWrite uint64
 }
 
-func NewDockerCollector(cfg *config.Config, s *store.Store) *DockerCollector {
+// maxStatsRequests bounds concurrent /stats calls; each one blocks for
+// about a second while the daemon takes two CPU samples.
+const maxStatsRequests = 8
+
+func NewDockerCollector(cfg *config.Config, s *store.Store, hist *history.DB) *DockerCollector {
 	return &DockerCollector{
-		cfg:   cfg,
-		store: s,
+		cfg:     cfg,
+		store:   s,
+		history: hist,
+		prev:    make(map[string]ioSample),
 		client: &http.Client{
 			Timeout: 5 * time.Second,
 			Transport: &http.Transport{
@@ -55,22 +73,10 @@ func (d *DockerCollector) Collect(ctx context.Context) error {
 		return err
 	}
 
-	// Map of known services to their external URLs
-	urlMap := map[string]string{
-		"jellyfin":   "https://jellyfin.local.example.com",
-		"radarr":     "https://radarr.local.example.com",
-		"sonarr":     "https://sonarr.local.example.com",
-		"seerr":      "https://seerr.local.example.com",
-		"prowlarr":   "https://prowlarr.local.example.com",
-		"bazarr":     "https://bazarr.local.example.com",
-		"sabnzbd":    "https://sabnzbd.local.example.com",
-		"unmanic":    "https://unmanic.local.example.com",
-		"npm":        "https://npm.local.example.com",
-		"arcticmon":  "https://dashboard.local.example.com",
-	}
+	stats := d.collectStats(ctx, containers)
 
 	var svcs []models.ServiceStatus
-	for _, c := range containers {
+	for i, c := range containers {
 		name := strings.TrimPrefix(c.Names[0], "/")
 		health := "none"
 		if c.State == "running" && c.Status != "" {
@@ -110,14 +116,111 @@ func (d *DockerCollector) Collect(ctx context.Context) error {
 			Image:       image,
 			IP:          ip,
 			Uptime:      uptime,
-			ExternalURL: urlMap[name],
+			ExternalURL: d.cfg.ExternalURLs[name],
+			Stats:       stats[i],
 		})
 	}
 
 	d.store.UpdateServices(svcs)
+	d.recordHistory(svcs)
 	return nil
 }
 
+// collectStats fetches resource usage of every running container in
+// parallel. Containers whose stats cannot be read get a nil entry.
+func (d *DockerCollector) collectStats(ctx context.Context, containers []dockerContainer) []*models.ContainerStats {
+	out := make([]*models.ContainerStats, len(containers))
+	raw := make([]*dockerStats, len(containers))
+	sem := make(chan struct{}, maxStatsRequests)
+	var wg sync.WaitGroup
+	for i, c := range containers {
+		if c.State != "running" {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			raw[i], _ = d.fetchStats(ctx, id)
+		}(i, c.ID)
+	}
+	wg.Wait()
+
+	now := time.Now()
+	seen := make(map[string]ioSample, len(containers))
+	for i, c := range containers {
+		if raw[i] == nil {
+			continue
+		}
+		st := raw[i].toModel()
+		cur := ioSample{t: now, rx: st.NetRx, tx: st.NetTx, blkRead: st.BlockRead, blkWrite: st.BlockWrite}
+		if prev, ok := d.prev[c.ID]; ok {
+			secs := cur.t.Sub(prev.t).Seconds()
+			st.NetRxRate = counterRate(prev.rx, cur.rx, secs)
+			st.NetTxRate = counterRate(prev.tx, cur.tx, secs)
+			st.BlockReadRate = counterRate(prev.blkRead, cur.blkRead, secs)
+			st.BlockWriteRate = counterRate(prev.blkWrite, cur.blkWrite, secs)
+		}
+		seen[c.ID] = cur
+		out[i] = st
+	}
+	d.prev = seen
+	return out
+}
+
+func (d *DockerCollector) fetchStats(ctx context.Context, id string) (*dockerStats, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET",
+		fmt.Sprintf("http://docker/containers/%s/stats?stream=false", id), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("stats %s: status %d", id, resp.StatusCode)
+	}
+	var st dockerStats
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// recordHistory feeds container stats into the time-series store as
+// "service.<stats json field>:<container name>".
+func (d *DockerCollector) recordHistory(svcs []models.ServiceStatus) {
+	if d.history == nil {
+		return
+	}
+	now := time.Now()
+	for _, svc := range svcs {
+		st := svc.Stats
+		if st == nil {
+			continue
+		}
+		d.history.Record("service.cpuPercent:"+svc.Name, now, st.CPUPercent)
+		d.history.Record("service.memUsage:"+svc.Name, now, float64(st.MemUsage))
+		d.history.Record("service.memPercent:"+svc.Name, now, st.MemPercent)
+		d.history.Record("service.netRxRate:"+svc.Name, now, st.NetRxRate)
+		d.history.Record("service.netTxRate:"+svc.Name, now, st.NetTxRate)
+		d.history.Record("service.blockReadRate:"+svc.Name, now, st.BlockReadRate)
+		d.history.Record("service.blockWriteRate:"+svc.Name, now, st.BlockWriteRate)
+	}
+}
+
+// counterRate returns the per-second increase of a cumulative counter,
+// treating a decrease (container restart) as a reset.
+func counterRate(prev, cur uint64, secs float64) float64 {
+	if secs <= 0 || cur < prev {
+		return 0
+	}
+	return float64(cur-prev) / secs
+}
+
 func formatContainerUptime(created int64) string {
 	t := time.Unix(created, 0)
 	dur := time.Since(t)
@@ -144,3 +247,76 @@ type dockerContainer struct {
 		} `json:"Networks"`
 	} `json:"NetworkSettings"`
 }
+
+// dockerStats is the subset of the /containers/{id}/stats response we use.
+type dockerStats struct {
+	CPUStats    dockerCPUStats `json:"cpu_stats"`
+	PreCPUStats dockerCPUStats `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+	BlkioStats struct {
+		IOServiceBytesRecursive []struct {
+			Op    string `json:"op"`
+			Value uint64 `json:"value"`
+		} `json:"io_service_bytes_recursive"`
+	} `json:"blkio_stats"`
+}
+
+type dockerCPUStats struct {
+	CPUUsage struct {
+		TotalUsage  uint64   `json:"total_usage"`
+		PercpuUsage []uint64 `json:"percpu_usage"`
+	} `json:"cpu_usage"`
+	SystemUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs  int    `json:"online_cpus"`
+}
+
+// toModel converts raw stats using the same formulas as `docker stats`:
+// CPU is relative to one core, memory excludes the page cache.
+func (s *dockerStats) toModel() *models.ContainerStats {
+	st := &models.ContainerStats{}
+
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	sysDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	cpus := s.CPUStats.OnlineCPUs
+	if cpus == 0 {
+		cpus = len(s.CPUStats.CPUUsage.PercpuUsage)
+	}
+	if cpuDelta > 0 && sysDelta > 0 {
+		st.CPUPercent = cpuDelta / sysDelta * float64(cpus) * 100
+	}
+
+	mem := s.MemoryStats
+	cache := mem.Stats["inactive_file"] // cgroup v2
+	if v, ok := mem.Stats["total_inactive_file"]; ok {
+		cache = v // cgroup v1
+	}
+	if cache < mem.Usage {
+		st.MemUsage = mem.Usage - cache
+	}
+	st.MemLimit = mem.Limit
+	if mem.Limit > 0 {
+		st.MemPercent = float64(st.MemUsage) / float64(mem.Limit) * 100
+	}
+
+	for _, n := range s.Networks {
+		st.NetRx += n.RxBytes
+		st.NetTx += n.TxBytes
+	}
+	for _, io := range s.BlkioStats.IOServiceBytesRecursive {
+		switch strings.ToLower(io.Op) {
+		case "read":
+			st.BlockRead += io.Value
+		case "write":
+			st.BlockWrite += io.Value
+		}
+	}
+	return st
+}
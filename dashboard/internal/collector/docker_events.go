@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"arcticmon/internal/config"
+	"arcticmon/internal/models"
+	"arcticmon/internal/store"
+)
+
+// dockerEventActions are the container events recorded in the timeline.
+var dockerEventActions = []string{"start", "stop", "die", "kill", "restart", "oom", "health_status"}
+
+// DockerEventWatcher follows the Docker /events stream and applies
+// container lifecycle changes to the store as they happen, between polls
+// of the docker collector.
+type DockerEventWatcher struct {
+	cfg     *config.Config
+	store   *store.Store
+	refresh func()
+	client  *http.Client
+}
+
+// NewDockerEventWatcher creates a watcher. refresh is called after start
+// events so the docker collector picks up the new IP, image and uptime.
+func NewDockerEventWatcher(cfg *config.Config, s *store.Store, refresh func()) *DockerEventWatcher {
+	return &DockerEventWatcher{
+		cfg:     cfg,
+		store:   s,
+		refresh: refresh,
+		client: &http.Client{
+			// No timeout: the events stream stays open indefinitely.
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", cfg.DockerSocket)
+				},
+			},
+		},
+	}
+}
+
+// Run follows the event stream until ctx is cancelled, reconnecting with
+// exponential backoff when the stream ends or the socket is unavailable.
+func (w *DockerEventWatcher) Run(ctx context.Context) {
+	failures := 0
+	for {
+		start := time.Now()
+		err := w.follow(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > time.Minute {
+			failures = 0
+		}
+		failures++
+		log.Printf("[docker-events] stream ended (attempt %d): %v", failures, err)
+
+		delay := time.Second << min(failures-1, 6)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		// Catch up on anything missed while disconnected.
+		w.refresh()
+	}
+}
+
+// follow streams events until the connection drops.
+func (w *DockerEventWatcher) follow(ctx context.Context) error {
+	filters, _ := json.Marshal(map[string][]string{
+		"type":  {"container"},
+		"event": dockerEventActions,
+	})
+	req, err := http.NewRequestWithContext(ctx, "GET",
+		"http://docker/events?filters="+url.QueryEscape(string(filters)), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("docker socket: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("docker events: status %d", resp.StatusCode)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg dockerEventMessage
+		if err := dec.Decode(&msg); err != nil {
+			return err
+		}
+		w.handle(msg)
+	}
+}
+
+func (w *DockerEventWatcher) handle(msg dockerEventMessage) {
+	name := msg.Actor.Attributes["name"]
+	if name == "" {
+		return
+	}
+
+	ev := models.ContainerEvent{
+		Time:   time.Unix(0, msg.TimeNano),
+		Action: msg.Action,
+	}
+	if msg.TimeNano == 0 {
+		ev.Time = time.Unix(msg.Time, 0)
+	}
+	// Health events arrive as "health_status: healthy".
+	if action, status, ok := strings.Cut(msg.Action, ":"); ok {
+		ev.Action = action
+		if action == "health_status" {
+			ev.Health = strings.TrimSpace(status)
+		}
+	}
+	if ev.Action == "die" {
+		if code, err := strconv.Atoi(msg.Actor.Attributes["exitCode"]); err == nil {
+			ev.ExitCode = &code
+		}
+	}
+
+	w.store.RecordServiceEvent(name, ev)
+	if ev.Action == "start" {
+		w.refresh()
+	}
+}
+
+type dockerEventMessage struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+	Time     int64 `json:"time"`
+	TimeNano int64 `json:"timeNano"`
+}
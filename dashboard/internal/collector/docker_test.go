@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"arcticmon/internal/models"
+)
+
+func TestCounterRate(t *testing.T) {
+	tests := []struct {
+		prev, cur uint64
+		secs      float64
+		want      float64
+	}{
+		{prev: 1000, cur: 3000, secs: 10, want: 200},
+		{prev: 1000, cur: 1000, secs: 10, want: 0},
+		// The container restarted and its counters started over.
+		{prev: 5000, cur: 100, secs: 10, want: 0},
+		{prev: 1000, cur: 3000, secs: 0, want: 0},
+		{prev: 1000, cur: 3000, secs: -1, want: 0},
+	}
+	for _, tt := range tests {
+		if got := counterRate(tt.prev, tt.cur, tt.secs); got != tt.want {
+			t.Errorf("counterRate(%d, %d, %g) = %g, want %g", tt.prev, tt.cur, tt.secs, got, tt.want)
+		}
+	}
+}
+
+func TestDockerStatsToModel(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want models.ContainerStats
+	}{
+		{
+			name: "cgroup v2",
+			raw: `{
+				"cpu_stats": {"cpu_usage": {"total_usage": 3000000000}, "system_cpu_usage": 20000000000, "online_cpus": 4},
+				"precpu_stats": {"cpu_usage": {"total_usage": 2000000000}, "system_cpu_usage": 10000000000},
+				"memory_stats": {"usage": 600, "limit": 1000, "stats": {"inactive_file": 100}},
+				"networks": {"eth0": {"rx_bytes": 10, "tx_bytes": 20}, "eth1": {"rx_bytes": 1, "tx_bytes": 2}},
+				"blkio_stats": {"io_service_bytes_recursive": [
+					{"op": "read", "value": 100}, {"op": "write", "value": 50}, {"op": "read", "value": 5}
+				]}
+			}`,
+			want: models.ContainerStats{
+				CPUPercent: 40, MemUsage: 500, MemLimit: 1000, MemPercent: 50,
+				NetRx: 11, NetTx: 22, BlockRead: 105, BlockWrite: 50,
+			},
+		},
+		{
+			name: "cgroup v1",
+			raw: `{
+				"cpu_stats": {"cpu_usage": {"total_usage": 400, "percpu_usage": [100, 300]}, "system_cpu_usage": 2000},
+				"precpu_stats": {"cpu_usage": {"total_usage": 200}, "system_cpu_usage": 1000},
+				"memory_stats": {"usage": 800, "limit": 2000, "stats": {"inactive_file": 1, "total_inactive_file": 400}},
+				"blkio_stats": {"io_service_bytes_recursive": [{"op": "Read", "value": 7}, {"op": "Write", "value": 9}, {"op": "Total", "value": 16}]}
+			}`,
+			want: models.ContainerStats{
+				CPUPercent: 40, MemUsage: 400, MemLimit: 2000, MemPercent: 20,
+				BlockRead: 7, BlockWrite: 9,
+			},
+		},
+		{
+			name: "first sample and cache above usage",
+			raw: `{
+				"cpu_stats": {"cpu_usage": {"total_usage": 500}, "system_cpu_usage": 1000, "online_cpus": 2},
+				"memory_stats": {"usage": 100, "stats": {"inactive_file": 200}}
+			}`,
+			want: models.ContainerStats{CPUPercent: 100},
+		},
+		{
+			name: "stopped container",
+			raw:  `{"cpu_stats": {}, "precpu_stats": {}, "memory_stats": {}}`,
+			want: models.ContainerStats{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s dockerStats
+			if err := json.Unmarshal([]byte(tt.raw), &s); err != nil {
+				t.Fatal(err)
+			}
+			got := *s.toModel()
+			if math.Abs(got.CPUPercent-tt.want.CPUPercent) > 1e-9 {
+				t.Errorf("CPUPercent = %g, want %g", got.CPUPercent, tt.want.CPUPercent)
+			}
+			got.CPUPercent = tt.want.CPUPercent
+			if got != tt.want {
+				t.Errorf("toModel() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
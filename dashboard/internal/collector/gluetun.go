@@ -0,0 +1,174 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"arcticmon/internal/config"
+	"arcticmon/internal/models"
+	"arcticmon/internal/store"
+)
+
+// errGluetunNotFound marks control server routes missing from this
+// Gluetun version.
+var errGluetunNotFound = errors.New("not found")
+
+// GluetunCollector polls Gluetun's control server for the tunnel status,
+// public exit IP and forwarded port, and checks that qBittorrent listens
+// on the forwarded port.
+type GluetunCollector struct {
+	cfg    *config.Config
+	store  *store.Store
+	qbit   *QbitTransferCollector
+	client *http.Client
+}
+
+func NewGluetunCollector(cfg *config.Config, s *store.Store, qbit *QbitTransferCollector) *GluetunCollector {
+	return &GluetunCollector{
+		cfg:    cfg,
+		store:  s,
+		qbit:   qbit,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (g *GluetunCollector) Name() string { return "gluetun" }
+
+func (g *GluetunCollector) Collect(ctx context.Context) error {
+	if g.cfg.GluetunURL == "" {
+		return ErrNotConfigured
+	}
+
+	var vpn models.VPNData
+	var status struct {
+		Status string `json:"status"`
+	}
+	// Older Gluetun versions only have the OpenVPN routes.
+	err := g.getJSON(ctx, "/v1/vpn/status", &status)
+	if errors.Is(err, errGluetunNotFound) {
+		err = g.getJSON(ctx, "/v1/openvpn/status", &status)
+	}
+	if err != nil {
+		// An unreachable control server means the tunnel is down too.
+		vpn.Status = "unreachable"
+		g.store.UpdateVPN(vpn)
+		g.store.ReplaceHealth("Gluetun", []models.HealthWarning{{
+			Source:  "Gluetun",
+			Type:    "error",
+			Message: "VPN control server unreachable: " + err.Error(),
+		}})
+		return err
+	}
+	vpn.Status = status.Status
+
+	var warnings []models.HealthWarning
+	if vpn.Status != "running" {
+		warnings = append(warnings, models.HealthWarning{
+			Source:  "Gluetun",
+			Type:    "error",
+			Message: fmt.Sprintf("VPN tunnel is %s", vpn.Status),
+		})
+	}
+
+	// The exit IP and forwarded port are best effort: the status is
+	// published and the failures raised even when they cannot be read.
+	var errs []error
+	var ip struct {
+		PublicIP     string `json:"public_ip"`
+		Country      string `json:"country"`
+		Region       string `json:"region"`
+		City         string `json:"city"`
+		Organization string `json:"organization"`
+	}
+	if err := g.getJSON(ctx, "/v1/publicip/ip", &ip); err != nil {
+		errs = append(errs, err)
+		warnings = append(warnings, models.HealthWarning{
+			Source:  "Gluetun",
+			Type:    "warning",
+			Message: "cannot read the VPN public IP: " + err.Error(),
+		})
+	} else {
+		vpn.PublicIP, vpn.Country, vpn.Region, vpn.City, vpn.Organization =
+			ip.PublicIP, ip.Country, ip.Region, ip.City, ip.Organization
+	}
+
+	var pf struct {
+		Port int `json:"port"`
+	}
+	// /v1/portforward replaced /v1/openvpn/portforwarded.
+	err = g.getJSON(ctx, "/v1/portforward", &pf)
+	if errors.Is(err, errGluetunNotFound) {
+		err = g.getJSON(ctx, "/v1/openvpn/portforwarded", &pf)
+	}
+	switch {
+	case err != nil:
+		errs = append(errs, err)
+		warnings = append(warnings, models.HealthWarning{
+			Source:  "Gluetun",
+			Type:    "warning",
+			Message: "cannot read the forwarded port: " + err.Error(),
+		})
+	case pf.Port == 0 && vpn.Status == "running":
+		// VPN_PORT_FORWARDING is on, so no port means forwarding failed.
+		warnings = append(warnings, models.HealthWarning{
+			Source:  "Gluetun",
+			Type:    "warning",
+			Message: "VPN tunnel is up but no port is forwarded",
+		})
+	}
+	vpn.ForwardedPort = pf.Port
+
+	if vpn.ForwardedPort != 0 && g.qbit != nil && g.cfg.CollectorEnabled("qbittorrent") {
+		port, err := g.qbit.ListenPort(ctx)
+		switch {
+		case errors.Is(err, ErrNotConfigured):
+		case err != nil:
+			warnings = append(warnings, models.HealthWarning{
+				Source:  "Gluetun",
+				Type:    "warning",
+				Message: "cannot check the qBittorrent listen port: " + err.Error(),
+			})
+		default:
+			vpn.QbitListenPort = port
+			if port != vpn.ForwardedPort {
+				warnings = append(warnings, models.HealthWarning{
+					Source:  "Gluetun",
+					Type:    "warning",
+					Message: fmt.Sprintf("qBittorrent listens on port %d but the VPN forwards port %d", port, vpn.ForwardedPort),
+				})
+			}
+		}
+	}
+
+	g.store.UpdateVPN(vpn)
+	g.store.ReplaceHealth("Gluetun", warnings)
+	return errors.Join(errs...)
+}
+
+func (g *GluetunCollector) getJSON(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", g.cfg.GluetunURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if g.cfg.GluetunAPIKey != "" {
+		req.Header.Set("X-API-Key", g.cfg.GluetunAPIKey)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return fmt.Errorf("gluetun %s: %w", path, errGluetunNotFound)
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("gluetun %s: status %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
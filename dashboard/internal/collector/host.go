@@ -15,15 +15,17 @@ import (
 	"time"
 
 	"arcticmon/internal/config"
+	"arcticmon/internal/history"
 	"arcticmon/internal/models"
 	"arcticmon/internal/store"
 )
 
 // HostCollector collects CPU, RAM, swap, disk, GPU, SSH sessions, and uptime.
 type HostCollector struct {
-	cfg    *config.Config
-	store  *store.Store
-	docker *http.Client
+	cfg     *config.Config
+	store   *store.Store
+	history *history.DB
+	docker  *http.Client
 
 	prevIdle  uint64
 	prevTotal uint64
@@ -32,10 +34,11 @@ type HostCollector struct {
 	prevCoreTotal map[int]uint64
 }
 
-func NewHostCollector(cfg *config.Config, s *store.Store) *HostCollector {
+func NewHostCollector(cfg *config.Config, s *store.Store, hist *history.DB) *HostCollector {
 	return &HostCollector{
-		cfg:   cfg,
-		store: s,
+		cfg:     cfg,
+		store:   s,
+		history: hist,
 		docker: &http.Client{
 			Timeout: 5 * time.Second,
 			Transport: &http.Transport{
@@ -63,7 +66,7 @@ func (h *HostCollector) Collect(ctx context.Context) error {
 	// Memory + swap
 	h.readMemory(&metrics)
 
-	// Disks (NVMe on /, HDD on /mnt/media)
+	// Disks (configured mounts)
 	metrics.Disks = h.readDisks()
 
 	// GPU (via nvidia-smi in jellyfin container, requires PCI passthrough)
@@ -80,9 +83,37 @@ func (h *HostCollector) Collect(ctx context.Context) error {
 	}
 
 	h.store.UpdateHost(metrics)
+	h.recordHistory(metrics)
 	return nil
 }
 
+// recordHistory feeds the sample into the time-series store. Series are
+// named "host.<json field>", with the mount or core ID appended after a
+// colon for per-disk and per-core values.
+func (h *HostCollector) recordHistory(m models.HostMetrics) {
+	if h.history == nil {
+		return
+	}
+	now := time.Now()
+	h.history.Record("host.cpuPercent", now, m.CPUPercent)
+	for _, c := range m.CPUCores {
+		h.history.Record(fmt.Sprintf("host.cpuCore:%d", c.ID), now, c.Percent)
+	}
+	h.history.Record("host.memUsed", now, float64(m.MemUsed))
+	h.history.Record("host.memPercent", now, m.MemPercent)
+	h.history.Record("host.swapUsed", now, float64(m.SwapUsed))
+	h.history.Record("host.swapPercent", now, m.SwapPercent)
+	for _, d := range m.Disks {
+		h.history.Record("host.diskUsed:"+d.Mount, now, float64(d.Used))
+		h.history.Record("host.diskPercent:"+d.Mount, now, d.Percent)
+	}
+	if m.GPU.Available {
+		h.history.Record("host.gpuUtilPercent", now, float64(m.GPU.UtilPercent))
+		h.history.Record("host.gpuTempC", now, float64(m.GPU.TempC))
+		h.history.Record("host.gpuMemPercent", now, m.GPU.MemPercent)
+	}
+}
+
 func (h *HostCollector) readCPU() (float64, []models.CPUCore, error) {
 	data, err := os.ReadFile(h.cfg.HostProcPath + "/stat")
 	if err != nil {
@@ -212,38 +243,23 @@ func (h *HostCollector) readMemory(m *models.HostMetrics) {
 }
 
 func (h *HostCollector) readDisks() []models.DiskInfo {
-	type diskMount struct {
-		mount string
-		label string
-	}
-	targets := []diskMount{
-		{mount: "/", label: "NVMe (/)"},
-		{mount: "/mnt/media", label: "HDD (/mnt/media)"},
-	}
-
 	var disks []models.DiskInfo
-	for _, t := range targets {
+	for _, t := range h.cfg.Mounts {
 		var stat statfsResult
 		// In container, / is the container root. Use /host/proc/1/root to access host FS
-		path := t.mount
-		if t.mount == "/" {
-			// Try host root via proc
-			hostRoot := h.cfg.HostProcPath + "/1/root"
-			if _, err := os.Stat(hostRoot); err == nil {
-				path = hostRoot
-			}
-		} else {
-			// For /mnt/media, try via host proc first
-			hostPath := h.cfg.HostProcPath + "/1/root" + t.mount
-			if _, err := os.Stat(hostPath); err == nil {
-				path = hostPath
-			}
+		path := t.Path
+		hostPath := h.cfg.HostProcPath + "/1/root"
+		if t.Path != "/" {
+			hostPath += t.Path
+		}
+		if _, err := os.Stat(hostPath); err == nil {
+			path = hostPath
 		}
 
 		if err := statfs(path, &stat); err == nil && stat.Total > 0 {
 			disks = append(disks, models.DiskInfo{
-				Mount:   t.mount,
-				Label:   t.label,
+				Mount:   t.Path,
+				Label:   t.Label,
 				Total:   stat.Total,
 				Used:    stat.Used,
 				Percent: float64(stat.Used) / float64(stat.Total) * 100,
@@ -31,7 +31,7 @@ func (j *JellyfinSessionCollector) Name() string { return "jellyfin" }
 
 func (j *JellyfinSessionCollector) Collect(ctx context.Context) error {
 	if j.cfg.JellyfinAPIKey == "" {
-		return nil
+		return ErrNotConfigured
 	}
 
 	sessions, err := j.getSessions(ctx)
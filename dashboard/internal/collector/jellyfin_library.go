@@ -31,7 +31,7 @@ func (j *JellyfinLibraryCollector) Name() string { return "jellyfin-library" }
 
 func (j *JellyfinLibraryCollector) Collect(ctx context.Context) error {
 	if j.cfg.JellyfinAPIKey == "" {
-		return nil
+		return ErrNotConfigured
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET",
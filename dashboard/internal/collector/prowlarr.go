@@ -31,7 +31,7 @@ func (p *ProwlarrCollector) Name() string { return "prowlarr" }
 
 func (p *ProwlarrCollector) Collect(ctx context.Context) error {
 	if p.cfg.ProwlarrAPIKey == "" {
-		return nil
+		return ErrNotConfigured
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET",
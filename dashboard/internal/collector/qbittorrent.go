@@ -3,12 +3,16 @@ package collector
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"arcticmon/internal/config"
@@ -16,126 +20,253 @@ import (
 	"arcticmon/internal/store"
 )
 
+// etaUnknown is the ETA qBittorrent reports for torrents that will not
+// complete (8640000 seconds, 100 days).
+const etaUnknown = 8640000
+
 // QbitTransferCollector polls qBittorrent for transfer stats and torrents.
+// It keeps the full torrent list up to date with the incremental
+// /api/v2/sync/maindata endpoint: after the first full update each poll
+// only returns the fields that changed since the previous response id.
+//
+// The Orchestrator keeps one collector across config reloads, so the
+// session and sync state survive them; SetConfig swaps the settings.
 type QbitTransferCollector struct {
-	cfg    *config.Config
+	cfg    atomic.Pointer[config.Config]
 	store  *store.Store
 	client *http.Client
+
+	// resync asks the next poll to start over with a full update, after
+	// the server or account changed.
+	resync atomic.Bool
+
+	// authMu serialises logins between polls and Command calls sharing
+	// the session cookie.
+	authMu sync.Mutex
 	authed bool
+
+	rid         int64
+	torrents    map[string]map[string]any // hash -> merged torrent fields
+	serverState map[string]any
 }
 
 func NewQbitTransferCollector(cfg *config.Config, s *store.Store) *QbitTransferCollector {
 	jar, _ := cookiejar.New(nil)
-	return &QbitTransferCollector{
-		cfg:   cfg,
+	q := &QbitTransferCollector{
 		store: s,
 		client: &http.Client{
 			Timeout: 5 * time.Second,
 			Jar:     jar,
 		},
 	}
+	q.cfg.Store(cfg)
+	return q
+}
+
+// SetConfig applies a reloaded config. The session and torrent state are
+// kept unless the qBittorrent URL or credentials changed.
+func (q *QbitTransferCollector) SetConfig(cfg *config.Config) {
+	prev := q.cfg.Swap(cfg)
+	if prev.QbitURL != cfg.QbitURL || prev.QbitUsername != cfg.QbitUsername || prev.QbitPassword != cfg.QbitPassword {
+		q.logout()
+		q.resync.Store(true)
+	}
 }
 
 func (q *QbitTransferCollector) Name() string { return "qbittorrent" }
 
+// qbitMainData is a /api/v2/sync/maindata response. Torrent and server
+// state objects only hold the fields that changed unless FullUpdate is set.
+type qbitMainData struct {
+	Rid             int64                     `json:"rid"`
+	FullUpdate      bool                      `json:"full_update"`
+	Torrents        map[string]map[string]any `json:"torrents"`
+	TorrentsRemoved []string                  `json:"torrents_removed"`
+	ServerState     map[string]any            `json:"server_state"`
+}
+
 func (q *QbitTransferCollector) Collect(ctx context.Context) error {
-	if q.cfg.QbitPassword == "" {
-		return nil
+	if q.cfg.Load().QbitPassword == "" {
+		return ErrNotConfigured
 	}
-
-	if !q.authed {
-		if err := q.login(ctx); err != nil {
-			return err
-		}
+	if q.resync.Swap(false) {
+		q.rid = 0
 	}
 
-	data := models.TorrentData{}
-
-	// Transfer info
-	transfer, err := q.getJSON(ctx, "/api/v2/transfer/info")
-	if err != nil {
-		q.authed = false
+	if err := q.ensureLogin(ctx); err != nil {
 		return err
 	}
-	if m, ok := transfer.(map[string]any); ok {
-		data.DLSpeed = jsonUint64(m["dl_info_speed"])
-		data.UPSpeed = jsonUint64(m["up_info_speed"])
-	}
 
-	// Torrents
-	torrentsRaw, err := q.getJSON(ctx, "/api/v2/torrents/info")
-	if err != nil {
-		q.authed = false
+	if err := q.sync(ctx); err != nil {
+		// Start over with a full update next time.
+		q.logout()
+		q.rid = 0
 		return err
 	}
 
-	if torrents, ok := torrentsRaw.([]any); ok {
-		data.TotalCount = len(torrents)
-		var allRatio float64
-		var ratioCount int
-		var active []models.TorrentBrief
+	list := q.torrentList()
+	q.store.UpdateTorrentList(list)
+	q.store.UpdateTorrents(q.summarize(list))
+	return nil
+}
 
-		for _, t := range torrents {
-			m, ok := t.(map[string]any)
-			if !ok {
-				continue
-			}
+// sync applies the changes since the last response to the local state.
+func (q *QbitTransferCollector) sync(ctx context.Context) error {
+	var md qbitMainData
+	if err := q.getJSON(ctx, fmt.Sprintf("/api/v2/sync/maindata?rid=%d", q.rid), &md); err != nil {
+		return err
+	}
+	if md.FullUpdate || q.torrents == nil {
+		q.torrents = make(map[string]map[string]any, len(md.Torrents))
+		q.serverState = make(map[string]any)
+	}
+	for hash, fields := range md.Torrents {
+		t := q.torrents[hash]
+		if t == nil {
+			t = make(map[string]any, len(fields))
+			q.torrents[hash] = t
+		}
+		for k, v := range fields {
+			t[k] = v
+		}
+	}
+	for _, hash := range md.TorrentsRemoved {
+		delete(q.torrents, hash)
+	}
+	for k, v := range md.ServerState {
+		q.serverState[k] = v
+	}
+	q.rid = md.Rid
+	return nil
+}
 
-			state := jsonStr(m["state"])
-			ratio := jsonFloat(m["ratio"])
-			if ratio > 0 {
-				allRatio += ratio
-				ratioCount++
+// torrentList converts the merged torrent fields, newest first.
+func (q *QbitTransferCollector) torrentList() []models.Torrent {
+	list := make([]models.Torrent, 0, len(q.torrents))
+	for hash, m := range q.torrents {
+		eta := int64(jsonFloat(m["eta"]))
+		if eta >= etaUnknown {
+			eta = -1
+		}
+		tags := []string{}
+		for _, tag := range strings.Split(jsonStr(m["tags"]), ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
 			}
+		}
+		list = append(list, models.Torrent{
+			Hash:         hash,
+			Name:         jsonStr(m["name"]),
+			State:        jsonStr(m["state"]),
+			Progress:     jsonFloat(m["progress"]) * 100,
+			Size:         jsonUint64(m["size"]),
+			Downloaded:   jsonUint64(m["downloaded"]),
+			Uploaded:     jsonUint64(m["uploaded"]),
+			DLSpeed:      jsonUint64(m["dlspeed"]),
+			UPSpeed:      jsonUint64(m["upspeed"]),
+			Ratio:        jsonFloat(m["ratio"]),
+			ETA:          eta,
+			Category:     jsonStr(m["category"]),
+			Tags:         tags,
+			AddedOn:      jsonUnix(m["added_on"]),
+			CompletedOn:  jsonUnix(m["completion_on"]),
+			LastActivity: jsonUnix(m["last_activity"]),
+			SeedingTime:  int64(jsonFloat(m["seeding_time"])),
+			Tracker:      jsonStr(m["tracker"]),
+			Seeds:        int(jsonFloat(m["num_seeds"])),
+			Leechs:       int(jsonFloat(m["num_leechs"])),
+			SwarmSeeds:   int(jsonFloat(m["num_complete"])),
+			SwarmLeechs:  int(jsonFloat(m["num_incomplete"])),
+			SavePath:     jsonStr(m["save_path"]),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].AddedOn.After(list[j].AddedOn) })
+	return list
+}
 
-			switch state {
-			case "uploading", "stalledUP", "forcedUP", "queuedUP", "checkingUP":
-				data.SeedingCount++
-			}
+// summarize computes the dashboard aggregates and the top 10 active
+// torrents by download speed.
+func (q *QbitTransferCollector) summarize(list []models.Torrent) models.TorrentData {
+	data := models.TorrentData{
+		DLSpeed:    jsonUint64(q.serverState["dl_info_speed"]),
+		UPSpeed:    jsonUint64(q.serverState["up_info_speed"]),
+		TotalCount: len(list),
+	}
+	var allRatio float64
+	var ratioCount int
+	var active []models.TorrentBrief
 
-			dlSpeed := jsonUint64(m["dlspeed"])
-			upSpeed := jsonUint64(m["upspeed"])
-			progress := jsonFloat(m["progress"])
-
-			if dlSpeed > 0 || upSpeed > 1024 || (progress < 1 && progress > 0) {
-				data.ActiveCount++
-				active = append(active, models.TorrentBrief{
-					Name:     jsonStr(m["name"]),
-					State:    state,
-					Progress: progress * 100,
-					DLSpeed:  dlSpeed,
-					UPSpeed:  upSpeed,
-					Size:     jsonUint64(m["size"]),
-					Ratio:    ratio,
-				})
-			}
+	for _, t := range list {
+		if t.Ratio > 0 {
+			allRatio += t.Ratio
+			ratioCount++
 		}
 
-		if ratioCount > 0 {
-			data.Ratio = allRatio / float64(ratioCount)
+		switch t.State {
+		case "uploading", "stalledUP", "forcedUP", "queuedUP", "checkingUP":
+			data.SeedingCount++
 		}
 
-		// Sort active by download speed descending, take top 10
-		sort.Slice(active, func(i, j int) bool {
-			return active[i].DLSpeed > active[j].DLSpeed
-		})
-		if len(active) > 10 {
-			active = active[:10]
+		if t.DLSpeed > 0 || t.UPSpeed > 1024 || (t.Progress < 100 && t.Progress > 0) {
+			data.ActiveCount++
+			active = append(active, models.TorrentBrief{
+				Name:     t.Name,
+				State:    t.State,
+				Progress: t.Progress,
+				DLSpeed:  t.DLSpeed,
+				UPSpeed:  t.UPSpeed,
+				Size:     t.Size,
+				Ratio:    t.Ratio,
+			})
 		}
-		data.TopTorrents = active
 	}
 
-	q.store.UpdateTorrents(data)
-	return nil
+	if ratioCount > 0 {
+		data.Ratio = allRatio / float64(ratioCount)
+	}
+
+	// Sort active by download speed descending, take top 10
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].DLSpeed > active[j].DLSpeed
+	})
+	if len(active) > 10 {
+		active = active[:10]
+	}
+	data.TopTorrents = active
+	return data
+}
+
+// ensureLogin signs in unless the session is already authenticated.
+func (q *QbitTransferCollector) ensureLogin(ctx context.Context) error {
+	q.authMu.Lock()
+	defer q.authMu.Unlock()
+	if q.authed {
+		return nil
+	}
+	return q.login(ctx)
+}
+
+// relogin signs in again after the session cookie was rejected.
+func (q *QbitTransferCollector) relogin(ctx context.Context) error {
+	q.logout()
+	return q.ensureLogin(ctx)
+}
+
+func (q *QbitTransferCollector) logout() {
+	q.authMu.Lock()
+	q.authed = false
+	q.authMu.Unlock()
 }
 
+// login signs in with the configured credentials. Caller must hold q.authMu.
 func (q *QbitTransferCollector) login(ctx context.Context) error {
+	cfg := q.cfg.Load()
 	form := url.Values{
-		"username": {q.cfg.QbitUsername},
-		"password": {q.cfg.QbitPassword},
+		"username": {cfg.QbitUsername},
+		"password": {cfg.QbitPassword},
 	}
 	req, err := http.NewRequestWithContext(ctx, "POST",
-		q.cfg.QbitURL+"/api/v2/auth/login",
+		cfg.QbitURL+"/api/v2/auth/login",
 		strings.NewReader(form.Encode()))
 	if err != nil {
 		return err
@@ -151,36 +282,139 @@ func (q *QbitTransferCollector) login(ctx context.Context) error {
 	if resp.StatusCode != 200 {
 		return fmt.Errorf("qbit login: status %d", resp.StatusCode)
 	}
+	// Bad credentials still get 200, with "Fails." instead of "Ok.".
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return fmt.Errorf("qbit login: %w", err)
+	}
+	if reply := strings.TrimSpace(string(body)); reply != "Ok." {
+		return fmt.Errorf("qbit login: rejected (%q)", reply)
+	}
 	q.authed = true
 	return nil
 }
 
-func (q *QbitTransferCollector) getJSON(ctx context.Context, path string) (any, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", q.cfg.QbitURL+path, nil)
+// getJSON decodes the reply to a WebUI API GET, signing in once more if
+// the session has expired.
+func (q *QbitTransferCollector) getJSON(ctx context.Context, path string, out any) error {
+	err := q.tryGetJSON(ctx, path, out)
+	if errors.Is(err, errQbitForbidden) {
+		if err := q.relogin(ctx); err != nil {
+			return err
+		}
+		err = q.tryGetJSON(ctx, path, out)
+	}
+	return err
+}
+
+// errQbitForbidden marks a request refused for lack of a valid session.
+var errQbitForbidden = errors.New("qbit: forbidden")
+
+func (q *QbitTransferCollector) tryGetJSON(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", q.cfg.Load().QbitURL+path, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	resp, err := q.client.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == 403 {
-		q.authed = false
-		if err := q.login(ctx); err != nil {
+		return fmt.Errorf("qbit %s: %w", strings.SplitN(path, "?", 2)[0], errQbitForbidden)
+	}
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("qbit %s: status %d", strings.SplitN(path, "?", 2)[0], resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ErrQbitUnsupported is returned by Command for WebUI API endpoints this
+// qBittorrent version does not have.
+var ErrQbitUnsupported = errors.New("qbittorrent: endpoint not supported")
+
+// Command posts a WebUI API command (e.g. "/api/v2/torrents/recheck")
+// through the collector's session, signing in first if needed and once
+// more if the session has expired. It returns the response body.
+func (q *QbitTransferCollector) Command(ctx context.Context, path string, form url.Values) ([]byte, error) {
+	if q.cfg.Load().QbitPassword == "" {
+		return nil, ErrNotConfigured
+	}
+	if err := q.ensureLogin(ctx); err != nil {
+		return nil, err
+	}
+	body, status, err := q.post(ctx, path, form)
+	if err == nil && status == 403 {
+		if err := q.relogin(ctx); err != nil {
 			return nil, err
 		}
-		return q.getJSON(ctx, path)
+		body, status, err = q.post(ctx, path, form)
 	}
+	switch {
+	case err != nil:
+		return nil, err
+	case status == 404 || status == 405:
+		return nil, ErrQbitUnsupported
+	case status != 200:
+		if msg := strings.TrimSpace(string(body)); msg != "" {
+			return nil, fmt.Errorf("qbit %s: status %d: %s", path, status, msg)
+		}
+		return nil, fmt.Errorf("qbit %s: status %d", path, status)
+	}
+	return body, nil
+}
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("qbit %s: status %d", path, resp.StatusCode)
+// AltSpeedLimits reports whether the alternative speed limits are on.
+func (q *QbitTransferCollector) AltSpeedLimits(ctx context.Context) (bool, error) {
+	if q.cfg.Load().QbitPassword == "" {
+		return false, ErrNotConfigured
 	}
+	if err := q.ensureLogin(ctx); err != nil {
+		return false, err
+	}
+	var mode int
+	if err := q.getJSON(ctx, "/api/v2/transfer/speedLimitsMode", &mode); err != nil {
+		return false, err
+	}
+	return mode == 1, nil
+}
 
-	var result any
-	return result, json.NewDecoder(resp.Body).Decode(&result)
+// ListenPort returns the incoming connections port of qBittorrent.
+func (q *QbitTransferCollector) ListenPort(ctx context.Context) (int, error) {
+	if q.cfg.Load().QbitPassword == "" {
+		return 0, ErrNotConfigured
+	}
+	if err := q.ensureLogin(ctx); err != nil {
+		return 0, err
+	}
+	var prefs struct {
+		ListenPort int `json:"listen_port"`
+	}
+	if err := q.getJSON(ctx, "/api/v2/app/preferences", &prefs); err != nil {
+		return 0, err
+	}
+	return prefs.ListenPort, nil
+}
+
+func (q *QbitTransferCollector) post(ctx context.Context, path string, form url.Values) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", q.cfg.Load().QbitURL+path,
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	return body, resp.StatusCode, err
 }
 
 func jsonStr(v any) string {
@@ -203,3 +437,12 @@ func jsonUint64(v any) uint64 {
 	}
 	return 0
 }
+
+// jsonUnix converts a unix timestamp; zero and negative values (qBittorrent
+// uses -1 for "never") give the zero time.
+func jsonUnix(v any) time.Time {
+	if f, ok := v.(float64); ok && f > 0 {
+		return time.Unix(int64(f), 0)
+	}
+	return time.Time{}
+}
@@ -31,7 +31,7 @@ func (r *RadarrCollector) Name() string { return "radarr" }
 
 func (r *RadarrCollector) Collect(ctx context.Context) error {
 	if r.cfg.RadarrAPIKey == "" {
-		return nil
+		return ErrNotConfigured
 	}
 
 	downloads, err := r.getQueue(ctx)
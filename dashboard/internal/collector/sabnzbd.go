@@ -31,7 +31,7 @@ func (s *SabnzbdCollector) Name() string { return "sabnzbd" }
 
 func (s *SabnzbdCollector) Collect(ctx context.Context) error {
 	if s.cfg.SabnzbdAPIKey == "" {
-		return nil
+		return ErrNotConfigured
 	}
 
 	url := fmt.Sprintf("%s/api?mode=queue&output=json&apikey=%s", s.cfg.SabnzbdURL, s.cfg.SabnzbdAPIKey)
@@ -32,7 +32,7 @@ func (s *SeerrCollector) Name() string { return "seerr" }
 
 func (s *SeerrCollector) Collect(ctx context.Context) error {
 	if s.cfg.SeerrAPIKey == "" {
-		return nil
+		return ErrNotConfigured
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET",
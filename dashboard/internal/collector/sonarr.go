@@ -31,7 +31,7 @@ func (s *SonarrCollector) Name() string { return "sonarr" }
 
 func (s *SonarrCollector) Collect(ctx context.Context) error {
 	if s.cfg.SonarrAPIKey == "" {
-		return nil
+		return ErrNotConfigured
 	}
 
 	downloads, err := s.getQueue(ctx)
@@ -0,0 +1,352 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"arcticmon/internal/config"
+	"arcticmon/internal/models"
+	"arcticmon/internal/store"
+)
+
+// maxPolicyActions is the number of remediations kept in the report.
+const maxPolicyActions = 50
+
+// policyDownloadStates are the states of incomplete torrents the policy
+// checks; paused, queued, checking and errored torrents are left alone.
+var policyDownloadStates = []string{"downloading", "forcedDL", "metaDL", "forcedMetaDL", "stalledDL"}
+
+// torrentTracker remembers when each torrent last made download progress
+// and since when it has had no seeds, along with recent remediations. It
+// lives on the Orchestrator so this history survives config reloads.
+type torrentTracker struct {
+	mu      sync.Mutex
+	seen    map[string]*torrentSeen
+	actions []models.TorrentFlag
+}
+
+type torrentSeen struct {
+	downloaded  uint64
+	progressAt  time.Time
+	seedsGoneAt time.Time // zero while the torrent has seeds
+}
+
+func newTorrentTracker() *torrentTracker {
+	return &torrentTracker{seen: make(map[string]*torrentSeen)}
+}
+
+// observe updates and returns the history of t. Caller must hold tr.mu.
+func (tr *torrentTracker) observe(t models.Torrent, now time.Time) *torrentSeen {
+	s := tr.seen[t.Hash]
+	if s == nil {
+		// First sighting (or restart): the last transfer is the best
+		// guess of the last progress.
+		s = &torrentSeen{downloaded: t.Downloaded, progressAt: t.AddedOn}
+		if t.LastActivity.After(s.progressAt) {
+			s.progressAt = t.LastActivity
+		}
+		if s.progressAt.IsZero() || s.progressAt.After(now) || t.DLSpeed > 0 {
+			s.progressAt = now
+		}
+		tr.seen[t.Hash] = s
+	} else if t.Downloaded > s.downloaded || t.DLSpeed > 0 || t.Progress >= 100 {
+		s.progressAt = now
+	}
+	s.downloaded = t.Downloaded
+
+	if t.Seeds > 0 || t.SwarmSeeds > 0 {
+		s.seedsGoneAt = time.Time{}
+	} else if s.seedsGoneAt.IsZero() {
+		s.seedsGoneAt = now
+	}
+	return s
+}
+
+// record adds finished remediations and returns the recent ones, newest
+// first.
+func (tr *torrentTracker) record(flags []models.TorrentFlag) []models.TorrentFlag {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	for _, f := range flags {
+		if f.Outcome == "done" || f.Outcome == "error" {
+			tr.actions = append([]models.TorrentFlag{f}, tr.actions...)
+		}
+	}
+	if len(tr.actions) > maxPolicyActions {
+		tr.actions = tr.actions[:maxPolicyActions]
+	}
+	return append([]models.TorrentFlag{}, tr.actions...)
+}
+
+// TorrentPolicy applies the stalled/dead torrent and seeding target rules
+// to the qBittorrent torrent list. Flagged downloads owned by Sonarr or
+// Radarr are removed through their queue, which blocklists the release
+// and searches again; completed torrents are removed from qBittorrent
+// with their files kept. Nothing is removed unless remediation is on and
+// dry-run is off.
+type TorrentPolicy struct {
+	cfg     *config.Config
+	store   *store.Store
+	qbit    *QbitTransferCollector
+	tracker *torrentTracker
+	client  *http.Client
+}
+
+func NewTorrentPolicy(cfg *config.Config, s *store.Store, qbit *QbitTransferCollector, tracker *torrentTracker) *TorrentPolicy {
+	return &TorrentPolicy{
+		cfg:     cfg,
+		store:   s,
+		qbit:    qbit,
+		tracker: tracker,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *TorrentPolicy) Name() string { return "torrent-policy" }
+
+func (p *TorrentPolicy) Collect(ctx context.Context) error {
+	if !p.cfg.CollectorEnabled("qbittorrent") || p.cfg.QbitPassword == "" {
+		return ErrNotConfigured
+	}
+
+	// Only judge torrents on fresh data.
+	var lastSuccess *time.Time
+	for _, st := range p.store.Get().Collectors {
+		if st.Name == "qbittorrent" {
+			lastSuccess = st.LastSuccess
+		}
+	}
+	if lastSuccess == nil {
+		return nil
+	}
+	if age := time.Since(*lastSuccess); age > 3*p.cfg.Interval("qbittorrent") {
+		return fmt.Errorf("qbittorrent data is stale (last collected %s ago)", age.Round(time.Second))
+	}
+
+	now := time.Now()
+	flags, checked := p.evaluate(p.store.TorrentList(), now)
+	report := models.TorrentPolicyReport{
+		Time:      now,
+		Remediate: p.cfg.TorrentRemediate,
+		DryRun:    p.cfg.TorrentDryRun,
+		Checked:   checked,
+		Flagged:   flags,
+	}
+
+	var err error
+	if p.cfg.TorrentRemediate && len(flags) > 0 {
+		var owners map[string]arrQueueItem
+		// Without every queue a torrent still being imported could look
+		// unowned, so nothing is removed this run.
+		if owners, err = p.arrQueues(ctx); err == nil {
+			for i := range flags {
+				p.remediate(ctx, &flags[i], owners)
+			}
+		}
+	}
+	report.Actions = p.tracker.record(flags)
+	p.store.UpdateTorrentPolicy(report)
+	return err
+}
+
+// evaluate returns the torrents matching a rule and the number checked.
+func (p *TorrentPolicy) evaluate(list []models.Torrent, now time.Time) ([]models.TorrentFlag, int) {
+	p.tracker.mu.Lock()
+	defer p.tracker.mu.Unlock()
+
+	flags := []models.TorrentFlag{}
+	checked := 0
+	present := make(map[string]bool, len(list))
+	for _, t := range list {
+		present[t.Hash] = true
+		seen := p.tracker.observe(t, now)
+		if len(p.cfg.TorrentCategories) > 0 && !slices.Contains(p.cfg.TorrentCategories, t.Category) {
+			continue
+		}
+		checked++
+		if rule, reason := p.match(t, seen, now); rule != "" {
+			flags = append(flags, models.TorrentFlag{
+				Time:     now,
+				Hash:     t.Hash,
+				Name:     t.Name,
+				Category: t.Category,
+				State:    t.State,
+				Rule:     rule,
+				Reason:   reason,
+			})
+		}
+	}
+	for hash := range p.tracker.seen {
+		if !present[hash] {
+			delete(p.tracker.seen, hash)
+		}
+	}
+	return flags, checked
+}
+
+// match returns the first rule t breaks, if any, and why.
+func (p *TorrentPolicy) match(t models.Torrent, seen *torrentSeen, now time.Time) (string, string) {
+	if t.Progress < 100 {
+		if !slices.Contains(policyDownloadStates, t.State) {
+			return "", ""
+		}
+		if d := p.cfg.TorrentStalledAfter; d > 0 && now.Sub(seen.progressAt) >= d {
+			return "stalled", "no download progress for " + hours(now.Sub(seen.progressAt))
+		}
+		if d := p.cfg.TorrentNoSeedsAfter; d > 0 && !seen.seedsGoneAt.IsZero() && now.Sub(seen.seedsGoneAt) >= d {
+			return "no-seeds", "no seeds for " + hours(now.Sub(seen.seedsGoneAt))
+		}
+		return "", ""
+	}
+	if r := p.cfg.TorrentRatioTarget; r > 0 && t.Ratio >= r {
+		return "ratio", fmt.Sprintf("ratio %.2f reached the %.2f target", t.Ratio, r)
+	}
+	if d := p.cfg.TorrentSeedTimeTarget; d > 0 {
+		if seeded := time.Duration(t.SeedingTime) * time.Second; seeded >= d {
+			return "seed-time", fmt.Sprintf("seeded for %s, target %s", hours(seeded), hours(d))
+		}
+	}
+	return "", ""
+}
+
+// remediate decides and, unless in dry-run, carries out the action on a
+// flagged torrent. Downloads no arr owns and completed torrents an arr is
+// still importing are skipped.
+func (p *TorrentPolicy) remediate(ctx context.Context, f *models.TorrentFlag, owners map[string]arrQueueItem) {
+	item, owned := owners[f.Hash]
+	if owned {
+		f.Owner = item.source
+	}
+	complete := f.Rule == "ratio" || f.Rule == "seed-time"
+	switch {
+	case complete && !owned:
+		f.Action = "remove"
+	case !complete && owned:
+		f.Action = "blocklist-and-search"
+	default:
+		f.Outcome = "skipped"
+		return
+	}
+	if p.cfg.TorrentDryRun {
+		f.Outcome = "dry-run"
+		return
+	}
+
+	var err error
+	if f.Action == "remove" {
+		_, err = p.qbit.Command(ctx, "/api/v2/torrents/delete", url.Values{
+			"hashes":      {f.Hash},
+			"deleteFiles": {"false"},
+		})
+	} else {
+		err = p.arrRemove(ctx, item)
+	}
+	if err != nil {
+		f.Outcome, f.Error = "error", err.Error()
+		log.Printf("[torrent-policy] %s %q (%s): %v", f.Action, f.Name, f.Reason, err)
+		return
+	}
+	f.Outcome = "done"
+	log.Printf("[torrent-policy] %s %q (%s)", f.Action, f.Name, f.Reason)
+}
+
+// arrQueueItem is a Sonarr or Radarr queue record of a torrent.
+type arrQueueItem struct {
+	source string
+	url    string
+	apiKey string
+	id     int
+}
+
+// arrQueues maps the torrent hashes of the Sonarr and Radarr queues to
+// their queue records.
+func (p *TorrentPolicy) arrQueues(ctx context.Context) (map[string]arrQueueItem, error) {
+	owners := map[string]arrQueueItem{}
+	arrs := []arrQueueItem{
+		{source: "Sonarr", url: p.cfg.SonarrURL, apiKey: p.cfg.SonarrAPIKey},
+		{source: "Radarr", url: p.cfg.RadarrURL, apiKey: p.cfg.RadarrAPIKey},
+	}
+	for _, arr := range arrs {
+		if arr.apiKey == "" {
+			continue
+		}
+		if err := p.arrQueue(ctx, arr, owners); err != nil {
+			return nil, err
+		}
+	}
+	return owners, nil
+}
+
+func (p *TorrentPolicy) arrQueue(ctx context.Context, arr arrQueueItem, owners map[string]arrQueueItem) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", arr.url+"/api/v3/queue?pageSize=1000", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Api-Key", arr.apiKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("%s queue: status %d", strings.ToLower(arr.source), resp.StatusCode)
+	}
+
+	var result struct {
+		Records []struct {
+			ID         int    `json:"id"`
+			DownloadID string `json:"downloadId"`
+			Protocol   string `json:"protocol"`
+		} `json:"records"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	for _, rec := range result.Records {
+		if rec.Protocol != "torrent" || rec.DownloadID == "" {
+			continue
+		}
+		item := arr
+		item.id = rec.ID
+		// The arrs report qBittorrent hashes in upper case.
+		owners[strings.ToLower(rec.DownloadID)] = item
+	}
+	return nil
+}
+
+// arrRemove removes a queue item and its torrent, blocklisting the
+// release so the arr searches for another one.
+func (p *TorrentPolicy) arrRemove(ctx context.Context, item arrQueueItem) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE",
+		item.url+"/api/v3/queue/"+strconv.Itoa(item.id)+"?removeFromClient=true&blocklist=true&skipRedownload=false", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Api-Key", item.apiKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("%s queue delete: status %d", strings.ToLower(item.source), resp.StatusCode)
+	}
+	return nil
+}
+
+func hours(d time.Duration) string {
+	return strconv.FormatFloat(d.Hours(), 'f', 1, 64) + "h"
+}
@@ -0,0 +1,197 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"arcticmon/internal/config"
+	"arcticmon/internal/models"
+)
+
+func testPolicy() *TorrentPolicy {
+	cfg := &config.Config{
+		TorrentStalledAfter:   6 * time.Hour,
+		TorrentNoSeedsAfter:   12 * time.Hour,
+		TorrentRatioTarget:    2,
+		TorrentSeedTimeTarget: 48 * time.Hour,
+		TorrentCategories:     []string{"tv", "movies"},
+	}
+	return NewTorrentPolicy(cfg, nil, nil, newTorrentTracker())
+}
+
+func TestTorrentPolicyRules(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(h float64) time.Time { return now.Add(-time.Duration(h * float64(time.Hour))) }
+
+	tests := []struct {
+		name       string
+		torrent    models.Torrent
+		wantRule   string
+		wantReason string
+	}{
+		{
+			name:       "stalled download",
+			torrent:    models.Torrent{State: "stalledDL", Progress: 40, AddedOn: ago(30), LastActivity: ago(7), Seeds: 1},
+			wantRule:   "stalled",
+			wantReason: "no download progress for 7.0h",
+		},
+		{
+			name:    "recent activity",
+			torrent: models.Torrent{State: "downloading", Progress: 40, AddedOn: ago(30), LastActivity: ago(1), Seeds: 1},
+		},
+		{
+			name:    "downloading right now",
+			torrent: models.Torrent{State: "downloading", Progress: 40, AddedOn: ago(30), LastActivity: ago(7), DLSpeed: 1000, Seeds: 1},
+		},
+		{
+			name:    "paused downloads are left alone",
+			torrent: models.Torrent{State: "pausedDL", Progress: 40, AddedOn: ago(30), LastActivity: ago(20)},
+		},
+		{
+			name:    "activity in the future",
+			torrent: models.Torrent{State: "downloading", Progress: 40, AddedOn: ago(30), LastActivity: now.Add(time.Hour), Seeds: 1},
+		},
+		{
+			name:       "ratio target",
+			torrent:    models.Torrent{State: "uploading", Progress: 100, Ratio: 2.5, SeedingTime: 3600},
+			wantRule:   "ratio",
+			wantReason: "ratio 2.50 reached the 2.00 target",
+		},
+		{
+			name:       "seed time target",
+			torrent:    models.Torrent{State: "stalledUP", Progress: 100, Ratio: 0.4, SeedingTime: 50 * 3600},
+			wantRule:   "seed-time",
+			wantReason: "seeded for 50.0h, target 48.0h",
+		},
+		{
+			name:    "seeding below the targets",
+			torrent: models.Torrent{State: "uploading", Progress: 100, Ratio: 1.2, SeedingTime: 10 * 3600},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.torrent.Hash, tt.torrent.Category = "h1", "tv"
+			flags, checked := testPolicy().evaluate([]models.Torrent{tt.torrent}, now)
+			if checked != 1 {
+				t.Errorf("checked = %d, want 1", checked)
+			}
+			if tt.wantRule == "" {
+				if len(flags) != 0 {
+					t.Errorf("flags = %+v, want none", flags)
+				}
+				return
+			}
+			if len(flags) != 1 || flags[0].Rule != tt.wantRule || flags[0].Reason != tt.wantReason {
+				t.Errorf("flags = %+v, want rule %s (%s)", flags, tt.wantRule, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestTorrentPolicyCategories(t *testing.T) {
+	now := time.Now()
+	stalled := models.Torrent{State: "stalledDL", Progress: 10, AddedOn: now.Add(-48 * time.Hour)}
+	music, tv := stalled, stalled
+	music.Hash, music.Category = "m", "music"
+	tv.Hash, tv.Category = "t", "tv"
+
+	flags, checked := testPolicy().evaluate([]models.Torrent{music, tv}, now)
+	if checked != 1 || len(flags) != 1 || flags[0].Hash != "t" {
+		t.Errorf("evaluate() = %+v, %d checked, want only the tv torrent", flags, checked)
+	}
+}
+
+// TestTorrentPolicyHistory follows torrents across runs, where the rules
+// depend on what earlier runs saw.
+func TestTorrentPolicyHistory(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	type step struct {
+		after      time.Duration
+		downloaded uint64
+		seeds      int
+		wantRule   string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "progress keeps it alive",
+			steps: []step{
+				{after: 0, downloaded: 100, seeds: 1},
+				{after: 5 * time.Hour, downloaded: 200, seeds: 1},
+				{after: 10 * time.Hour, downloaded: 300, seeds: 1},
+			},
+		},
+		{
+			name: "no progress since the first run",
+			steps: []step{
+				{after: 0, downloaded: 100, seeds: 1},
+				{after: 5 * time.Hour, downloaded: 100, seeds: 1},
+				{after: 6 * time.Hour, downloaded: 100, seeds: 1, wantRule: "stalled"},
+			},
+		},
+		{
+			name: "no seeds while still progressing",
+			steps: []step{
+				{after: 0, downloaded: 100},
+				{after: 11 * time.Hour, downloaded: 200},
+				{after: 12 * time.Hour, downloaded: 300, wantRule: "no-seeds"},
+			},
+		},
+		{
+			name: "seeds came back",
+			steps: []step{
+				{after: 0, downloaded: 100},
+				{after: 11 * time.Hour, downloaded: 200, seeds: 2},
+				{after: 13 * time.Hour, downloaded: 300},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testPolicy()
+			for i, s := range tt.steps {
+				tor := models.Torrent{
+					Hash: "h1", Category: "movies", State: "downloading", Progress: 50,
+					AddedOn: start, LastActivity: start, Downloaded: s.downloaded, Seeds: s.seeds,
+				}
+				flags, _ := p.evaluate([]models.Torrent{tor}, start.Add(s.after))
+				got := ""
+				if len(flags) > 0 {
+					got = flags[0].Rule
+				}
+				if got != s.wantRule {
+					t.Fatalf("run %d: rule %q, want %q", i, got, s.wantRule)
+				}
+			}
+		})
+	}
+
+	// Torrents that left qBittorrent are forgotten.
+	p := testPolicy()
+	p.evaluate([]models.Torrent{{Hash: "gone", Category: "tv", State: "downloading"}}, start)
+	p.evaluate(nil, start)
+	if len(p.tracker.seen) != 0 {
+		t.Errorf("tracker kept %d removed torrents", len(p.tracker.seen))
+	}
+}
+
+func TestTorrentTrackerRecord(t *testing.T) {
+	tr := newTorrentTracker()
+	var flags []models.TorrentFlag
+	for i := 0; i < maxPolicyActions+5; i++ {
+		flags = append(flags, models.TorrentFlag{Hash: string(rune('a' + i%26)), Outcome: "done"})
+	}
+	flags = append(flags, models.TorrentFlag{Hash: "dry", Outcome: "dry-run"})
+
+	got := tr.record(flags)
+	if len(got) != maxPolicyActions {
+		t.Fatalf("record() kept %d actions, want %d", len(got), maxPolicyActions)
+	}
+	for _, f := range got {
+		if f.Outcome == "dry-run" {
+			t.Error("dry-run flag recorded as an action")
+		}
+	}
+}
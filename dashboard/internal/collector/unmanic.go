@@ -34,15 +34,17 @@ func (u *UnmanicCollector) Collect(ctx context.Context) error {
 
 	// Workers
 	workers, err := u.getWorkers(ctx)
-	if err == nil {
-		data.Workers = workers
+	if err != nil {
+		return err
 	}
+	data.Workers = workers
 
 	// Pending
 	pending, err := u.getPending(ctx)
-	if err == nil {
-		data.Pending = pending
+	if err != nil {
+		return err
 	}
+	data.Pending = pending
 
 	u.store.UpdateTranscodes(data)
 	return nil
@@ -0,0 +1,286 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"arcticmon/internal/config"
+	"arcticmon/internal/models"
+	"arcticmon/internal/store"
+)
+
+// errNoContainer marks a container Docker does not know.
+var errNoContainer = errors.New("no such container")
+
+// egressScript prints the body of the URL in $0 with whichever HTTP client
+// the image ships: curl, busybox wget or Python.
+const egressScript = `curl -fsS -m 10 "$0" 2>/dev/null || wget -qO- -T 10 "$0" 2>/dev/null || python3 -c 'import sys, urllib.request; print(urllib.request.urlopen(sys.argv[1], timeout=10).read().decode())' "$0"`
+
+// VPNLeakCollector checks that the containers meant to run behind the VPN
+// share the gateway's network namespace, and that the IP they reach the
+// echo service from is not the host's.
+type VPNLeakCollector struct {
+	cfg    *config.Config
+	store  *store.Store
+	docker *http.Client
+	client *http.Client
+}
+
+func NewVPNLeakCollector(cfg *config.Config, s *store.Store) *VPNLeakCollector {
+	return &VPNLeakCollector{
+		cfg:   cfg,
+		store: s,
+		docker: &http.Client{
+			// Covers the in-container fetch and its 10s timeout.
+			Timeout: 15 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", cfg.DockerSocket)
+				},
+			},
+		},
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (v *VPNLeakCollector) Name() string { return "vpn-leak" }
+
+// containerInfo is the part of a container inspect the check needs.
+type containerInfo struct {
+	ID    string `json:"Id"`
+	Name  string `json:"Name"`
+	State struct {
+		Running bool `json:"Running"`
+	} `json:"State"`
+	HostConfig struct {
+		NetworkMode string `json:"NetworkMode"`
+	} `json:"HostConfig"`
+}
+
+func (v *VPNLeakCollector) Collect(ctx context.Context) error {
+	if len(v.cfg.VPNContainers) == 0 || v.cfg.VPNEchoURL == "" {
+		return ErrNotConfigured
+	}
+
+	gateway, err := v.inspect(ctx, v.cfg.VPNGateway)
+	if err != nil && !errors.Is(err, errNoContainer) {
+		return err
+	}
+
+	data := models.VPNLeakData{CheckedAt: time.Now(), Containers: []models.VPNContainerCheck{}}
+	hostIP, hostErr := v.hostIP(ctx)
+	if hostErr == nil {
+		data.HostIP = hostIP.String()
+	}
+
+	var warnings []models.HealthWarning
+	for _, name := range v.cfg.VPNContainers {
+		check := models.VPNContainerCheck{Name: name}
+		info, err := v.inspect(ctx, name)
+		if errors.Is(err, errNoContainer) {
+			check.Error = "container not found"
+			data.Containers = append(data.Containers, check)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		check.NetworkMode = info.HostConfig.NetworkMode
+		// Compose resolves service:gluetun to container:<id>; a container
+		// started by hand may reference the name instead.
+		ref, shared := strings.CutPrefix(check.NetworkMode, "container:")
+		check.BehindVPN = shared && gateway.ID != "" &&
+			(ref == gateway.ID || ref == strings.TrimPrefix(gateway.Name, "/"))
+		if !check.BehindVPN {
+			warnings = append(warnings, models.HealthWarning{
+				Source:  "VPN",
+				Type:    "critical",
+				Message: fmt.Sprintf("%s is not in the network namespace of %s (network mode %q)", name, v.cfg.VPNGateway, check.NetworkMode),
+			})
+		}
+
+		if info.State.Running && hostErr == nil {
+			ip, err := v.egressIP(ctx, name)
+			switch {
+			case err != nil:
+				// Gluetun's firewall blocks all traffic while the tunnel is
+				// down, so a failed fetch is not a leak.
+				check.Error = err.Error()
+				warnings = append(warnings, models.HealthWarning{
+					Source:  "VPN",
+					Type:    "warning",
+					Message: fmt.Sprintf("cannot check the egress IP of %s: %v", name, err),
+				})
+			case ip == hostIP:
+				check.EgressIP, check.Leak = ip.String(), true
+				warnings = append(warnings, models.HealthWarning{
+					Source:  "VPN",
+					Type:    "critical",
+					Message: fmt.Sprintf("%s reaches the Internet from the host IP %s: traffic is leaking outside the VPN", name, ip),
+				})
+			default:
+				check.EgressIP = ip.String()
+			}
+		}
+		data.Containers = append(data.Containers, check)
+	}
+
+	v.store.UpdateVPNLeak(data)
+	v.store.ReplaceHealth("VPN", warnings)
+	if hostErr != nil {
+		return fmt.Errorf("host egress IP: %w", hostErr)
+	}
+	return nil
+}
+
+// hostIP returns the IP arcticmon itself reaches the echo service from,
+// which is the host's since it does not run behind the VPN.
+func (v *VPNLeakCollector) hostIP(ctx context.Context) (netip.Addr, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", v.cfg.VPNEchoURL, nil)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return netip.Addr{}, fmt.Errorf("echo %s: status %d", v.cfg.VPNEchoURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return parseEchoIP(body)
+}
+
+// egressIP fetches the echo URL from inside the container.
+func (v *VPNLeakCollector) egressIP(ctx context.Context, name string) (netip.Addr, error) {
+	payload, _ := json.Marshal(map[string]any{
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Cmd":          []string{"sh", "-c", egressScript, v.cfg.VPNEchoURL},
+	})
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := v.post(ctx, "/containers/"+name+"/exec", payload, 201, &created); err != nil {
+		return netip.Addr{}, err
+	}
+
+	var out bytes.Buffer
+	start := []byte(`{"Detach":false,"Tty":false}`)
+	if err := v.post(ctx, "/exec/"+created.ID+"/start", start, 200, &out); err != nil {
+		return netip.Addr{}, err
+	}
+	stdout := demuxDockerStream(out.Bytes())
+	if len(bytes.TrimSpace(stdout)) == 0 {
+		return netip.Addr{}, errors.New("echo service unreachable from the container")
+	}
+	return parseEchoIP(stdout)
+}
+
+// inspect returns the container's inspect data, or errNoContainer.
+func (v *VPNLeakCollector) inspect(ctx context.Context, name string) (containerInfo, error) {
+	var info containerInfo
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://docker/containers/"+name+"/json", nil)
+	if err != nil {
+		return info, err
+	}
+	resp, err := v.docker.Do(req)
+	if err != nil {
+		return info, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return info, fmt.Errorf("%s: %w", name, errNoContainer)
+	}
+	if resp.StatusCode != 200 {
+		return info, fmt.Errorf("docker inspect %s: status %d", name, resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	return info, err
+}
+
+// post sends a Docker API request. out is a *bytes.Buffer for raw output,
+// anything else is decoded as JSON.
+func (v *VPNLeakCollector) post(ctx context.Context, path string, body []byte, want int, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", "http://docker"+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.docker.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != want {
+		return fmt.Errorf("docker %s: status %d", path, resp.StatusCode)
+	}
+	if buf, ok := out.(*bytes.Buffer); ok {
+		_, err = io.Copy(buf, io.LimitReader(resp.Body, 64<<10))
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// demuxDockerStream returns the stdout frames of a multiplexed exec
+// stream: each frame has an 8-byte header holding the stream type and a
+// big-endian payload size.
+func demuxDockerStream(b []byte) []byte {
+	var stdout []byte
+	for len(b) >= 8 {
+		size := int(binary.BigEndian.Uint32(b[4:8]))
+		end := min(8+size, len(b))
+		if b[0] == 1 {
+			stdout = append(stdout, b[8:end]...)
+		}
+		b = b[end:]
+	}
+	return stdout
+}
+
+// parseEchoIP reads an echo service reply: the bare IP as plain text, or
+// JSON with an "ip" field.
+func parseEchoIP(body []byte) (netip.Addr, error) {
+	text := strings.TrimSpace(string(body))
+	if strings.HasPrefix(text, "{") {
+		var reply struct {
+			IP string `json:"ip"`
+		}
+		if err := json.Unmarshal([]byte(text), &reply); err != nil {
+			return netip.Addr{}, fmt.Errorf("echo reply: %w", err)
+		}
+		text = reply.IP
+	}
+	ip, err := netip.ParseAddr(text)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("echo reply %q is not an IP", truncateReply(text))
+	}
+	return ip.Unmap(), nil
+}
+
+func truncateReply(s string) string {
+	if len(s) > 64 {
+		return s[:64] + "…"
+	}
+	return s
+}
@@ -0,0 +1,232 @@
+package collector
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"arcticmon/internal/config"
+	"arcticmon/internal/store"
+)
+
+func TestParseEchoIP(t *testing.T) {
+	tests := []struct {
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{body: "203.0.113.7\n", want: "203.0.113.7"},
+		{body: `{"ip":"2001:db8::1"}`, want: "2001:db8::1"},
+		{body: "::ffff:192.0.2.1", want: "192.0.2.1"},
+		{body: "<html>blocked</html>", wantErr: true},
+		{body: `{"ip":""}`, wantErr: true},
+		{body: "{broken", wantErr: true},
+	}
+	for _, tt := range tests {
+		ip, err := parseEchoIP([]byte(tt.body))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseEchoIP(%q) = %s, want an error", tt.body, ip)
+			}
+			continue
+		}
+		if err != nil || ip.String() != tt.want {
+			t.Errorf("parseEchoIP(%q) = %s, %v, want %s", tt.body, ip, err, tt.want)
+		}
+	}
+}
+
+// TestHostIP runs the host side of the check against a local echo stub.
+func TestHostIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "plain text",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, "198.51.100.20")
+			},
+			want: "198.51.100.20",
+		},
+		{
+			name: "json",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, `{"ip":"198.51.100.21"}`)
+			},
+			want: "198.51.100.21",
+		},
+		{
+			name: "error status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "down", http.StatusServiceUnavailable)
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			v := NewVPNLeakCollector(&config.Config{VPNEchoURL: srv.URL}, nil)
+			ip, err := v.hostIP(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("hostIP() = %s, want an error", ip)
+				}
+				return
+			}
+			if err != nil || ip.String() != tt.want {
+				t.Fatalf("hostIP() = %s, %v, want %s", ip, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDemuxDockerStream(t *testing.T) {
+	frame := func(stream byte, payload string) []byte {
+		b := make([]byte, 8, 8+len(payload))
+		b[0] = stream
+		binary.BigEndian.PutUint32(b[4:], uint32(len(payload)))
+		return append(b, payload...)
+	}
+	var stream []byte
+	stream = append(stream, frame(1, "203.0.")...)
+	stream = append(stream, frame(2, "curl: not found\n")...)
+	stream = append(stream, frame(1, "113.7\n")...)
+	// A frame cut short by the output limit keeps what arrived.
+	stream = append(stream, frame(1, "extra")[:10]...)
+
+	if got := string(demuxDockerStream(stream)); got != "203.0.113.7\nex" {
+		t.Errorf("demuxDockerStream() = %q, want %q", got, "203.0.113.7\nex")
+	}
+}
+
+// fakeDocker serves container inspects and exec on a unix socket. Exec in
+// any container prints egress.
+func fakeDocker(t *testing.T, containers map[string]string, egress string) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /containers/{name}/json", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		mode, ok := containers[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"Id":"id-%s","Name":"/%s","State":{"Running":true},"HostConfig":{"NetworkMode":%q}}`, name, name, mode)
+	})
+	mux.HandleFunc("POST /containers/{name}/exec", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"Id":"exec-%s"}`, r.PathValue("name"))
+	})
+	mux.HandleFunc("POST /exec/{id}/start", func(w http.ResponseWriter, r *http.Request) {
+		frame := make([]byte, 8, 8+len(egress))
+		frame[0] = 1
+		binary.BigEndian.PutUint32(frame[4:], uint32(len(egress)))
+		w.Write(append(frame, egress...))
+	})
+
+	sock := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(mux)
+	srv.Listener = ln
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return sock
+}
+
+func TestVPNLeakCollect(t *testing.T) {
+	const hostIP = "198.51.100.20"
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, hostIP)
+	}))
+	defer echo.Close()
+
+	tests := []struct {
+		name        string
+		networkMode string
+		egress      string
+		want        []string // messages of critical warnings
+	}{
+		{
+			name:        "behind the VPN",
+			networkMode: "container:id-gluetun",
+			egress:      "203.0.113.7\n",
+		},
+		{
+			name:        "network mode by gateway name",
+			networkMode: "container:gluetun",
+			egress:      "203.0.113.7\n",
+		},
+		{
+			name:        "egress from the host IP",
+			networkMode: "container:id-gluetun",
+			egress:      hostIP + "\n",
+			want:        []string{"qbittorrent reaches the Internet from the host IP 198.51.100.20: traffic is leaking outside the VPN"},
+		},
+		{
+			name:        "not in the gateway namespace",
+			networkMode: "bridge",
+			egress:      "203.0.113.7\n",
+			want:        []string{`qbittorrent is not in the network namespace of gluetun (network mode "bridge")`},
+		},
+		{
+			name:        "another container's namespace, leaking",
+			networkMode: "container:id-other",
+			egress:      hostIP + "\n",
+			want: []string{
+				`qbittorrent is not in the network namespace of gluetun (network mode "container:id-other")`,
+				"qbittorrent reaches the Internet from the host IP 198.51.100.20: traffic is leaking outside the VPN",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sock := fakeDocker(t, map[string]string{
+				"gluetun":     "bridge",
+				"qbittorrent": tt.networkMode,
+			}, tt.egress)
+			cfg := &config.Config{
+				DockerSocket:  sock,
+				VPNGateway:    "gluetun",
+				VPNContainers: []string{"qbittorrent"},
+				VPNEchoURL:    echo.URL,
+			}
+			s := store.New()
+			if err := NewVPNLeakCollector(cfg, s).Collect(context.Background()); err != nil {
+				t.Fatalf("Collect() = %v", err)
+			}
+
+			data := s.Get()
+			var got []string
+			for _, w := range data.Health {
+				if w.Source == "VPN" && w.Type == "critical" {
+					got = append(got, w.Message)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("critical warnings = %q, want %q", got, tt.want)
+			}
+			if data.VPNLeak.HostIP != hostIP {
+				t.Errorf("HostIP = %q, want %q", data.VPNLeak.HostIP, hostIP)
+			}
+			if c := data.VPNLeak.Containers[0]; c.Leak != (tt.egress == hostIP+"\n") {
+				t.Errorf("Leak = %v for egress %q", c.Leak, tt.egress)
+			}
+		})
+	}
+}
@@ -1,15 +1,61 @@
 package config
 
-import "os"
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFile is read when CONFIG_FILE is unset; it is optional.
+const DefaultFile = "/data/arcticmon.yml"
+
+// DefaultIntervals is the polling interval of every collector, keyed by
+// collector name. It also serves as the list of known collectors.
+var DefaultIntervals = map[string]time.Duration{
+	"host":             10 * time.Second,
+	"docker":           10 * time.Second,
+	"jellyfin":         10 * time.Second,
+	"qbittorrent":      10 * time.Second,
+	"unmanic":          10 * time.Second,
+	"seerr":            30 * time.Second,
+	"radarr":           30 * time.Second,
+	"sonarr":           30 * time.Second,
+	"sabnzbd":          30 * time.Second,
+	"jellyfin-library": 60 * time.Second,
+	"prowlarr":         60 * time.Second,
+	"bazarr":           60 * time.Second,
+	"ssh-security":     60 * time.Second,
+	"gluetun":          30 * time.Second,
+	"vpn-leak":         60 * time.Second,
+	"torrent-policy":   5 * time.Minute,
+}
+
+// DefaultTimeout bounds a single collection unless overridden per collector.
+const DefaultTimeout = 20 * time.Second
+
+// Mount is a filesystem monitored by the host collector.
+type Mount struct {
+	Path  string `yaml:"path"`
+	Label string `yaml:"label"`
+}
 
 type Config struct {
+	// File is the config file that was loaded, empty if none.
+	File string
+
 	ListenAddr string
 
 	JellyfinURL    string
 	JellyfinAPIKey string
 
 	QbitURL      string
-	QbitUsername  string
+	QbitUsername string
 	QbitPassword string
 
 	RadarrURL    string
@@ -32,6 +78,18 @@ type Config struct {
 
 	UnmanicURL string
 
+	// GluetunURL is Gluetun's control server; GluetunAPIKey is sent as
+	// X-API-Key when its routes require authentication.
+	GluetunURL    string
+	GluetunAPIKey string
+
+	// VPNContainers must share the network namespace of VPNGateway
+	// (network_mode: service:gluetun). The leak check also compares their
+	// egress IP with the host's, both as returned by VPNEchoURL.
+	VPNGateway    string
+	VPNContainers []string
+	VPNEchoURL    string
+
 	DockerSocket string
 	HostProcPath string
 	HostUtmpPath string
@@ -39,56 +97,553 @@ type Config struct {
 	DashboardUser string
 	DashboardPass string
 
+	// SSEMaxSubscribers limits concurrent /api/events streams; idle
+	// streams get a comment every SSEHeartbeat so proxies keep them open.
+	SSEMaxSubscribers int
+	SSEHeartbeat      time.Duration
+
+	// SessionTTL is the lifetime of a dashboard login session.
+	SessionTTL time.Duration
+	// SSOTokenTTL is the longest lifetime of an API token owned by a
+	// single sign-on identity, whose role is only re-checked when the
+	// owner signs in again.
+	SSOTokenTTL time.Duration
+
+	// GroupRoles maps identity provider or proxy groups to dashboard
+	// roles; users matching no group get DefaultRole (empty denies them).
+	GroupRoles  map[string]string
+	DefaultRole string
+
+	// OIDC single sign-on (authorization code flow with PKCE), enabled
+	// when OIDCIssuer is set.
+	OIDCIssuer        string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string
+	OIDCScopes        []string
+	OIDCUsernameClaim string
+	OIDCGroupsClaim   string
+
+	// Trusted reverse proxy authentication: ProxyUserHeader and
+	// ProxyGroupsHeader are honoured only from ProxyNetworks (CIDRs).
+	ProxyNetworks     []string
+	ProxyUserHeader   string
+	ProxyGroupsHeader string
+
+	// TrustedOrigins are extra origins (scheme://host[:port]) allowed to
+	// send state-changing requests, besides the dashboard's own host.
+	TrustedOrigins []string
+
+	// TrustedProxies are the networks (CIDRs) of reverse proxies whose
+	// X-Forwarded-For header gives the client IP.
+	TrustedProxies []string
+
+	// Failed sign-ins are delayed exponentially; after MaxFailures from one
+	// IP or for one username it is locked out for LockoutDuration.
+	MaxFailures     int
+	LockoutDuration time.Duration
+
 	PiholeURL      string
 	PiholePassword string
+
+	DataDir string
+
+	AlertRulesFile   string
+	AlertWebhookURL  string
+	AlertNtfyURL     string
+	AlertNtfyToken   string
+	AlertGotifyURL   string
+	AlertGotifyToken string
+
+	SMTPHost string
+	SMTPPort string
+	SMTPUser string
+	SMTPPass string
+	SMTPFrom string
+	SMTPTo   string
+
+	// ActionsAllow limits container actions to these names (empty means
+	// every stack container); ActionsDeny always wins.
+	ActionsAllow []string
+	ActionsDeny  []string
+
+	// Each user may start ActionsBurst actions at once, regaining one
+	// every ActionsInterval.
+	ActionsBurst    int
+	ActionsInterval time.Duration
+
+	// The torrent policy flags incomplete torrents without download
+	// progress for TorrentStalledAfter or without any seed for
+	// TorrentNoSeedsAfter, and completed ones past TorrentRatioTarget or
+	// TorrentSeedTimeTarget; zero disables a rule. Only TorrentCategories
+	// are checked when set. With TorrentRemediate flagged torrents are
+	// removed, blocklisted and searched again by the owning Sonarr or
+	// Radarr; TorrentDryRun reports the actions instead.
+	TorrentStalledAfter   time.Duration
+	TorrentNoSeedsAfter   time.Duration
+	TorrentRatioTarget    float64
+	TorrentSeedTimeTarget time.Duration
+	TorrentCategories     []string
+	TorrentRemediate      bool
+	TorrentDryRun         bool
+
+	Mounts       []Mount
+	ExternalURLs map[string]string
+	Intervals    map[string]time.Duration
+	Timeouts     map[string]time.Duration
+	Collectors   map[string]bool
+}
+
+// Load builds the configuration from defaults, then the YAML config file
+// (CONFIG_FILE, or DefaultFile if present), then environment variables.
+// The result is validated; a non-nil error lists every problem found.
+func Load() (*Config, error) {
+	cfg := defaults()
+
+	path := os.Getenv("CONFIG_FILE")
+	required := path != ""
+	if path == "" {
+		path = DefaultFile
+	}
+	if err := cfg.loadFile(path); err != nil {
+		if required || !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	} else {
+		cfg.File = path
+	}
+
+	cfg.applyEnv()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
-func Load() *Config {
+func defaults() *Config {
 	return &Config{
-		ListenAddr: envOr("LISTEN_ADDR", ":3000"),
+		ListenAddr: ":3000",
+
+		JellyfinURL:  "http://jellyfin:8096",
+		QbitURL:      "http://gluetun:8080",
+		QbitUsername: "admin",
+		RadarrURL:    "http://radarr:7878",
+		SonarrURL:    "http://sonarr:8989",
+		SeerrURL:     "http://seerr:5055",
+		ProwlarrURL:  "http://prowlarr:9696",
+		BazarrURL:    "http://bazarr:6767",
+		SabnzbdURL:   "http://sabnzbd:8080",
+		UnmanicURL:   "http://unmanic:8888",
+		GluetunURL:   "http://gluetun:8000",
+
+		VPNGateway:    "gluetun",
+		VPNContainers: []string{"qbittorrent", "flaresolverr"},
+		VPNEchoURL:    "https://api.ipify.org",
 
-		JellyfinURL:    envOr("JELLYFIN_URL", "http://jellyfin:8096"),
-		JellyfinAPIKey: os.Getenv("JELLYFIN_API_KEY"),
+		DockerSocket: "/var/run/docker.sock",
+		HostProcPath: "/host/proc",
+		HostUtmpPath: "/host/run/utmp",
 
-		QbitURL:      envOr("QBIT_URL", "http://gluetun:8080"),
-		QbitUsername:  envOr("QBIT_USERNAME", "admin"),
-		QbitPassword:  os.Getenv("QBIT_PASSWORD"),
+		PiholeURL: "http://192.168.1.254",
 
-		RadarrURL:    envOr("RADARR_URL", "http://radarr:7878"),
-		RadarrAPIKey: os.Getenv("RADARR_API_KEY"),
+		DataDir: "/data",
 
-		SonarrURL:    envOr("SONARR_URL", "http://sonarr:8989"),
-		SonarrAPIKey: os.Getenv("SONARR_API_KEY"),
+		SSEMaxSubscribers: 20,
+		SSEHeartbeat:      15 * time.Second,
 
-		SeerrURL:    envOr("SEERR_URL", "http://seerr:5055"),
-		SeerrAPIKey: os.Getenv("SEERR_API_KEY"),
+		SessionTTL:  24 * time.Hour,
+		SSOTokenTTL: 30 * 24 * time.Hour,
+		GroupRoles:  map[string]string{},
 
-		ProwlarrURL:    envOr("PROWLARR_URL", "http://prowlarr:9696"),
-		ProwlarrAPIKey: os.Getenv("PROWLARR_API_KEY"),
+		MaxFailures:     10,
+		LockoutDuration: 15 * time.Minute,
 
-		BazarrURL:    envOr("BAZARR_URL", "http://bazarr:6767"),
-		BazarrAPIKey: os.Getenv("BAZARR_API_KEY"),
+		OIDCScopes:        []string{"openid", "profile", "email", "groups"},
+		OIDCUsernameClaim: "preferred_username",
+		OIDCGroupsClaim:   "groups",
 
-		SabnzbdURL:    envOr("SABNZBD_URL", "http://sabnzbd:8080"),
-		SabnzbdAPIKey: os.Getenv("SABNZBD_API_KEY"),
+		ProxyUserHeader:   "Remote-User",
+		ProxyGroupsHeader: "Remote-Groups",
 
-		UnmanicURL: envOr("UNMANIC_URL", "http://unmanic:8888"),
+		SMTPPort: "587",
+		SMTPFrom: "arcticmon@localhost",
 
-		DockerSocket: envOr("DOCKER_SOCKET", "/var/run/docker.sock"),
-		HostProcPath: envOr("HOST_PROC", "/host/proc"),
-		HostUtmpPath: envOr("HOST_UTMP", "/host/run/utmp"),
+		// Never restart ourselves or the watchdog.
+		ActionsDeny: []string{"arcticmon", "autoheal"},
 
-		DashboardUser: os.Getenv("DASHBOARD_USER"),
-		DashboardPass: os.Getenv("DASHBOARD_PASS"),
+		ActionsBurst:    3,
+		ActionsInterval: 30 * time.Second,
+
+		TorrentStalledAfter: 24 * time.Hour,
+		TorrentNoSeedsAfter: 12 * time.Hour,
+		TorrentDryRun:       true,
+
+		Mounts: []Mount{
+			{Path: "/", Label: "NVMe (/)"},
+			{Path: "/mnt/media", Label: "HDD (/mnt/media)"},
+		},
+		ExternalURLs: map[string]string{
+			"jellyfin":  "https://jellyfin.local.example.com",
+			"radarr":    "https://radarr.local.example.com",
+			"sonarr":    "https://sonarr.local.example.com",
+			"seerr":     "https://seerr.local.example.com",
+			"prowlarr":  "https://prowlarr.local.example.com",
+			"bazarr":    "https://bazarr.local.example.com",
+			"sabnzbd":   "https://sabnzbd.local.example.com",
+			"unmanic":   "https://unmanic.local.example.com",
+			"npm":       "https://npm.local.example.com",
+			"arcticmon": "https://dashboard.local.example.com",
+		},
+		Intervals:  map[string]time.Duration{},
+		Timeouts:   map[string]time.Duration{},
+		Collectors: map[string]bool{},
+	}
+}
+
+func (c *Config) applyEnv() {
+	envOverride(&c.ListenAddr, "LISTEN_ADDR")
+
+	envOverride(&c.JellyfinURL, "JELLYFIN_URL")
+	envOverride(&c.JellyfinAPIKey, "JELLYFIN_API_KEY")
+
+	envOverride(&c.QbitURL, "QBIT_URL")
+	envOverride(&c.QbitUsername, "QBIT_USERNAME")
+	envOverride(&c.QbitPassword, "QBIT_PASSWORD")
+
+	envOverride(&c.RadarrURL, "RADARR_URL")
+	envOverride(&c.RadarrAPIKey, "RADARR_API_KEY")
+
+	envOverride(&c.SonarrURL, "SONARR_URL")
+	envOverride(&c.SonarrAPIKey, "SONARR_API_KEY")
+
+	envOverride(&c.SeerrURL, "SEERR_URL")
+	envOverride(&c.SeerrAPIKey, "SEERR_API_KEY")
+
+	envOverride(&c.ProwlarrURL, "PROWLARR_URL")
+	envOverride(&c.ProwlarrAPIKey, "PROWLARR_API_KEY")
+
+	envOverride(&c.BazarrURL, "BAZARR_URL")
+	envOverride(&c.BazarrAPIKey, "BAZARR_API_KEY")
+
+	envOverride(&c.SabnzbdURL, "SABNZBD_URL")
+	envOverride(&c.SabnzbdAPIKey, "SABNZBD_API_KEY")
+
+	envOverride(&c.UnmanicURL, "UNMANIC_URL")
+
+	envOverride(&c.GluetunURL, "GLUETUN_URL")
+	envOverride(&c.GluetunAPIKey, "GLUETUN_API_KEY")
+	envOverride(&c.VPNEchoURL, "VPN_ECHO_URL")
+
+	envOverride(&c.DockerSocket, "DOCKER_SOCKET")
+	envOverride(&c.HostProcPath, "HOST_PROC")
+	envOverride(&c.HostUtmpPath, "HOST_UTMP")
+
+	envOverride(&c.DashboardUser, "DASHBOARD_USER")
+	envOverride(&c.DashboardPass, "DASHBOARD_PASS")
+
+	envOverride(&c.OIDCIssuer, "OIDC_ISSUER")
+	envOverride(&c.OIDCClientID, "OIDC_CLIENT_ID")
+	envOverride(&c.OIDCClientSecret, "OIDC_CLIENT_SECRET")
+	envOverride(&c.OIDCRedirectURL, "OIDC_REDIRECT_URL")
+
+	envOverride(&c.PiholeURL, "PIHOLE_URL")
+	envOverride(&c.PiholePassword, "PIHOLE_PASSWORD")
+
+	envOverride(&c.DataDir, "DATA_DIR")
+
+	envOverride(&c.AlertRulesFile, "ALERT_RULES_FILE")
+	envOverride(&c.AlertWebhookURL, "ALERT_WEBHOOK_URL")
+	envOverride(&c.AlertNtfyURL, "ALERT_NTFY_URL")
+	envOverride(&c.AlertNtfyToken, "ALERT_NTFY_TOKEN")
+	envOverride(&c.AlertGotifyURL, "ALERT_GOTIFY_URL")
+	envOverride(&c.AlertGotifyToken, "ALERT_GOTIFY_TOKEN")
+
+	envOverride(&c.SMTPHost, "SMTP_HOST")
+	envOverride(&c.SMTPPort, "SMTP_PORT")
+	envOverride(&c.SMTPUser, "SMTP_USER")
+	envOverride(&c.SMTPPass, "SMTP_PASS")
+	envOverride(&c.SMTPFrom, "SMTP_FROM")
+	envOverride(&c.SMTPTo, "SMTP_TO")
+
+	// DISK_MOUNTS="/=NVMe,/mnt/media=HDD" replaces the mount list.
+	if v := os.Getenv("DISK_MOUNTS"); v != "" {
+		c.Mounts = nil
+		for _, entry := range splitList(v) {
+			path, label, _ := strings.Cut(entry, "=")
+			c.Mounts = append(c.Mounts, Mount{Path: path, Label: label})
+		}
+	}
+	// ACTIONS_ALLOW / ACTIONS_DENY="arcticmon,autoheal,npm"
+	if v := os.Getenv("ACTIONS_ALLOW"); v != "" {
+		c.ActionsAllow = splitList(v)
+	}
+	if v := os.Getenv("ACTIONS_DENY"); v != "" {
+		c.ActionsDeny = splitList(v)
+	}
+	// AUTH_PROXY_NETWORKS="172.18.0.0/16,10.0.0.5/32"
+	if v := os.Getenv("AUTH_PROXY_NETWORKS"); v != "" {
+		c.ProxyNetworks = splitList(v)
+	}
+	// AUTH_GROUP_ROLES="admins=admin,media=operator"
+	if v := os.Getenv("AUTH_GROUP_ROLES"); v != "" {
+		c.GroupRoles = map[string]string{}
+		for _, entry := range splitList(v) {
+			group, role, _ := strings.Cut(entry, "=")
+			c.GroupRoles[group] = role
+		}
+	}
+	envOverride(&c.DefaultRole, "AUTH_DEFAULT_ROLE")
+	// TRUSTED_PROXIES="172.18.0.0/16"
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		c.TrustedProxies = splitList(v)
+	}
+	// AUTH_TRUSTED_ORIGINS="https://arctic.example.com"
+	if v := os.Getenv("AUTH_TRUSTED_ORIGINS"); v != "" {
+		c.TrustedOrigins = splitList(v)
+	}
+	// DISABLED_COLLECTORS="unmanic,bazarr"
+	for _, name := range splitList(os.Getenv("DISABLED_COLLECTORS")) {
+		c.Collectors[name] = false
+	}
+}
+
+// Validate checks the configuration and reports all problems at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.ListenAddr == "" {
+		errs = append(errs, errors.New("listen: must not be empty"))
+	}
+	if c.DataDir == "" {
+		errs = append(errs, errors.New("dataDir: must not be empty"))
+	}
+
+	urls := []struct{ key, val string }{
+		{"services.jellyfin.url", c.JellyfinURL},
+		{"services.qbittorrent.url", c.QbitURL},
+		{"services.radarr.url", c.RadarrURL},
+		{"services.sonarr.url", c.SonarrURL},
+		{"services.seerr.url", c.SeerrURL},
+		{"services.prowlarr.url", c.ProwlarrURL},
+		{"services.bazarr.url", c.BazarrURL},
+		{"services.sabnzbd.url", c.SabnzbdURL},
+		{"services.unmanic.url", c.UnmanicURL},
+		{"services.gluetun.url", c.GluetunURL},
+		{"vpnLeak.echoUrl", c.VPNEchoURL},
+		{"services.pihole.url", c.PiholeURL},
+		{"alerts.webhookUrl", c.AlertWebhookURL},
+		{"alerts.ntfyUrl", c.AlertNtfyURL},
+		{"alerts.gotifyUrl", c.AlertGotifyURL},
+	}
+	for name, u := range c.ExternalURLs {
+		urls = append(urls, struct{ key, val string }{"externalUrls." + name, u})
+	}
+	for _, u := range urls {
+		if u.val == "" {
+			continue
+		}
+		if err := checkURL(u.val); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", u.key, err))
+		}
+	}
+
+	if c.SSEMaxSubscribers < 1 {
+		errs = append(errs, fmt.Errorf("dashboard.sse.maxSubscribers: %d must be at least 1", c.SSEMaxSubscribers))
+	}
+	if c.SSEHeartbeat < time.Second {
+		errs = append(errs, fmt.Errorf("dashboard.sse.heartbeat: %s is below the 1s minimum", c.SSEHeartbeat))
+	}
+	if c.SessionTTL < time.Minute {
+		errs = append(errs, fmt.Errorf("auth.sessionTtl: %s is below the 1m minimum", c.SessionTTL))
+	}
+	if c.SSOTokenTTL < time.Minute {
+		errs = append(errs, fmt.Errorf("auth.ssoTokenTtl: %s is below the 1m minimum", c.SSOTokenTTL))
+	}
+	if c.ActionsBurst < 1 {
+		errs = append(errs, fmt.Errorf("actions.rateLimit.burst: %d must be at least 1", c.ActionsBurst))
+	}
+	if c.ActionsInterval < time.Second {
+		errs = append(errs, fmt.Errorf("actions.rateLimit.interval: %s is below the 1s minimum", c.ActionsInterval))
+	}
+	if c.MaxFailures < 1 {
+		errs = append(errs, fmt.Errorf("auth.lockout.maxFailures: %d must be at least 1", c.MaxFailures))
+	}
+	if c.LockoutDuration < time.Minute {
+		errs = append(errs, fmt.Errorf("auth.lockout.duration: %s is below the 1m minimum", c.LockoutDuration))
+	}
+	if c.TorrentStalledAfter < 0 || c.TorrentStalledAfter > 0 && c.TorrentStalledAfter < time.Hour {
+		errs = append(errs, fmt.Errorf("torrentPolicy.stalledAfter: %s is below the 1h minimum", c.TorrentStalledAfter))
+	}
+	if c.TorrentNoSeedsAfter < 0 || c.TorrentNoSeedsAfter > 0 && c.TorrentNoSeedsAfter < time.Hour {
+		errs = append(errs, fmt.Errorf("torrentPolicy.noSeedsAfter: %s is below the 1h minimum", c.TorrentNoSeedsAfter))
+	}
+	if c.TorrentRatioTarget < 0 {
+		errs = append(errs, fmt.Errorf("torrentPolicy.ratioTarget: %g must not be negative", c.TorrentRatioTarget))
+	}
+	if c.TorrentSeedTimeTarget < 0 {
+		errs = append(errs, fmt.Errorf("torrentPolicy.seedTimeTarget: %s must not be negative", c.TorrentSeedTimeTarget))
+	}
+	for i, n := range c.TrustedProxies {
+		if _, err := netip.ParsePrefix(n); err != nil {
+			errs = append(errs, fmt.Errorf("auth.trustedProxies[%d]: %w", i, err))
+		}
+	}
+
+	for group, role := range c.GroupRoles {
+		if !knownRole(role) {
+			errs = append(errs, fmt.Errorf("auth.groupRoles.%s: unknown role %q", group, role))
+		}
+	}
+	if c.DefaultRole != "" && !knownRole(c.DefaultRole) {
+		errs = append(errs, fmt.Errorf("auth.defaultRole: unknown role %q", c.DefaultRole))
+	}
+	if c.OIDCIssuer != "" {
+		if err := checkURL(c.OIDCIssuer); err != nil {
+			errs = append(errs, fmt.Errorf("auth.oidc.issuer: %w", err))
+		}
+		if c.OIDCClientID == "" {
+			errs = append(errs, errors.New("auth.oidc.clientId: required when an issuer is set"))
+		}
+		if c.OIDCRedirectURL == "" {
+			errs = append(errs, errors.New("auth.oidc.redirectUrl: required when an issuer is set"))
+		} else if err := checkURL(c.OIDCRedirectURL); err != nil {
+			errs = append(errs, fmt.Errorf("auth.oidc.redirectUrl: %w", err))
+		}
+	}
+	for i, n := range c.ProxyNetworks {
+		if _, err := netip.ParsePrefix(n); err != nil {
+			errs = append(errs, fmt.Errorf("auth.proxy.trustedNetworks[%d]: %w", i, err))
+		}
+	}
+	if len(c.ProxyNetworks) > 0 && c.ProxyUserHeader == "" {
+		errs = append(errs, errors.New("auth.proxy.userHeader: must not be empty"))
+	}
+	for i, o := range c.TrustedOrigins {
+		if err := checkURL(o); err != nil {
+			errs = append(errs, fmt.Errorf("auth.trustedOrigins[%d]: %w", i, err))
+		}
+	}
+
+	if len(c.VPNContainers) > 0 && c.VPNGateway == "" {
+		errs = append(errs, errors.New("vpnLeak.gateway: must not be empty"))
+	}
+
+	if len(c.Mounts) == 0 {
+		errs = append(errs, errors.New("mounts: at least one mount is required"))
+	}
+	for i, m := range c.Mounts {
+		if !filepath.IsAbs(m.Path) {
+			errs = append(errs, fmt.Errorf("mounts[%d].path: %q is not an absolute path", i, m.Path))
+		}
+		if m.Label == "" {
+			c.Mounts[i].Label = m.Path
+		}
+	}
+
+	for name, d := range c.Intervals {
+		if !knownCollector(name) {
+			errs = append(errs, fmt.Errorf("intervals.%s: unknown collector", name))
+		} else if d < time.Second {
+			errs = append(errs, fmt.Errorf("intervals.%s: %s is below the 1s minimum", name, d))
+		}
+	}
+	for name, d := range c.Timeouts {
+		if !knownCollector(name) {
+			errs = append(errs, fmt.Errorf("timeouts.%s: unknown collector", name))
+		} else if d < time.Second {
+			errs = append(errs, fmt.Errorf("timeouts.%s: %s is below the 1s minimum", name, d))
+		}
+	}
+	for name := range c.Collectors {
+		if !knownCollector(name) {
+			errs = append(errs, fmt.Errorf("collectors.%s: unknown collector", name))
+		}
+	}
+
+	if _, err := strconv.Atoi(c.SMTPPort); err != nil {
+		errs = append(errs, fmt.Errorf("smtp.port: %q is not a number", c.SMTPPort))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Interval returns the polling interval for a collector.
+func (c *Config) Interval(name string) time.Duration {
+	if d, ok := c.Intervals[name]; ok {
+		return d
+	}
+	return DefaultIntervals[name]
+}
+
+// Timeout returns the per-collection deadline for a collector.
+func (c *Config) Timeout(name string) time.Duration {
+	if d, ok := c.Timeouts[name]; ok {
+		return d
+	}
+	return DefaultTimeout
+}
+
+// ContainerManaged reports whether dashboard actions may start, stop,
+// restart or recreate the named container.
+func (c *Config) ContainerManaged(name string) bool {
+	for _, n := range c.ActionsDeny {
+		if n == name {
+			return false
+		}
+	}
+	if len(c.ActionsAllow) == 0 {
+		return true
+	}
+	for _, n := range c.ActionsAllow {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CollectorEnabled reports whether a collector should run (default true).
+func (c *Config) CollectorEnabled(name string) bool {
+	enabled, ok := c.Collectors[name]
+	return !ok || enabled
+}
+
+func checkURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q must use http or https", s)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", s)
+	}
+	return nil
+}
+
+// knownRole mirrors the roles of the auth package.
+func knownRole(role string) bool {
+	return role == "viewer" || role == "operator" || role == "admin"
+}
+
+func knownCollector(name string) bool {
+	_, ok := DefaultIntervals[name]
+	return ok
+}
 
-		PiholeURL:      envOr("PIHOLE_URL", "http://192.168.1.254"),
-		PiholePassword: os.Getenv("PIHOLE_PASSWORD"),
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
 	}
+	return out
 }
 
-func envOr(key, fallback string) string {
+func envOverride(dst *string, key string) {
 	if v := os.Getenv(key); v != "" {
-		return v
+		*dst = v
 	}
-	return fallback
 }
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   []string // substrings of the expected errors; none means valid
+	}{
+		{name: "defaults", modify: func(c *Config) {}},
+		{
+			name:   "empty listen address",
+			modify: func(c *Config) { c.ListenAddr = "" },
+			want:   []string{"listen: must not be empty"},
+		},
+		{
+			name:   "service URL without scheme",
+			modify: func(c *Config) { c.SonarrURL = "sonarr:8989" },
+			want:   []string{"services.sonarr.url"},
+		},
+		{
+			name:   "external URL without host",
+			modify: func(c *Config) { c.ExternalURLs = map[string]string{"jellyfin": "https://"} },
+			want:   []string{"externalUrls.jellyfin", "has no host"},
+		},
+		{
+			name: "durations below their minimum",
+			modify: func(c *Config) {
+				c.SSEHeartbeat = 500 * time.Millisecond
+				c.SessionTTL = time.Second
+				c.ActionsInterval = 0
+			},
+			want: []string{"dashboard.sse.heartbeat", "auth.sessionTtl", "actions.rateLimit.interval"},
+		},
+		{
+			name:   "stalled torrent threshold disabled",
+			modify: func(c *Config) { c.TorrentStalledAfter = 0 },
+		},
+		{
+			name:   "stalled torrent threshold too short",
+			modify: func(c *Config) { c.TorrentStalledAfter = 30 * time.Minute },
+			want:   []string{"torrentPolicy.stalledAfter"},
+		},
+		{
+			name:   "negative ratio target",
+			modify: func(c *Config) { c.TorrentRatioTarget = -1 },
+			want:   []string{"torrentPolicy.ratioTarget"},
+		},
+		{
+			name:   "unknown group role",
+			modify: func(c *Config) { c.GroupRoles = map[string]string{"admins": "root"} },
+			want:   []string{`auth.groupRoles.admins: unknown role "root"`},
+		},
+		{
+			name:   "OIDC without client",
+			modify: func(c *Config) { c.OIDCIssuer = "https://auth.example.com" },
+			want:   []string{"auth.oidc.clientId", "auth.oidc.redirectUrl"},
+		},
+		{
+			name:   "bad proxy network",
+			modify: func(c *Config) { c.ProxyNetworks = []string{"172.18.0.0"} },
+			want:   []string{"auth.proxy.trustedNetworks[0]"},
+		},
+		{
+			name:   "relative mount",
+			modify: func(c *Config) { c.Mounts = []Mount{{Path: "mnt/media"}} },
+			want:   []string{`mounts[0].path: "mnt/media" is not an absolute path`},
+		},
+		{
+			name: "unknown and too fast collectors",
+			modify: func(c *Config) {
+				c.Intervals = map[string]time.Duration{"plex": time.Minute, "host": time.Millisecond}
+				c.Collectors = map[string]bool{"emby": false}
+			},
+			want: []string{"intervals.plex: unknown collector", "intervals.host", "collectors.emby: unknown collector"},
+		},
+		{
+			name:   "SMTP port not a number",
+			modify: func(c *Config) { c.SMTPPort = "smtp" },
+			want:   []string{"smtp.port"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := defaults()
+			tt.modify(c)
+			err := c.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want errors containing %q", tt.want)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("Validate() = %v, want it to contain %q", err, w)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateDefaultsMountLabel(t *testing.T) {
+	c := defaults()
+	c.Mounts = []Mount{{Path: "/mnt/media"}}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Mounts[0].Label != "/mnt/media" {
+		t.Errorf("label = %q, want the path", c.Mounts[0].Label)
+	}
+}
@@ -0,0 +1,305 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig mirrors the YAML config file. Empty values leave the default
+// (or a previously applied value) untouched.
+type fileConfig struct {
+	Listen  string `yaml:"listen"`
+	DataDir string `yaml:"dataDir"`
+
+	Services struct {
+		Jellyfin    serviceEntry `yaml:"jellyfin"`
+		Qbittorrent serviceEntry `yaml:"qbittorrent"`
+		Radarr      serviceEntry `yaml:"radarr"`
+		Sonarr      serviceEntry `yaml:"sonarr"`
+		Seerr       serviceEntry `yaml:"seerr"`
+		Prowlarr    serviceEntry `yaml:"prowlarr"`
+		Bazarr      serviceEntry `yaml:"bazarr"`
+		Sabnzbd     serviceEntry `yaml:"sabnzbd"`
+		Unmanic     serviceEntry `yaml:"unmanic"`
+		Gluetun     serviceEntry `yaml:"gluetun"`
+		Pihole      serviceEntry `yaml:"pihole"`
+	} `yaml:"services"`
+
+	Docker struct {
+		Socket string `yaml:"socket"`
+	} `yaml:"docker"`
+
+	Host struct {
+		Proc string `yaml:"proc"`
+		Utmp string `yaml:"utmp"`
+	} `yaml:"host"`
+
+	Dashboard struct {
+		User string `yaml:"user"`
+		Pass string `yaml:"pass"`
+		SSE  struct {
+			MaxSubscribers int           `yaml:"maxSubscribers"`
+			Heartbeat      time.Duration `yaml:"heartbeat"`
+		} `yaml:"sse"`
+	} `yaml:"dashboard"`
+
+	Auth struct {
+		SessionTTL  time.Duration     `yaml:"sessionTtl"`
+		SSOTokenTTL time.Duration     `yaml:"ssoTokenTtl"`
+		GroupRoles  map[string]string `yaml:"groupRoles"`
+		DefaultRole string            `yaml:"defaultRole"`
+		OIDC        struct {
+			Issuer        string   `yaml:"issuer"`
+			ClientID      string   `yaml:"clientId"`
+			ClientSecret  string   `yaml:"clientSecret"`
+			RedirectURL   string   `yaml:"redirectUrl"`
+			Scopes        []string `yaml:"scopes"`
+			UsernameClaim string   `yaml:"usernameClaim"`
+			GroupsClaim   string   `yaml:"groupsClaim"`
+		} `yaml:"oidc"`
+		Proxy struct {
+			TrustedNetworks []string `yaml:"trustedNetworks"`
+			UserHeader      string   `yaml:"userHeader"`
+			GroupsHeader    string   `yaml:"groupsHeader"`
+		} `yaml:"proxy"`
+		TrustedOrigins []string `yaml:"trustedOrigins"`
+		TrustedProxies []string `yaml:"trustedProxies"`
+		Lockout        struct {
+			MaxFailures int           `yaml:"maxFailures"`
+			Duration    time.Duration `yaml:"duration"`
+		} `yaml:"lockout"`
+	} `yaml:"auth"`
+
+	Actions struct {
+		Allow     []string `yaml:"allow"`
+		Deny      []string `yaml:"deny"`
+		RateLimit struct {
+			Burst    int           `yaml:"burst"`
+			Interval time.Duration `yaml:"interval"`
+		} `yaml:"rateLimit"`
+	} `yaml:"actions"`
+
+	VPNLeak struct {
+		Gateway    string   `yaml:"gateway"`
+		Containers []string `yaml:"containers"`
+		EchoURL    string   `yaml:"echoUrl"`
+	} `yaml:"vpnLeak"`
+
+	TorrentPolicy struct {
+		StalledAfter   *time.Duration `yaml:"stalledAfter"`
+		NoSeedsAfter   *time.Duration `yaml:"noSeedsAfter"`
+		RatioTarget    float64        `yaml:"ratioTarget"`
+		SeedTimeTarget time.Duration  `yaml:"seedTimeTarget"`
+		Categories     []string       `yaml:"categories"`
+		Remediate      bool           `yaml:"remediate"`
+		DryRun         *bool          `yaml:"dryRun"`
+	} `yaml:"torrentPolicy"`
+
+	Mounts       []Mount                  `yaml:"mounts"`
+	ExternalURLs map[string]string        `yaml:"externalUrls"`
+	Intervals    map[string]time.Duration `yaml:"intervals"`
+	Timeouts     map[string]time.Duration `yaml:"timeouts"`
+	Collectors   map[string]bool          `yaml:"collectors"`
+
+	Alerts struct {
+		RulesFile   string `yaml:"rulesFile"`
+		WebhookURL  string `yaml:"webhookUrl"`
+		NtfyURL     string `yaml:"ntfyUrl"`
+		NtfyToken   string `yaml:"ntfyToken"`
+		GotifyURL   string `yaml:"gotifyUrl"`
+		GotifyToken string `yaml:"gotifyToken"`
+	} `yaml:"alerts"`
+
+	SMTP struct {
+		Host string   `yaml:"host"`
+		Port int      `yaml:"port"`
+		User string   `yaml:"user"`
+		Pass string   `yaml:"pass"`
+		From string   `yaml:"from"`
+		To   []string `yaml:"to"`
+	} `yaml:"smtp"`
+}
+
+type serviceEntry struct {
+	URL      string `yaml:"url"`
+	APIKey   string `yaml:"apiKey"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// loadFile decodes path and applies it on top of c. Unknown keys are
+// rejected so typos surface as startup errors.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var f fileConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	set(&c.ListenAddr, f.Listen)
+	set(&c.DataDir, f.DataDir)
+
+	set(&c.JellyfinURL, f.Services.Jellyfin.URL)
+	set(&c.JellyfinAPIKey, f.Services.Jellyfin.APIKey)
+	set(&c.QbitURL, f.Services.Qbittorrent.URL)
+	set(&c.QbitUsername, f.Services.Qbittorrent.Username)
+	set(&c.QbitPassword, f.Services.Qbittorrent.Password)
+	set(&c.RadarrURL, f.Services.Radarr.URL)
+	set(&c.RadarrAPIKey, f.Services.Radarr.APIKey)
+	set(&c.SonarrURL, f.Services.Sonarr.URL)
+	set(&c.SonarrAPIKey, f.Services.Sonarr.APIKey)
+	set(&c.SeerrURL, f.Services.Seerr.URL)
+	set(&c.SeerrAPIKey, f.Services.Seerr.APIKey)
+	set(&c.ProwlarrURL, f.Services.Prowlarr.URL)
+	set(&c.ProwlarrAPIKey, f.Services.Prowlarr.APIKey)
+	set(&c.BazarrURL, f.Services.Bazarr.URL)
+	set(&c.BazarrAPIKey, f.Services.Bazarr.APIKey)
+	set(&c.SabnzbdURL, f.Services.Sabnzbd.URL)
+	set(&c.SabnzbdAPIKey, f.Services.Sabnzbd.APIKey)
+	set(&c.UnmanicURL, f.Services.Unmanic.URL)
+	set(&c.GluetunURL, f.Services.Gluetun.URL)
+	set(&c.GluetunAPIKey, f.Services.Gluetun.APIKey)
+	set(&c.PiholeURL, f.Services.Pihole.URL)
+	set(&c.PiholePassword, f.Services.Pihole.Password)
+
+	set(&c.DockerSocket, f.Docker.Socket)
+	set(&c.HostProcPath, f.Host.Proc)
+	set(&c.HostUtmpPath, f.Host.Utmp)
+
+	set(&c.DashboardUser, f.Dashboard.User)
+	set(&c.DashboardPass, f.Dashboard.Pass)
+	if f.Dashboard.SSE.MaxSubscribers != 0 {
+		c.SSEMaxSubscribers = f.Dashboard.SSE.MaxSubscribers
+	}
+	if f.Dashboard.SSE.Heartbeat != 0 {
+		c.SSEHeartbeat = f.Dashboard.SSE.Heartbeat
+	}
+	if f.Auth.SessionTTL != 0 {
+		c.SessionTTL = f.Auth.SessionTTL
+	}
+	if f.Auth.SSOTokenTTL != 0 {
+		c.SSOTokenTTL = f.Auth.SSOTokenTTL
+	}
+	if f.Auth.GroupRoles != nil {
+		c.GroupRoles = f.Auth.GroupRoles
+	}
+	set(&c.DefaultRole, f.Auth.DefaultRole)
+	set(&c.OIDCIssuer, f.Auth.OIDC.Issuer)
+	set(&c.OIDCClientID, f.Auth.OIDC.ClientID)
+	set(&c.OIDCClientSecret, f.Auth.OIDC.ClientSecret)
+	set(&c.OIDCRedirectURL, f.Auth.OIDC.RedirectURL)
+	if len(f.Auth.OIDC.Scopes) > 0 {
+		c.OIDCScopes = f.Auth.OIDC.Scopes
+	}
+	set(&c.OIDCUsernameClaim, f.Auth.OIDC.UsernameClaim)
+	set(&c.OIDCGroupsClaim, f.Auth.OIDC.GroupsClaim)
+	if f.Auth.Proxy.TrustedNetworks != nil {
+		c.ProxyNetworks = f.Auth.Proxy.TrustedNetworks
+	}
+	set(&c.ProxyUserHeader, f.Auth.Proxy.UserHeader)
+	set(&c.ProxyGroupsHeader, f.Auth.Proxy.GroupsHeader)
+	if f.Auth.TrustedOrigins != nil {
+		c.TrustedOrigins = f.Auth.TrustedOrigins
+	}
+	if f.Auth.TrustedProxies != nil {
+		c.TrustedProxies = f.Auth.TrustedProxies
+	}
+	if f.Auth.Lockout.MaxFailures != 0 {
+		c.MaxFailures = f.Auth.Lockout.MaxFailures
+	}
+	if f.Auth.Lockout.Duration != 0 {
+		c.LockoutDuration = f.Auth.Lockout.Duration
+	}
+
+	if f.Actions.Allow != nil {
+		c.ActionsAllow = f.Actions.Allow
+	}
+	if f.Actions.Deny != nil {
+		c.ActionsDeny = f.Actions.Deny
+	}
+	if f.Actions.RateLimit.Burst != 0 {
+		c.ActionsBurst = f.Actions.RateLimit.Burst
+	}
+	if f.Actions.RateLimit.Interval != 0 {
+		c.ActionsInterval = f.Actions.RateLimit.Interval
+	}
+	set(&c.VPNGateway, f.VPNLeak.Gateway)
+	if f.VPNLeak.Containers != nil {
+		c.VPNContainers = f.VPNLeak.Containers
+	}
+	set(&c.VPNEchoURL, f.VPNLeak.EchoURL)
+
+	// Pointers tell an explicit 0 (rule disabled) or false from unset.
+	if f.TorrentPolicy.StalledAfter != nil {
+		c.TorrentStalledAfter = *f.TorrentPolicy.StalledAfter
+	}
+	if f.TorrentPolicy.NoSeedsAfter != nil {
+		c.TorrentNoSeedsAfter = *f.TorrentPolicy.NoSeedsAfter
+	}
+	if f.TorrentPolicy.RatioTarget != 0 {
+		c.TorrentRatioTarget = f.TorrentPolicy.RatioTarget
+	}
+	if f.TorrentPolicy.SeedTimeTarget != 0 {
+		c.TorrentSeedTimeTarget = f.TorrentPolicy.SeedTimeTarget
+	}
+	if f.TorrentPolicy.Categories != nil {
+		c.TorrentCategories = f.TorrentPolicy.Categories
+	}
+	if f.TorrentPolicy.Remediate {
+		c.TorrentRemediate = true
+	}
+	if f.TorrentPolicy.DryRun != nil {
+		c.TorrentDryRun = *f.TorrentPolicy.DryRun
+	}
+	if len(f.Mounts) > 0 {
+		c.Mounts = f.Mounts
+	}
+	if f.ExternalURLs != nil {
+		c.ExternalURLs = f.ExternalURLs
+	}
+	for name, d := range f.Intervals {
+		c.Intervals[name] = d
+	}
+	for name, d := range f.Timeouts {
+		c.Timeouts[name] = d
+	}
+	for name, enabled := range f.Collectors {
+		c.Collectors[name] = enabled
+	}
+
+	set(&c.AlertRulesFile, f.Alerts.RulesFile)
+	set(&c.AlertWebhookURL, f.Alerts.WebhookURL)
+	set(&c.AlertNtfyURL, f.Alerts.NtfyURL)
+	set(&c.AlertNtfyToken, f.Alerts.NtfyToken)
+	set(&c.AlertGotifyURL, f.Alerts.GotifyURL)
+	set(&c.AlertGotifyToken, f.Alerts.GotifyToken)
+
+	set(&c.SMTPHost, f.SMTP.Host)
+	if f.SMTP.Port != 0 {
+		c.SMTPPort = fmt.Sprint(f.SMTP.Port)
+	}
+	set(&c.SMTPUser, f.SMTP.User)
+	set(&c.SMTPPass, f.SMTP.Pass)
+	set(&c.SMTPFrom, f.SMTP.From)
+	set(&c.SMTPTo, strings.Join(f.SMTP.To, ","))
+	return nil
+}
+
+func set(dst *string, v string) {
+	if v != "" {
+		*dst = v
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Watch reloads the configuration on SIGHUP or when the loaded config file
+// changes on disk, calling onReload with each new valid configuration.
+// Invalid configurations are logged and the current one is kept.
+func Watch(ctx context.Context, current *Config, onReload func(*Config)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	lastMod := modTime(current.File)
+
+	reload := func(reason string) {
+		next, err := Load()
+		if err != nil {
+			log.Printf("[config] reload (%s) rejected: %v", reason, err)
+			return
+		}
+		log.Printf("[config] reloaded (%s)", reason)
+		lastMod = modTime(next.File)
+		current = next
+		onReload(next)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			reload("SIGHUP")
+		case <-ticker.C:
+			if current.File == "" {
+				continue
+			}
+			if mod := modTime(current.File); !mod.Equal(lastMod) {
+				lastMod = mod
+				reload("file changed")
+			}
+		}
+	}
+}
+
+func modTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
@@ -0,0 +1,258 @@
+package history
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Tier is a downsampling level: samples are averaged into buckets of Step
+// and buckets older than Retention are discarded.
+type Tier struct {
+	Step      time.Duration
+	Retention time.Duration
+}
+
+// DefaultTiers keeps 10s resolution for a day, 5m for a month and 1h for a year.
+var DefaultTiers = []Tier{
+	{Step: 10 * time.Second, Retention: 24 * time.Hour},
+	{Step: 5 * time.Minute, Retention: 30 * 24 * time.Hour},
+	{Step: time.Hour, Retention: 365 * 24 * time.Hour},
+}
+
+// Point is a single aggregated value returned by Query.
+type Point struct {
+	T time.Time `json:"t"`
+	V float64   `json:"v"`
+}
+
+// bucket accumulates samples falling in the same step window.
+type bucket struct {
+	T     int64 // bucket start, unix seconds
+	Sum   float64
+	Count uint32
+}
+
+func (b bucket) avg() float64 {
+	if b.Count == 0 {
+		return 0
+	}
+	return b.Sum / float64(b.Count)
+}
+
+// DB is an embedded time-series store persisted to a single file.
+type DB struct {
+	path  string
+	tiers []Tier
+
+	mu     sync.RWMutex
+	series []map[string][]bucket // one map per tier, keyed by series name
+	dirty  bool
+}
+
+// Open loads the database from path, creating an empty one if the file
+// does not exist yet. A file that cannot be decoded is moved aside to
+// path.corrupt so that the dashboard starts with an empty history instead
+// of failing.
+func Open(path string, tiers []Tier) (*DB, error) {
+	db := &DB{
+		path:   path,
+		tiers:  tiers,
+		series: make([]map[string][]bucket, len(tiers)),
+	}
+	for i := range db.series {
+		db.series[i] = make(map[string][]bucket)
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved []map[string][]bucket
+	err = gob.NewDecoder(f).Decode(&saved)
+	f.Close()
+	if err != nil {
+		if rerr := os.Rename(path, path+".corrupt"); rerr != nil {
+			return nil, fmt.Errorf("decode %s: %w (moving it aside: %v)", path, err, rerr)
+		}
+		log.Printf("[history] %s is corrupt (%v); moved it to %s.corrupt and starting empty", path, err, path)
+		return db, nil
+	}
+	// Tiers may have changed between versions; keep what still lines up.
+	for i := range db.series {
+		if i < len(saved) && saved[i] != nil {
+			db.series[i] = saved[i]
+		}
+	}
+	db.prune(time.Now())
+	return db, nil
+}
+
+// Record adds a sample for the named series to every tier. Samples older
+// than the newest bucket of a tier are ignored for that tier.
+func (db *DB) Record(name string, t time.Time, v float64) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for i, tier := range db.tiers {
+		start := t.Truncate(tier.Step).Unix()
+		buckets := db.series[i][name]
+		n := len(buckets)
+		switch {
+		case n > 0 && buckets[n-1].T == start:
+			buckets[n-1].Sum += v
+			buckets[n-1].Count++
+		case n > 0 && buckets[n-1].T > start:
+			// Out-of-order sample (clock stepped back); buckets must stay sorted.
+			continue
+		default:
+			buckets = append(buckets, bucket{T: start, Sum: v, Count: 1})
+		}
+		db.series[i][name] = buckets
+	}
+	db.dirty = true
+}
+
+// Query returns averaged points for name between from and to. The finest
+// tier that still covers from is used; if step is coarser than that tier,
+// buckets are merged to step. A zero step keeps the tier resolution.
+func (db *DB) Query(name string, from, to time.Time, step time.Duration) ([]Point, time.Duration) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	idx := len(db.tiers) - 1
+	for i, tier := range db.tiers {
+		if time.Since(from) <= tier.Retention {
+			idx = i
+			break
+		}
+	}
+	tierStep := db.tiers[idx].Step
+	if step < tierStep {
+		step = tierStep
+	}
+
+	buckets := db.series[idx][name]
+	lo := sort.Search(len(buckets), func(i int) bool { return buckets[i].T >= from.Truncate(tierStep).Unix() })
+
+	points := []Point{}
+	var acc bucket
+	stepSec := int64(step / time.Second)
+	for _, b := range buckets[lo:] {
+		if b.T > to.Unix() {
+			break
+		}
+		start := b.T - b.T%stepSec
+		if acc.Count > 0 && acc.T != start {
+			points = append(points, Point{T: time.Unix(acc.T, 0), V: acc.Sum / float64(acc.Count)})
+			acc = bucket{}
+		}
+		acc.T = start
+		acc.Sum += b.avg()
+		acc.Count++
+	}
+	if acc.Count > 0 {
+		points = append(points, Point{T: time.Unix(acc.T, 0), V: acc.Sum / float64(acc.Count)})
+	}
+	return points, step
+}
+
+// Series returns the names of all series known to the finest tier.
+func (db *DB) Series() []string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	names := make([]string, 0, len(db.series[0]))
+	for name := range db.series[0] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// prune drops buckets that fell out of their tier's retention window.
+// Caller must hold the write lock (or own db exclusively).
+func (db *DB) prune(now time.Time) {
+	for i, tier := range db.tiers {
+		cutoff := now.Add(-tier.Retention).Unix()
+		for name, buckets := range db.series[i] {
+			lo := sort.Search(len(buckets), func(j int) bool { return buckets[j].T >= cutoff })
+			switch {
+			case lo == len(buckets):
+				delete(db.series[i], name)
+			case lo > 0:
+				db.series[i][name] = append([]bucket(nil), buckets[lo:]...)
+			}
+		}
+	}
+}
+
+// Flush prunes expired data and writes the database to disk atomically.
+func (db *DB) Flush() error {
+	db.mu.Lock()
+	if !db.dirty {
+		db.mu.Unlock()
+		return nil
+	}
+	db.prune(time.Now())
+
+	if err := os.MkdirAll(filepath.Dir(db.path), 0o755); err != nil {
+		db.mu.Unlock()
+		return err
+	}
+	tmp := db.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		db.mu.Unlock()
+		return err
+	}
+	err = gob.NewEncoder(f).Encode(db.series)
+	db.dirty = false
+	db.mu.Unlock()
+
+	// Sync before the rename so a crash cannot leave a truncated file in
+	// place of the previous one.
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, db.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		db.mu.Lock()
+		db.dirty = true
+		db.mu.Unlock()
+	}
+	return err
+}
+
+// Run flushes the database every interval until ctx is cancelled. The
+// caller is expected to Flush once more on shutdown.
+func (db *DB) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := db.Flush(); err != nil {
+				log.Printf("[history] flush error: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testTiers = []Tier{
+	{Step: 10 * time.Second, Retention: time.Hour},
+	{Step: time.Minute, Retention: 24 * time.Hour},
+}
+
+func TestQuery(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "history.gob"), testTiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Twelve samples 10s apart over two whole minutes, valued 0 to 11.
+	base := time.Now().Add(-30 * time.Minute).Truncate(time.Minute)
+	for i := 0; i < 12; i++ {
+		db.Record("cpu", base.Add(time.Duration(i)*10*time.Second), float64(i))
+	}
+	// Out of order: ignored by every tier.
+	db.Record("cpu", base.Add(-time.Minute), 100)
+
+	end := base.Add(time.Hour)
+	tests := []struct {
+		name     string
+		from, to time.Time
+		step     time.Duration
+		wantStep time.Duration
+		want     []float64
+	}{
+		{
+			name: "tier resolution", from: base, to: end,
+			wantStep: 10 * time.Second,
+			want:     []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+		},
+		{
+			name: "step below the tier", from: base, to: end, step: 5 * time.Second,
+			wantStep: 10 * time.Second,
+			want:     []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+		},
+		{
+			name: "merged to 30s", from: base, to: end, step: 30 * time.Second,
+			wantStep: 30 * time.Second,
+			want:     []float64{1, 4, 7, 10},
+		},
+		{
+			name: "merged to 1m", from: base, to: end, step: time.Minute,
+			wantStep: time.Minute,
+			want:     []float64{2.5, 8.5},
+		},
+		{
+			name: "from beyond the finest tier", from: base.Add(-2 * time.Hour), to: end,
+			wantStep: time.Minute,
+			want:     []float64{2.5, 8.5},
+		},
+		{
+			name: "bounded by to", from: base, to: base.Add(25 * time.Second),
+			wantStep: 10 * time.Second,
+			want:     []float64{0, 1, 2},
+		},
+		{
+			name: "from mid-range", from: base.Add(95 * time.Second), to: end,
+			wantStep: 10 * time.Second,
+			want:     []float64{9, 10, 11},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points, step := db.Query("cpu", tt.from, tt.to, tt.step)
+			if step != tt.wantStep {
+				t.Errorf("step = %s, want %s", step, tt.wantStep)
+			}
+			var got []float64
+			for _, p := range points {
+				got = append(got, p.V)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("values = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("values = %v, want %v", got, tt.want)
+				}
+			}
+			if len(points) > 0 && points[0].T.Before(tt.from.Truncate(step)) {
+				t.Errorf("first point at %s, before %s", points[0].T, tt.from)
+			}
+		})
+	}
+
+	if points, _ := db.Query("missing", base, end, 0); len(points) != 0 {
+		t.Errorf("unknown series returned %v", points)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "history.gob"), testTiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	db.Record("old", now.Add(-2*time.Hour), 1)
+	db.Record("new", now, 1)
+	db.prune(now)
+
+	if _, ok := db.series[0]["old"]; ok {
+		t.Error("finest tier kept a sample past its retention")
+	}
+	if _, ok := db.series[1]["old"]; !ok {
+		t.Error("coarse tier dropped a sample within its retention")
+	}
+	if got := db.Series(); len(got) != 1 || got[0] != "new" {
+		t.Errorf("Series() = %v, want [new]", got)
+	}
+}
+
+func TestFlushAndOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.gob")
+	db, err := Open(path, testTiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Truncate(10 * time.Second)
+	db.Record("cpu", now, 42)
+	if err := db.Flush(); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temp file left behind: %v", err)
+	}
+
+	db, err = Open(path, testTiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	points, _ := db.Query("cpu", now, now.Add(time.Minute), 0)
+	if len(points) != 1 || points[0].V != 42 {
+		t.Errorf("reopened Query() = %v, want one point of 42", points)
+	}
+}
+
+func TestOpenCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.gob")
+	if err := os.WriteFile(path, []byte("not a gob"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(path, testTiers)
+	if err != nil {
+		t.Fatalf("Open() = %v, want an empty database", err)
+	}
+	if got := db.Series(); len(got) != 0 {
+		t.Errorf("Series() = %v, want none", got)
+	}
+	if _, err := os.Stat(path + ".corrupt"); err != nil {
+		t.Errorf("corrupt file not moved aside: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("corrupt file still in place: %v", err)
+	}
+}
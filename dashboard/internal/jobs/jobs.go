@@ -0,0 +1,442 @@
+// Package jobs runs dashboard actions asynchronously. Every action becomes
+// a job with an ID whose state, log output and result can be polled or
+// followed, and the job history is persisted across restarts.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job states.
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+)
+
+const (
+	// maxJobs is the number of jobs kept in history.
+	maxJobs = 200
+	// maxLogLines and maxLineLen bound the captured output per job.
+	maxLogLines = 2000
+	maxLineLen  = 4096
+	queueSize   = 32
+)
+
+// ErrQueueFull is returned by Submit when too many jobs are pending.
+var ErrQueueFull = errors.New("job queue is full")
+
+// GlobalLease is the lease of jobs that affect the whole stack or host; it
+// conflicts with every other lease. Other leases name one or more
+// resources separated by commas (e.g. "container:gluetun,container:qbittorrent")
+// and conflict with leases naming one of the same resources.
+const GlobalLease = "*"
+
+// LeaseError is returned by Submit when a queued or running job holds a
+// conflicting lease.
+type LeaseError struct {
+	Holder Job
+}
+
+func (e *LeaseError) Error() string {
+	return fmt.Sprintf("%s is already %s", e.Holder.Action, e.Holder.State)
+}
+
+// Job is a single asynchronous action.
+type Job struct {
+	ID         string            `json:"id"`
+	Action     string            `json:"action"`
+	User       string            `json:"user,omitempty"`
+	Lease      string            `json:"lease,omitempty"`
+	Params     map[string]string `json:"params,omitempty"`
+	State      string            `json:"state"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	StartedAt  *time.Time        `json:"startedAt,omitempty"`
+	FinishedAt *time.Time        `json:"finishedAt,omitempty"`
+	ExitCode   *int              `json:"exitCode,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Result     json.RawMessage   `json:"result,omitempty"`
+	Logs       []string          `json:"logs,omitempty"`
+}
+
+// Done reports whether the job reached a terminal state.
+func (j *Job) Done() bool {
+	return j.State == StateSucceeded || j.State == StateFailed
+}
+
+// summary returns a copy without logs, for broadcast events.
+func (j *Job) summary() Job {
+	s := *j
+	s.Logs = nil
+	return s
+}
+
+// Func is the body of a job. Its result is stored as JSON; a non-nil error
+// fails the job.
+type Func func(ctx context.Context, run *Run) (any, error)
+
+// Event is sent to job watchers: either a job snapshot (without logs) on
+// state changes or a single log line.
+type Event struct {
+	Event string `json:"event"` // "job" or "log"
+	Data  any    `json:"data"`
+}
+
+// Manager queues jobs and runs them one at a time, so actions never
+// overlap, and persists the job history to a JSON file.
+type Manager struct {
+	path    string
+	publish func(event string, data any)
+	saveMu  sync.Mutex
+
+	mu       sync.Mutex
+	jobs     []*Job // oldest first
+	byID     map[string]*Job
+	watchers map[string]map[chan Event]struct{}
+	// pending counts the queue slots taken by submitted jobs the worker
+	// has not received yet, so the send in Submit never blocks.
+	pending int
+
+	queue chan queued
+}
+
+type queued struct {
+	job *Job
+	fn  Func
+}
+
+// Open loads the job history from path (a missing file is not an error).
+// Jobs left queued or running by a previous process are marked failed.
+// publish, if non-nil, receives a "job" event on every state change.
+func Open(path string, publish func(event string, data any)) (*Manager, error) {
+	m := &Manager{
+		path:     path,
+		publish:  publish,
+		byID:     make(map[string]*Job),
+		watchers: make(map[string]map[chan Event]struct{}),
+		queue:    make(chan queued, queueSize),
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &m.jobs); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	for _, j := range m.jobs {
+		if !j.Done() {
+			now := time.Now()
+			j.State = StateFailed
+			j.Error = "interrupted by restart"
+			j.FinishedAt = &now
+		}
+		m.byID[j.ID] = j
+	}
+	return m, nil
+}
+
+// Run executes queued jobs until ctx is cancelled; the running job's
+// context is cancelled with it.
+func (m *Manager) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case q := <-m.queue:
+			m.mu.Lock()
+			m.pending--
+			m.mu.Unlock()
+			m.execute(ctx, q.job, q.fn)
+		}
+	}
+}
+
+// Submit queues fn as a new job on behalf of user and returns its initial
+// snapshot. The job holds lease until it finishes; if another unfinished
+// job holds a conflicting lease, Submit fails with a *LeaseError. An empty
+// lease never conflicts.
+func (m *Manager) Submit(action, user, lease string, params map[string]string, fn Func) (Job, error) {
+	j := &Job{
+		ID:        newID(),
+		Action:    action,
+		User:      user,
+		Lease:     lease,
+		Params:    params,
+		State:     StateQueued,
+		CreatedAt: time.Now(),
+		Logs:      []string{},
+	}
+
+	m.mu.Lock()
+	if m.pending == cap(m.queue) {
+		m.mu.Unlock()
+		return Job{}, ErrQueueFull
+	}
+	for _, h := range m.holdersLocked() {
+		if leasesConflict(lease, h.Lease) {
+			m.mu.Unlock()
+			return Job{}, &LeaseError{Holder: h.summary()}
+		}
+	}
+	m.jobs = append(m.jobs, j)
+	m.byID[j.ID] = j
+	m.trimLocked()
+	m.pending++
+	snap := j.summary()
+	m.mu.Unlock()
+
+	// Announce the job before the worker can pick it up. The slot was
+	// reserved under the lock, so the send does not block.
+	m.changed(snap)
+	m.queue <- queued{job: j, fn: fn}
+	return snap, nil
+}
+
+// Holders returns the unfinished jobs holding a lease, oldest first.
+func (m *Manager) Holders() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Job{}
+	for _, j := range m.holdersLocked() {
+		out = append(out, j.summary())
+	}
+	return out
+}
+
+func (m *Manager) holdersLocked() []*Job {
+	var out []*Job
+	for _, j := range m.jobs {
+		if j.Lease != "" && !j.Done() {
+			out = append(out, j)
+		}
+	}
+	return out
+}
+
+func leasesConflict(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if a == GlobalLease || b == GlobalLease {
+		return true
+	}
+	held := strings.Split(b, ",")
+	for _, res := range strings.Split(a, ",") {
+		if slices.Contains(held, res) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns a snapshot of a job including its logs.
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.byID[id]
+	if !ok {
+		return Job{}, false
+	}
+	snap := *j
+	snap.Logs = append([]string(nil), j.Logs...)
+	return snap, true
+}
+
+// List returns job summaries, newest first.
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Job, 0, len(m.jobs))
+	for i := len(m.jobs) - 1; i >= 0; i-- {
+		out = append(out, m.jobs[i].summary())
+	}
+	return out
+}
+
+// Watch returns a snapshot of job id with its logs, a channel receiving
+// the events that follow it until the job finishes, and a function to stop
+// watching. Both are taken under one lock, so every log line is either in
+// the snapshot or on the channel, never both. The channel is closed on
+// completion.
+func (m *Manager) Watch(id string) (Job, <-chan Event, func(), bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.byID[id]
+	if !ok {
+		return Job{}, nil, nil, false
+	}
+	snap := *j
+	snap.Logs = append([]string(nil), j.Logs...)
+	ch := make(chan Event, 256)
+	if j.Done() {
+		close(ch)
+		return snap, ch, func() {}, true
+	}
+	if m.watchers[id] == nil {
+		m.watchers[id] = make(map[chan Event]struct{})
+	}
+	m.watchers[id][ch] = struct{}{}
+	stop := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := m.watchers[id][ch]; ok {
+			delete(m.watchers[id], ch)
+			close(ch)
+		}
+	}
+	return snap, ch, stop, true
+}
+
+func (m *Manager) execute(ctx context.Context, j *Job, fn Func) {
+	now := time.Now()
+	m.mu.Lock()
+	j.State = StateRunning
+	j.StartedAt = &now
+	snap := j.summary()
+	m.mu.Unlock()
+	m.changed(snap)
+
+	result, err := m.call(ctx, j, fn)
+
+	end := time.Now()
+	m.mu.Lock()
+	j.FinishedAt = &end
+	if err != nil {
+		j.State = StateFailed
+		j.Error = err.Error()
+	} else {
+		j.State = StateSucceeded
+	}
+	if result != nil {
+		if data, merr := json.Marshal(result); merr == nil {
+			j.Result = data
+		}
+	}
+	snap = j.summary()
+	m.mu.Unlock()
+	m.changed(snap)
+}
+
+// call runs fn, turning a panic into a job failure.
+func (m *Manager) call(ctx context.Context, j *Job, fn Func) (result any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return fn(ctx, &Run{m: m, job: j})
+}
+
+// changed broadcasts a state change, closes watchers of finished jobs and
+// persists the history.
+func (m *Manager) changed(snap Job) {
+	m.mu.Lock()
+	for ch := range m.watchers[snap.ID] {
+		select {
+		case ch <- Event{Event: "job", Data: snap}:
+		default:
+		}
+		if snap.Done() {
+			close(ch)
+		}
+	}
+	if snap.Done() {
+		delete(m.watchers, snap.ID)
+	}
+	m.mu.Unlock()
+
+	if m.publish != nil {
+		m.publish("job", snap)
+	}
+	if err := m.save(); err != nil {
+		log.Printf("[jobs] save: %v", err)
+	}
+}
+
+func (m *Manager) trimLocked() {
+	for len(m.jobs) > maxJobs && m.jobs[0].Done() {
+		delete(m.byID, m.jobs[0].ID)
+		m.jobs = m.jobs[1:]
+	}
+}
+
+// save writes the history atomically.
+func (m *Manager) save() error {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+	m.mu.Lock()
+	data, err := json.Marshal(m.jobs)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// Run is the handle a job body uses to report progress.
+type Run struct {
+	m   *Manager
+	job *Job
+}
+
+// ID returns the job ID.
+func (r *Run) ID() string { return r.job.ID }
+
+// Log appends a line to the job output.
+func (r *Run) Log(line string) {
+	if len(line) > maxLineLen {
+		line = line[:maxLineLen]
+	}
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(r.job.Logs) >= maxLogLines {
+		if len(r.job.Logs) == maxLogLines {
+			r.job.Logs = append(r.job.Logs, "[output truncated]")
+		}
+		return
+	}
+	r.job.Logs = append(r.job.Logs, line)
+	for ch := range m.watchers[r.job.ID] {
+		select {
+		case ch <- Event{Event: "log", Data: line}:
+		default:
+		}
+	}
+}
+
+// Logf appends a formatted line to the job output.
+func (r *Run) Logf(format string, args ...any) {
+	r.Log(fmt.Sprintf(format, args...))
+}
+
+// SetExitCode records the exit code of the job's helper container.
+func (r *Run) SetExitCode(code int) {
+	r.m.mu.Lock()
+	r.job.ExitCode = &code
+	r.m.mu.Unlock()
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
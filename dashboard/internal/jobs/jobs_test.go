@@ -0,0 +1,165 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTest(t *testing.T) *Manager {
+	t.Helper()
+	m, err := Open(filepath.Join(t.TempDir(), "jobs.json"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func noop(context.Context, *Run) (any, error) { return nil, nil }
+
+// startWorker runs m until the test ends, waiting for the worker to stop
+// so that it does not write to the removed temporary directory.
+func startWorker(t *testing.T, m *Manager) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestLeasesConflict(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "", b: "", want: false},
+		{a: "", b: GlobalLease, want: false},
+		{a: GlobalLease, b: "container:sonarr", want: true},
+		{a: "container:sonarr", b: GlobalLease, want: true},
+		{a: "container:sonarr", b: "container:sonarr", want: true},
+		{a: "container:sonarr", b: "container:radarr", want: false},
+		{a: "container:gluetun,container:qbittorrent", b: "container:qbittorrent", want: true},
+		{a: "container:qbittorrent", b: "container:flaresolverr,container:gluetun,container:qbittorrent", want: true},
+		{a: "container:gluetun,container:qbittorrent", b: "container:prowlarr,container:sonarr", want: false},
+		// Resources match whole, not by prefix.
+		{a: "container:sonarr", b: "container:sonarr-4k", want: false},
+	}
+	for _, tt := range tests {
+		if got := leasesConflict(tt.a, tt.b); got != tt.want {
+			t.Errorf("leasesConflict(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSubmitLeases(t *testing.T) {
+	m := openTest(t)
+	// Without a worker the first job stays queued and keeps its lease.
+	if _, err := m.Submit("restart-service", "alice", "container:gluetun,container:qbittorrent", nil, noop); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		lease    string
+		conflict bool
+	}{
+		{lease: "container:qbittorrent", conflict: true},
+		{lease: GlobalLease, conflict: true},
+		{lease: "container:sonarr", conflict: false},
+		{lease: "", conflict: false},
+		// container:sonarr is now held as well.
+		{lease: "container:prowlarr,container:sonarr", conflict: true},
+	}
+	for _, tt := range tests {
+		_, err := m.Submit("start-service", "bob", tt.lease, nil, noop)
+		var le *LeaseError
+		if got := errors.As(err, &le); got != tt.conflict {
+			t.Errorf("Submit(lease %q) = %v, want conflict %v", tt.lease, err, tt.conflict)
+			continue
+		}
+		if tt.conflict && le.Holder.Action != "restart-service" && le.Holder.Action != "start-service" {
+			t.Errorf("holder = %+v", le.Holder)
+		}
+	}
+	if got := len(m.Holders()); got != 2 {
+		t.Errorf("Holders() has %d jobs, want 2", got)
+	}
+}
+
+func TestSubmitQueueBound(t *testing.T) {
+	m := openTest(t)
+	for i := 0; i < queueSize; i++ {
+		if _, err := m.Submit("noop", "", "", nil, noop); err != nil {
+			t.Fatalf("Submit %d = %v", i, err)
+		}
+	}
+	if _, err := m.Submit("noop", "", "", nil, noop); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Submit on a full queue = %v, want ErrQueueFull", err)
+	}
+
+	// Once the worker takes jobs off the queue, slots free up again.
+	startWorker(t, m)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := m.Submit("noop", "", "", nil, noop)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrQueueFull) || time.Now().After(deadline) {
+			t.Fatalf("Submit after the worker started = %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatchSnapshot(t *testing.T) {
+	m := openTest(t)
+	startWorker(t, m)
+
+	logged, release := make(chan struct{}), make(chan struct{})
+	job, err := m.Submit("test", "", "", nil, func(ctx context.Context, run *Run) (any, error) {
+		run.Log("before watch")
+		close(logged)
+		<-release
+		run.Log("after watch")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-logged
+
+	snap, ch, stop, ok := m.Watch(job.ID)
+	if !ok {
+		t.Fatal("Watch() did not find the job")
+	}
+	defer stop()
+	close(release)
+
+	lines := append([]string(nil), snap.Logs...)
+	var last Job
+	for ev := range ch {
+		switch ev.Event {
+		case "log":
+			lines = append(lines, ev.Data.(string))
+		case "job":
+			last = ev.Data.(Job)
+		}
+	}
+	if len(lines) != 2 || lines[0] != "before watch" || lines[1] != "after watch" {
+		t.Errorf("lines = %q, want each line once", lines)
+	}
+	if last.State != StateSucceeded {
+		t.Errorf("last state = %q, want %q", last.State, StateSucceeded)
+	}
+
+	if _, _, _, ok := m.Watch("missing"); ok {
+		t.Error("Watch() found an unknown job")
+	}
+}
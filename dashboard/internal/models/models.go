@@ -14,6 +14,11 @@ type DashboardData struct {
 	Library    LibraryCounts    `json:"library"`
 	Health     []HealthWarning  `json:"health"`
 	SSHSecurity SSHSecurityData `json:"sshSecurity"`
+	AuthSecurity AuthSecurityData `json:"authSecurity"`
+	VPN        VPNData          `json:"vpn"`
+	VPNLeak    VPNLeakData      `json:"vpnLeak"`
+	Alerts     []Alert          `json:"alerts"`
+	Collectors []CollectorStatus `json:"collectors"`
 	UpdatedAt  time.Time        `json:"updatedAt"`
 }
 
@@ -61,13 +66,51 @@ type GPUMetrics struct {
 
 // ServiceStatus represents the health of a Docker container.
 type ServiceStatus struct {
-	Name      string `json:"name"`
-	Status    string `json:"status"`
-	Health    string `json:"health"`
-	Image     string `json:"image"`
-	IP        string `json:"ip"`
-	Uptime    string `json:"uptime"`
-	ExternalURL string `json:"externalUrl,omitempty"`
+	Name         string          `json:"name"`
+	Status       string          `json:"status"`
+	Health       string          `json:"health"`
+	Image        string          `json:"image"`
+	IP           string          `json:"ip"`
+	Uptime       string          `json:"uptime"`
+	ExternalURL  string          `json:"externalUrl,omitempty"`
+	RestartCount int             `json:"restartCount"`
+	LastExitCode *int            `json:"lastExitCode,omitempty"`
+	Stats        *ContainerStats `json:"stats,omitempty"`
+}
+
+// ContainerStats is a resource usage sample of a running container.
+// Network and block I/O are cumulative byte counters; the rates are
+// per-second averages since the previous sample.
+type ContainerStats struct {
+	CPUPercent     float64 `json:"cpuPercent"`
+	MemUsage       uint64  `json:"memUsage"`
+	MemLimit       uint64  `json:"memLimit"`
+	MemPercent     float64 `json:"memPercent"`
+	NetRx          uint64  `json:"netRx"`
+	NetTx          uint64  `json:"netTx"`
+	BlockRead      uint64  `json:"blockRead"`
+	BlockWrite     uint64  `json:"blockWrite"`
+	NetRxRate      float64 `json:"netRxRate"`
+	NetTxRate      float64 `json:"netTxRate"`
+	BlockReadRate  float64 `json:"blockReadRate"`
+	BlockWriteRate float64 `json:"blockWriteRate"`
+}
+
+// ContainerEvent is a single Docker lifecycle event for a container.
+type ContainerEvent struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	Health   string    `json:"health,omitempty"`
+	ExitCode *int      `json:"exitCode,omitempty"`
+}
+
+// ServiceEvents is the recent event timeline of a container.
+type ServiceEvents struct {
+	Name         string           `json:"name"`
+	RestartCount int              `json:"restartCount"`
+	LastExitCode *int             `json:"lastExitCode,omitempty"`
+	LastOOM      *time.Time       `json:"lastOom,omitempty"`
+	Events       []ContainerEvent `json:"events"`
 }
 
 // StreamSession represents a Jellyfin playback session.
@@ -105,6 +148,91 @@ type TorrentBrief struct {
 	Ratio    float64 `json:"ratio"`
 }
 
+// Torrent is a single qBittorrent torrent with its full details.
+type Torrent struct {
+	Hash         string    `json:"hash"`
+	Name         string    `json:"name"`
+	State        string    `json:"state"`
+	Progress     float64   `json:"progress"` // percent
+	Size         uint64    `json:"size"`
+	Downloaded   uint64    `json:"downloaded"`
+	Uploaded     uint64    `json:"uploaded"`
+	DLSpeed      uint64    `json:"dlSpeed"`
+	UPSpeed      uint64    `json:"upSpeed"`
+	Ratio        float64   `json:"ratio"`
+	ETA          int64     `json:"eta"` // seconds, -1 when unknown
+	Category     string    `json:"category"`
+	Tags         []string  `json:"tags"`
+	AddedOn      time.Time `json:"addedOn"`
+	CompletedOn  time.Time `json:"completedOn"`
+	LastActivity time.Time `json:"lastActivity"`
+	SeedingTime  int64     `json:"seedingTime"` // seconds
+	Tracker      string    `json:"tracker"`
+	Seeds        int       `json:"seeds"`       // connected seeders
+	Leechs       int       `json:"leechs"`      // connected leechers
+	SwarmSeeds   int       `json:"swarmSeeds"`  // seeders in the swarm
+	SwarmLeechs  int       `json:"swarmLeechs"` // leechers in the swarm
+	SavePath     string    `json:"savePath"`
+}
+
+// TorrentPolicyReport is the outcome of the last torrent policy run.
+type TorrentPolicyReport struct {
+	Time      time.Time     `json:"time"`
+	Remediate bool          `json:"remediate"`
+	DryRun    bool          `json:"dryRun"`
+	Checked   int           `json:"checked"`
+	Flagged   []TorrentFlag `json:"flagged"`
+	// Actions are the most recent remediations, newest first, kept
+	// across runs.
+	Actions []TorrentFlag `json:"actions"`
+}
+
+// TorrentFlag is a torrent matched by a policy rule and what was done
+// about it.
+type TorrentFlag struct {
+	Time     time.Time `json:"time"`
+	Hash     string    `json:"hash"`
+	Name     string    `json:"name"`
+	Category string    `json:"category"`
+	State    string    `json:"state"`
+	Rule     string    `json:"rule"` // stalled, no-seeds, ratio or seed-time
+	Reason   string    `json:"reason"`
+	Owner    string    `json:"owner,omitempty"`   // Sonarr or Radarr queue holding it
+	Action   string    `json:"action,omitempty"`  // blocklist-and-search or remove
+	Outcome  string    `json:"outcome,omitempty"` // done, dry-run, skipped or error
+	Error    string    `json:"error,omitempty"`
+}
+
+// VPNData holds the Gluetun tunnel status.
+type VPNData struct {
+	Status         string `json:"status"`             // running, stopped... or unreachable
+	PublicIP       string `json:"publicIp,omitempty"` // empty when unreadable
+	Country        string `json:"country,omitempty"`
+	Region         string `json:"region,omitempty"`
+	City           string `json:"city,omitempty"`
+	Organization   string `json:"organization,omitempty"`
+	ForwardedPort  int    `json:"forwardedPort"`  // 0 when none
+	QbitListenPort int    `json:"qbitListenPort"` // 0 when unknown
+}
+
+// VPNLeakData is the outcome of the last VPN leak check.
+type VPNLeakData struct {
+	CheckedAt  time.Time           `json:"checkedAt"`
+	HostIP     string              `json:"hostIp"`
+	Containers []VPNContainerCheck `json:"containers"`
+}
+
+// VPNContainerCheck is the leak check of a container that must run in
+// gluetun's network namespace.
+type VPNContainerCheck struct {
+	Name        string `json:"name"`
+	NetworkMode string `json:"networkMode"`
+	BehindVPN   bool   `json:"behindVpn"`
+	EgressIP    string `json:"egressIp,omitempty"`
+	Leak        bool   `json:"leak"` // egress IP is the host's
+	Error       string `json:"error,omitempty"`
+}
+
 // DownloadItem represents a queued download from Radarr/Sonarr/SABnzbd.
 type DownloadItem struct {
 	Title    string  `json:"title"`
@@ -147,6 +275,38 @@ type HealthWarning struct {
 	Message string `json:"message"`
 }
 
+// Alert is a rule instance evaluated by the alert engine.
+type Alert struct {
+	ID         string     `json:"id"`
+	Rule       string     `json:"rule"`
+	Key        string     `json:"key,omitempty"`
+	Severity   string     `json:"severity"`
+	State      string     `json:"state"`
+	Value      float64    `json:"value"`
+	Threshold  float64    `json:"threshold"`
+	Message    string     `json:"message"`
+	StartsAt   time.Time  `json:"startsAt"`
+	FiredAt    *time.Time `json:"firedAt,omitempty"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+}
+
+// CollectorStatus tracks the health of a single data collector.
+// State is one of pending, ok, error, unconfigured or disabled.
+type CollectorStatus struct {
+	Name                string     `json:"name"`
+	State               string     `json:"state"`
+	Enabled             bool       `json:"enabled"`
+	IntervalSeconds     float64    `json:"intervalSeconds"`
+	LastRun             *time.Time `json:"lastRun,omitempty"`
+	LastSuccess         *time.Time `json:"lastSuccess,omitempty"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastErrorAt         *time.Time `json:"lastErrorAt,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastDurationMs      float64    `json:"lastDurationMs"`
+	Runs                uint64     `json:"runs"`
+	Errors              uint64     `json:"errors"`
+}
+
 // LibraryCounts holds Jellyfin library item counts.
 type LibraryCounts struct {
 	Movies   int `json:"movies"`
@@ -187,6 +347,22 @@ type SSHAuthEvent struct {
 	Success  bool   `json:"success"`
 }
 
+// AuthSecurityData holds the dashboard's own failed sign-ins and lockouts.
+type AuthSecurityData struct {
+	Failed24h int           `json:"failed24h"`
+	Locked    []AuthLockout `json:"locked"`   // lockouts still in force
+	Lockouts  []AuthLockout `json:"lockouts"` // recent lockouts, newest first
+}
+
+// AuthLockout is a client IP or username locked out after failed sign-ins.
+type AuthLockout struct {
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"` // "ip" or "user"
+	Key      string    `json:"key"`
+	Failures int       `json:"failures"`
+	Until    time.Time `json:"until"`
+}
+
 // ArrStats holds combined stats for Radarr/Sonarr.
 type ArrStats struct {
 	Movies     int    `json:"movies"`
@@ -2,6 +2,7 @@ package store
 
 import (
 	"encoding/json"
+	"sort"
 	"sync"
 
 	"arcticmon/internal/models"
@@ -11,15 +12,34 @@ import (
 type Store struct {
 	mu   sync.RWMutex
 	data models.DashboardData
+	// torrentList is the full torrent list, kept out of DashboardData
+	// because it is too large to broadcast.
+	torrentList []models.Torrent
+	// torrentPolicy is the last torrent policy report.
+	torrentPolicy models.TorrentPolicyReport
 
 	subsMu  sync.Mutex
-	subs    map[chan []byte]struct{}
+	subs    map[*Subscription]struct{}
+	maxSubs int
+	lastID  uint64
+	ring    []Event
+
+	listenersMu sync.RWMutex
+	listeners   []func(event string)
+
+	eventsMu sync.Mutex
+	events   map[string]*models.ServiceEvents
 }
 
+// maxServiceEvents bounds the per-container event timeline.
+const maxServiceEvents = 200
+
 // New creates a new Store.
 func New() *Store {
 	return &Store{
-		subs: make(map[chan []byte]struct{}),
+		subs:    make(map[*Subscription]struct{}),
+		maxSubs: DefaultMaxSubscribers,
+		events:  make(map[string]*models.ServiceEvents),
 	}
 }
 
@@ -38,14 +58,102 @@ func (s *Store) UpdateHost(h models.HostMetrics) {
 	s.notify("host", h)
 }
 
-// UpdateServices updates service statuses.
+// UpdateServices updates service statuses. Restart counts and exit codes
+// are filled in from the event timelines.
 func (s *Store) UpdateServices(svcs []models.ServiceStatus) {
+	s.eventsMu.Lock()
+	for i := range svcs {
+		if tl, ok := s.events[svcs[i].Name]; ok {
+			svcs[i].RestartCount = tl.RestartCount
+			svcs[i].LastExitCode = tl.LastExitCode
+		}
+	}
+	s.eventsMu.Unlock()
+
+	s.mu.Lock()
+	s.data.Services = svcs
+	s.mu.Unlock()
+	s.notify("services", svcs)
+}
+
+// RecordServiceEvent appends a Docker event to the container's timeline
+// and patches its ServiceStatus so changes show up before the next poll.
+// A restart is counted for "restart" events and for a "start" directly
+// following a "die" (restart policy or autoheal).
+func (s *Store) RecordServiceEvent(name string, ev models.ContainerEvent) {
+	s.eventsMu.Lock()
+	tl, ok := s.events[name]
+	if !ok {
+		tl = &models.ServiceEvents{Name: name}
+		s.events[name] = tl
+	}
+	prev := ""
+	if n := len(tl.Events); n > 0 {
+		prev = tl.Events[n-1].Action
+	}
+	switch ev.Action {
+	case "restart":
+		tl.RestartCount++
+	case "start":
+		if prev == "die" {
+			tl.RestartCount++
+		}
+	case "die":
+		tl.LastExitCode = ev.ExitCode
+	case "oom":
+		t := ev.Time
+		tl.LastOOM = &t
+	}
+	// Copy-on-write so timelines returned by ServiceEvents stay immutable.
+	events := append([]models.ContainerEvent(nil), tl.Events...)
+	events = append(events, ev)
+	if len(events) > maxServiceEvents {
+		events = events[len(events)-maxServiceEvents:]
+	}
+	tl.Events = events
+	restarts, exitCode := tl.RestartCount, tl.LastExitCode
+	s.eventsMu.Unlock()
+
 	s.mu.Lock()
+	svcs := append([]models.ServiceStatus(nil), s.data.Services...)
+	for i := range svcs {
+		if svcs[i].Name != name {
+			continue
+		}
+		svc := &svcs[i]
+		svc.RestartCount = restarts
+		svc.LastExitCode = exitCode
+		switch ev.Action {
+		case "start":
+			svc.Status = "running"
+			if svc.Health == "none" {
+				svc.Health = "running"
+			}
+		case "die", "stop":
+			svc.Status = "exited"
+			svc.Health = "none"
+		case "health_status":
+			svc.Health = ev.Health
+		}
+	}
 	s.data.Services = svcs
 	s.mu.Unlock()
+
+	s.notify("serviceEvent", map[string]any{"name": name, "event": ev})
 	s.notify("services", svcs)
 }
 
+// ServiceEvents returns the event timeline of a container.
+func (s *Store) ServiceEvents(name string) (models.ServiceEvents, bool) {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	tl, ok := s.events[name]
+	if !ok {
+		return models.ServiceEvents{Name: name, Events: []models.ContainerEvent{}}, false
+	}
+	return *tl, true
+}
+
 // UpdateStreams updates Jellyfin stream sessions.
 func (s *Store) UpdateStreams(ss []models.StreamSession) {
 	s.mu.Lock()
@@ -62,6 +170,36 @@ func (s *Store) UpdateTorrents(t models.TorrentData) {
 	s.notify("torrents", t)
 }
 
+// UpdateTorrentList replaces the full torrent list.
+func (s *Store) UpdateTorrentList(list []models.Torrent) {
+	s.mu.Lock()
+	s.torrentList = list
+	s.mu.Unlock()
+}
+
+// TorrentList returns the full torrent list. The slice is shared and must
+// not be modified.
+func (s *Store) TorrentList() []models.Torrent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.torrentList
+}
+
+// UpdateTorrentPolicy stores the report of a torrent policy run.
+func (s *Store) UpdateTorrentPolicy(r models.TorrentPolicyReport) {
+	s.mu.Lock()
+	s.torrentPolicy = r
+	s.mu.Unlock()
+	s.notify("torrentPolicy", r)
+}
+
+// TorrentPolicy returns the last torrent policy report.
+func (s *Store) TorrentPolicy() models.TorrentPolicyReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.torrentPolicy
+}
+
 // UpdateDownloads updates download queue items.
 func (s *Store) UpdateDownloads(d []models.DownloadItem) {
 	s.mu.Lock()
@@ -102,6 +240,30 @@ func (s *Store) UpdateSSHSecurity(d models.SSHSecurityData) {
 	s.notify("sshSecurity", d)
 }
 
+// UpdateAuthSecurity updates dashboard sign-in failures and lockouts.
+func (s *Store) UpdateAuthSecurity(d models.AuthSecurityData) {
+	s.mu.Lock()
+	s.data.AuthSecurity = d
+	s.mu.Unlock()
+	s.notify("authSecurity", d)
+}
+
+// UpdateVPN updates the Gluetun tunnel status.
+func (s *Store) UpdateVPN(v models.VPNData) {
+	s.mu.Lock()
+	s.data.VPN = v
+	s.mu.Unlock()
+	s.notify("vpn", v)
+}
+
+// UpdateVPNLeak updates the VPN leak check results.
+func (s *Store) UpdateVPNLeak(v models.VPNLeakData) {
+	s.mu.Lock()
+	s.data.VPNLeak = v
+	s.mu.Unlock()
+	s.notify("vpnLeak", v)
+}
+
 // UpdateHealth updates health warnings.
 func (s *Store) UpdateHealth(h []models.HealthWarning) {
 	s.mu.Lock()
@@ -110,45 +272,211 @@ func (s *Store) UpdateHealth(h []models.HealthWarning) {
 	s.notify("health", h)
 }
 
-const maxSubscribers = 20
+// ReplaceHealth replaces the health warnings of source with warnings,
+// keeping those of other sources. Filtering under the lock keeps parallel
+// collectors from dropping each other's warnings.
+func (s *Store) ReplaceHealth(source string, warnings []models.HealthWarning) {
+	s.mu.Lock()
+	h := make([]models.HealthWarning, 0, len(s.data.Health)+len(warnings))
+	for _, w := range s.data.Health {
+		if w.Source != source {
+			h = append(h, w)
+		}
+	}
+	h = append(h, warnings...)
+	s.data.Health = h
+	s.mu.Unlock()
+	s.notify("health", h)
+}
+
+// UpdateAlerts updates active and recently resolved alerts.
+func (s *Store) UpdateAlerts(a []models.Alert) {
+	s.mu.Lock()
+	s.data.Alerts = a
+	s.mu.Unlock()
+	s.notify("alerts", a)
+}
+
+// UpdateCollector applies fn to the named collector's status, creating it
+// if needed, and notifies subscribers with the full collector list.
+func (s *Store) UpdateCollector(name string, fn func(*models.CollectorStatus)) {
+	s.mu.Lock()
+	idx := -1
+	for i := range s.data.Collectors {
+		if s.data.Collectors[i].Name == name {
+			idx = i
+			break
+		}
+	}
+	// Copy-on-write so snapshots returned by Get are never mutated.
+	collectors := append([]models.CollectorStatus(nil), s.data.Collectors...)
+	if idx < 0 {
+		collectors = append(collectors, models.CollectorStatus{Name: name})
+		idx = len(collectors) - 1
+	}
+	fn(&collectors[idx])
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].Name < collectors[j].Name })
+	s.data.Collectors = collectors
+	s.mu.Unlock()
+	s.notify("collectors", collectors)
+}
 
-// Subscribe returns a channel that receives SSE event payloads.
-// Returns nil if the max subscriber limit is reached.
-func (s *Store) Subscribe() chan []byte {
+// OnUpdate registers fn to be called after every store update with the
+// event name. Listeners run synchronously on the updating goroutine and
+// must not block.
+func (s *Store) OnUpdate(fn func(event string)) {
+	s.listenersMu.Lock()
+	s.listeners = append(s.listeners, fn)
+	s.listenersMu.Unlock()
+}
+
+// Publish sends an event that is not part of the dashboard state (job
+// progress, for example) to SSE subscribers and update listeners.
+func (s *Store) Publish(event string, data any) {
+	s.notify(event, data)
+}
+
+// DefaultMaxSubscribers is the SSE subscriber limit until
+// SetMaxSubscribers is called.
+const DefaultMaxSubscribers = 20
+
+// eventBuffer is the number of recent events kept for replay.
+const eventBuffer = 512
+
+// Event is a published update. IDs increase monotonically from 1; Data is
+// the JSON encoding of the payload.
+type Event struct {
+	ID   uint64
+	Name string
+	Data []byte
+}
+
+// Subscription receives the events published after it was created.
+type Subscription struct {
+	// C is closed on Unsubscribe, and when the subscriber falls behind:
+	// it should then reconnect and replay from its last event ID.
+	C chan Event
+	// LastID is the ID of the last event published before subscribing.
+	LastID uint64
+	// Replay holds the buffered events after the requested ID, and Missed
+	// is true when some of them were already evicted.
+	Replay []Event
+	Missed bool
+
+	topics map[string]bool
+}
+
+func (sub *Subscription) wants(name string) bool {
+	return len(sub.topics) == 0 || sub.topics[name]
+}
+
+// SetMaxSubscribers changes the SSE subscriber limit; existing
+// subscribers are kept.
+func (s *Store) SetMaxSubscribers(n int) {
+	s.subsMu.Lock()
+	s.maxSubs = n
+	s.subsMu.Unlock()
+}
+
+// Subscribe registers a subscriber to topics (every topic if empty). When
+// afterID is non-zero, the buffered events after it are returned in
+// Replay. Returns nil if the subscriber limit is reached.
+func (s *Store) Subscribe(topics []string, afterID uint64) *Subscription {
 	s.subsMu.Lock()
 	defer s.subsMu.Unlock()
-	if len(s.subs) >= maxSubscribers {
+	if len(s.subs) >= s.maxSubs {
 		return nil
 	}
-	ch := make(chan []byte, 64)
-	s.subs[ch] = struct{}{}
-	return ch
+	sub := &Subscription{C: make(chan Event, 64), LastID: s.lastID}
+	if len(topics) > 0 {
+		sub.topics = make(map[string]bool, len(topics))
+		for _, t := range topics {
+			sub.topics[t] = true
+		}
+	}
+	if afterID > 0 && afterID < s.lastID {
+		if len(s.ring) == 0 || s.ring[0].ID > afterID+1 {
+			sub.Missed = true
+		}
+		for _, ev := range s.ring {
+			if ev.ID > afterID && sub.wants(ev.Name) {
+				sub.Replay = append(sub.Replay, ev)
+			}
+		}
+	} else if afterID > s.lastID {
+		// IDs from before a restart.
+		sub.Missed = true
+	}
+	s.subs[sub] = struct{}{}
+	return sub
 }
 
-// Unsubscribe removes a subscriber channel.
-func (s *Store) Unsubscribe(ch chan []byte) {
+// Unsubscribe removes a subscriber.
+func (s *Store) Unsubscribe(sub *Subscription) {
 	s.subsMu.Lock()
-	delete(s.subs, ch)
-	s.subsMu.Unlock()
-	close(ch)
+	defer s.subsMu.Unlock()
+	if _, ok := s.subs[sub]; ok {
+		delete(s.subs, sub)
+		close(sub.C)
+	}
+}
+
+// Snapshot returns the current dashboard state keyed by event name.
+func (s *Store) Snapshot() map[string]any {
+	d := s.Get()
+	return map[string]any{
+		"host":          d.Host,
+		"services":      d.Services,
+		"streams":       d.Streams,
+		"torrents":      d.Torrents,
+		"downloads":     d.Downloads,
+		"requests":      d.Requests,
+		"transcodes":    d.Transcodes,
+		"library":       d.Library,
+		"health":        d.Health,
+		"sshSecurity":   d.SSHSecurity,
+		"authSecurity":  d.AuthSecurity,
+		"vpn":           d.VPN,
+		"vpnLeak":       d.VPNLeak,
+		"alerts":        d.Alerts,
+		"collectors":    d.Collectors,
+		"torrentPolicy": s.TorrentPolicy(),
+	}
 }
 
-// notify sends a JSON event to all SSE subscribers.
+// notify sends an event to update listeners and SSE subscribers, and keeps
+// it for replay. Subscribers too slow to keep up are dropped so that they
+// reconnect and replay instead of silently missing events.
 func (s *Store) notify(event string, data any) {
-	msg, err := json.Marshal(map[string]any{
-		"event": event,
-		"data":  data,
-	})
+	s.listenersMu.RLock()
+	listeners := s.listeners
+	s.listenersMu.RUnlock()
+	for _, fn := range listeners {
+		fn(event)
+	}
+
+	msg, err := json.Marshal(data)
 	if err != nil {
 		return
 	}
 	s.subsMu.Lock()
 	defer s.subsMu.Unlock()
-	for ch := range s.subs {
+	s.lastID++
+	ev := Event{ID: s.lastID, Name: event, Data: msg}
+	if len(s.ring) == eventBuffer {
+		copy(s.ring, s.ring[1:])
+		s.ring = s.ring[:eventBuffer-1]
+	}
+	s.ring = append(s.ring, ev)
+	for sub := range s.subs {
+		if !sub.wants(event) {
+			continue
+		}
 		select {
-		case ch <- msg:
+		case sub.C <- ev:
 		default:
-			// Slow consumer, drop message
+			delete(s.subs, sub)
+			close(sub.C)
 		}
 	}
 }
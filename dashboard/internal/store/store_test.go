@@ -0,0 +1,117 @@
+package store
+
+import (
+	"testing"
+
+	"arcticmon/internal/models"
+)
+
+// publishN publishes n events alternating between "host" and "job".
+func publishN(s *Store, n int) {
+	for i := 0; i < n; i++ {
+		name := "host"
+		if i%2 == 1 {
+			name = "job"
+		}
+		s.Publish(name, i)
+	}
+}
+
+func ids(evs []Event) []uint64 {
+	var out []uint64
+	for _, ev := range evs {
+		out = append(out, ev.ID)
+	}
+	return out
+}
+
+func TestSubscribeReplay(t *testing.T) {
+	tests := []struct {
+		name       string
+		published  int
+		topics     []string
+		afterID    uint64
+		wantReplay []uint64
+		wantMissed bool
+	}{
+		{name: "fresh", published: 5},
+		{name: "resume", published: 5, afterID: 3, wantReplay: []uint64{4, 5}},
+		{name: "up to date", published: 5, afterID: 5},
+		{name: "topic filter", published: 6, topics: []string{"job"}, afterID: 1, wantReplay: []uint64{2, 4, 6}},
+		{name: "ID from before a restart", published: 5, afterID: 10, wantMissed: true},
+		{name: "nothing published since a restart", afterID: 3, wantMissed: true},
+		{name: "last evicted event", published: eventBuffer + 2, afterID: 2, wantReplay: seq(3, eventBuffer+2)},
+		{name: "evicted events", published: eventBuffer + 2, afterID: 1, wantReplay: seq(3, eventBuffer+2), wantMissed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New()
+			publishN(s, tt.published)
+			sub := s.Subscribe(tt.topics, tt.afterID)
+			if sub == nil {
+				t.Fatal("Subscribe() = nil")
+			}
+			defer s.Unsubscribe(sub)
+
+			if sub.LastID != uint64(tt.published) {
+				t.Errorf("LastID = %d, want %d", sub.LastID, tt.published)
+			}
+			if sub.Missed != tt.wantMissed {
+				t.Errorf("Missed = %v, want %v", sub.Missed, tt.wantMissed)
+			}
+			got := ids(sub.Replay)
+			if len(got) != len(tt.wantReplay) {
+				t.Fatalf("Replay = %v, want %v", got, tt.wantReplay)
+			}
+			for i := range got {
+				if got[i] != tt.wantReplay[i] {
+					t.Fatalf("Replay = %v, want %v", got, tt.wantReplay)
+				}
+			}
+		})
+	}
+}
+
+func seq(from, to uint64) []uint64 {
+	var out []uint64
+	for i := from; i <= to; i++ {
+		out = append(out, i)
+	}
+	return out
+}
+
+func TestSubscribeLive(t *testing.T) {
+	s := New()
+	s.SetMaxSubscribers(2)
+	all := s.Subscribe(nil, 0)
+	health := s.Subscribe([]string{"health"}, 0)
+	if s.Subscribe(nil, 0) != nil {
+		t.Error("Subscribe() beyond the limit succeeded")
+	}
+
+	s.UpdateHost(models.HostMetrics{})
+	s.ReplaceHealth("VPN", []models.HealthWarning{{Source: "VPN", Type: "critical", Message: "leak"}})
+	if ev := <-all.C; ev.Name != "host" || ev.ID != 1 {
+		t.Errorf("first event = %s #%d, want host #1", ev.Name, ev.ID)
+	}
+	if ev := <-all.C; ev.Name != "health" {
+		t.Errorf("second event = %s, want health", ev.Name)
+	}
+	if ev := <-health.C; ev.Name != "health" || ev.ID != 2 {
+		t.Errorf("filtered event = %s #%d, want health #2", ev.Name, ev.ID)
+	}
+
+	// A subscriber that stops reading is dropped so that it reconnects.
+	publishN(s, cap(all.C)+1)
+	n := 0
+	for range all.C {
+		n++
+	}
+	if n != cap(all.C) {
+		t.Errorf("slow subscriber got %d events before being dropped, want %d", n, cap(all.C))
+	}
+	if s.Subscribe(nil, 0) == nil {
+		t.Error("dropped subscriber still counts against the limit")
+	}
+	s.Unsubscribe(all) // no-op once dropped
+}
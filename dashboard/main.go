@@ -7,12 +7,21 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"arcticmon/internal/alert"
 	"arcticmon/internal/api"
+	"arcticmon/internal/audit"
+	"arcticmon/internal/auth"
 	"arcticmon/internal/collector"
 	"arcticmon/internal/config"
+	"arcticmon/internal/history"
+	"arcticmon/internal/jobs"
+	"arcticmon/internal/models"
 	"arcticmon/internal/store"
 )
 
@@ -20,18 +29,96 @@ import (
 var webFS embed.FS
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+	if cfg.File != "" {
+		log.Printf("loaded config file %s", cfg.File)
+	}
 	st := store.New()
+	st.SetMaxSubscribers(cfg.SSEMaxSubscribers)
+
+	hist, err := history.Open(filepath.Join(cfg.DataDir, "history.gob"), history.DefaultTiers)
+	if err != nil {
+		log.Fatalf("history: %v", err)
+	}
+
+	rules, err := alert.LoadRules(cfg.AlertRulesFile)
+	if err != nil {
+		log.Fatalf("alert rules: %v", err)
+	}
+	alerts := alert.NewEngine(st, rules, alert.NotifiersFromConfig(cfg))
+	alerts.Start()
 
 	// Start collectors
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	orch := collector.NewOrchestrator(st, cfg)
+	go hist.Run(ctx, time.Minute)
+
+	orch := collector.NewOrchestrator(st, cfg, hist)
 	orch.Start(ctx)
 
+	auditLog, err := audit.Open(filepath.Join(cfg.DataDir, "audit.log"), audit.DefaultMaxSize, audit.DefaultMaxFiles)
+	if err != nil {
+		log.Fatalf("audit log: %v", err)
+	}
+	defer auditLog.Close()
+
+	jobMgr, err := jobs.Open(filepath.Join(cfg.DataDir, "jobs.json"), func(event string, data any) {
+		st.Publish(event, data)
+		if j, ok := data.(jobs.Job); ok && j.Done() {
+			auditJob(auditLog, j)
+		}
+	})
+	if err != nil {
+		log.Fatalf("jobs: %v", err)
+	}
+	go jobMgr.Run(ctx)
+
+	users, err := auth.OpenUsers(filepath.Join(cfg.DataDir, "users.json"))
+	if err != nil {
+		log.Fatalf("users: %v", err)
+	}
+	if created, err := users.Seed(cfg.DashboardUser, cfg.DashboardPass); err != nil {
+		log.Fatalf("users: %v", err)
+	} else if created {
+		log.Printf("created admin user %q from DASHBOARD_USER", cfg.DashboardUser)
+	}
+	if users.Len() == 0 {
+		log.Printf("no dashboard users configured, authentication is disabled")
+	}
+
+	tokens, err := auth.OpenTokens(filepath.Join(cfg.DataDir, "tokens.json"))
+	if err != nil {
+		log.Fatalf("tokens: %v", err)
+	}
+
+	// Failed sign-ins feed the security panel; lockouts are audited.
+	throttle := auth.NewThrottle(cfg.MaxFailures, cfg.LockoutDuration)
+	throttle.OnChange = st.UpdateAuthSecurity
+	throttle.OnLockout = func(l models.AuthLockout) {
+		log.Printf("[auth] %s %s locked out until %s after %d failed sign-ins", l.Kind, l.Key, l.Until.Format(time.RFC3339), l.Failures)
+		auditLockout(auditLog, l)
+	}
+	st.UpdateAuthSecurity(throttle.Snapshot())
+
+	comp := &api.Components{
+		Jobs:       jobMgr,
+		Audit:      auditLog,
+		Users:      users,
+		Sessions:   auth.NewSessions(),
+		Tokens:     tokens,
+		Throttle:   throttle,
+		Limiter:    api.NewRateLimiter(cfg.ActionsBurst, cfg.ActionsInterval),
+		Confirm:    api.NewConfirmations(),
+		LogStreams: api.NewLogStreams(),
+	}
+
 	// HTTP server
-	router := api.NewRouter(st, cfg, webFS)
+	router := &reloadableHandler{}
+	router.set(api.NewRouter(st, hist, orch, comp, cfg, webFS))
 	srv := &http.Server{
 		Addr:         cfg.ListenAddr,
 		Handler:      router,
@@ -47,6 +134,23 @@ func main() {
 		}
 	}()
 
+	// Hot reload on SIGHUP or config file change
+	go config.Watch(ctx, cfg, func(next *config.Config) {
+		if next.ListenAddr != cfg.ListenAddr || next.DataDir != cfg.DataDir {
+			log.Printf("[config] listen address and data dir changes require a restart")
+		}
+		rules, err := alert.LoadRules(next.AlertRulesFile)
+		if err != nil {
+			log.Printf("[config] alert rules: %v (keeping previous rules)", err)
+		} else {
+			alerts.Reload(rules, alert.NotifiersFromConfig(next))
+		}
+		orch.Reload(next)
+		st.SetMaxSubscribers(next.SSEMaxSubscribers)
+		comp.Limiter.SetLimits(next.ActionsBurst, next.ActionsInterval)
+		router.set(api.NewRouter(st, hist, orch, comp, next, webFS))
+	})
+
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -58,4 +162,60 @@ func main() {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdownCancel()
 	srv.Shutdown(shutdownCtx)
+
+	if err := hist.Flush(); err != nil {
+		log.Printf("history flush: %v", err)
+	}
+	if err := tokens.Flush(); err != nil {
+		log.Printf("tokens flush: %v", err)
+	}
+}
+
+// auditJob records the outcome of a finished action job; the request that
+// queued it is logged separately by the API.
+func auditJob(al *audit.Log, j jobs.Job) {
+	e := audit.Entry{
+		User:    j.User,
+		Action:  "job/" + j.Action,
+		Params:  j.Params,
+		Outcome: j.State,
+		Error:   j.Error,
+		JobID:   j.ID,
+	}
+	if j.StartedAt != nil && j.FinishedAt != nil {
+		e.DurationMs = float64(j.FinishedAt.Sub(*j.StartedAt).Microseconds()) / 1000
+	}
+	if err := al.Append(e); err != nil {
+		log.Printf("[audit] %v", err)
+	}
+}
+
+// auditLockout records a sign-in lockout.
+func auditLockout(al *audit.Log, l models.AuthLockout) {
+	e := audit.Entry{
+		Time:    l.Time,
+		Action:  "login/lockout",
+		Params:  map[string]string{"until": l.Until.Format(time.RFC3339), "failures": strconv.Itoa(l.Failures)},
+		Outcome: "locked",
+	}
+	if l.Kind == "ip" {
+		e.IP = l.Key
+	} else {
+		e.User = l.Key
+	}
+	if err := al.Append(e); err != nil {
+		log.Printf("[audit] %v", err)
+	}
+}
+
+// reloadableHandler lets the router be rebuilt on config reload while the
+// server keeps running.
+type reloadableHandler struct {
+	h atomic.Pointer[http.Handler]
+}
+
+func (rh *reloadableHandler) set(h http.Handler) { rh.h.Store(&h) }
+
+func (rh *reloadableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*rh.h.Load()).ServeHTTP(w, r)
 }
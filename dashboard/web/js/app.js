@@ -1,21 +1,100 @@
 // Arctic Monitor - Main Application
 
+// Host-level actions need a confirmation nonce before they run.
+var CONFIRMED_ACTIONS = { 'restart-vm': true, 'update-system': true };
+
+function confirmNonce(action) {
+    if (!CONFIRMED_ACTIONS[action]) return Promise.resolve(null);
+    return fetch('/api/actions/' + action + '/confirm', { method: 'POST' })
+        .then(function(r) { return r.json(); })
+        .then(function(data) {
+            if (data.error) throw new Error(data.error);
+            return data.nonce;
+        });
+}
+
 // Action buttons
 function doAction(action, btn) {
     if (btn.classList.contains('loading')) return;
     btn.classList.add('loading');
-    fetch('/api/actions/' + action, { method: 'POST' })
+    confirmNonce(action)
+        .then(function(nonce) {
+            var headers = nonce ? { 'X-Confirm-Nonce': nonce } : {};
+            return fetch('/api/actions/' + action, { method: 'POST', headers: headers });
+        })
         .then(function(r) { return r.json(); })
         .then(function(data) {
             if (data.error) {
                 alert('Error: ' + data.error);
+            } else if (data.id) {
+                followJob(data.id, btn);
+                return;
             }
+            btn.classList.remove('loading');
         })
         .catch(function(err) {
             alert('Action failed: ' + err.message);
+            btn.classList.remove('loading');
+        });
+}
+
+// Follow an action job until it finishes.
+function followJob(id, btn) {
+    var es = new EventSource('/api/jobs/' + id + '/events');
+    es.onmessage = function(e) {
+        var msg = JSON.parse(e.data);
+        if (msg.event !== 'job') return;
+        var job = msg.data;
+        if (job.state === 'succeeded' || job.state === 'failed') {
+            es.close();
+            btn.classList.remove('loading');
+            if (job.state === 'failed') {
+                alert('Action failed: ' + job.error);
+            }
+        }
+    };
+    es.onerror = function() {
+        es.close();
+        btn.classList.remove('loading');
+    };
+}
+
+// Session: show the signed-in user and hide actions above their role.
+var ROLE_RANK = { viewer: 1, operator: 2, admin: 3 };
+
+function loadIdentity() {
+    fetch('/api/me')
+        .then(function(r) {
+            if (r.status === 401) {
+                location.replace('/login.html?next=' + encodeURIComponent(location.pathname));
+                return null;
+            }
+            return r.json();
+        })
+        .then(function(me) {
+            if (!me) return;
+            document.querySelectorAll('[data-role]').forEach(function(el) {
+                if ((ROLE_RANK[me.role] || 0) < ROLE_RANK[el.dataset.role]) {
+                    el.style.display = 'none';
+                }
+            });
+            if (me.user) {
+                document.getElementById('user-name').textContent = me.user;
+                document.getElementById('user-role').textContent = me.role;
+                document.getElementById('header-user').style.display = '';
+                // Proxy sign-ins are ended at the proxy, not here.
+                document.getElementById('logout-btn').style.display = me.method === 'session' ? '' : 'none';
+            }
         })
+        .catch(function(err) {
+            console.error('Failed to load identity:', err);
+        });
+}
+
+function logout() {
+    fetch('/api/logout', { method: 'POST' })
         .finally(function() {
-            btn.classList.remove('loading');
+            location.replace('/login.html');
         });
 }
 
@@ -70,30 +149,39 @@ function closeConfirm() {
             if (data.services) renderServices(data.services);
             if (data.streams) renderStreams(data.streams);
             if (data.torrents) renderTorrents(data.torrents);
+            if (data.vpn) renderVPN(data.vpn);
+            if (data.vpnLeak) renderVPNLeak(data.vpnLeak);
             if (data.downloads) renderDownloads(data.downloads);
             if (data.requests) renderRequests(data.requests);
             if (data.transcodes) renderTranscoding(data.transcodes);
             if (data.health) renderHealth(data.health);
             if (data.library) renderLibrary(data.library);
             if (data.sshSecurity) renderSSHSecurity(data.sshSecurity);
+            if (data.authSecurity) renderAuthSecurity(data.authSecurity);
+            if (data.collectors) renderCollectors(data.collectors);
         } catch (e) {
             console.error('Failed to load overview:', e);
         }
     }
 
+    loadIdentity();
     loadOverview();
 
     // SSE connection with auto-reconnect
     var sse = null;
     var reconnectTimer = null;
+    var lastEventId = '';
     var statusDot = document.getElementById('sse-status');
+    var SSE_EVENTS = ['host', 'services', 'streams', 'torrents', 'downloads', 'requests',
+        'transcodes', 'health', 'library', 'sshSecurity', 'authSecurity', 'collectors', 'vpn', 'vpnLeak'];
 
     function connectSSE() {
         if (sse) {
             sse.close();
         }
 
-        sse = new EventSource('/api/events');
+        // Resume from the last event so missed updates are replayed.
+        sse = new EventSource('/api/events' + (lastEventId ? '?lastEventId=' + encodeURIComponent(lastEventId) : ''));
 
         sse.onopen = function() {
             statusDot.className = 'sse-status connected';
@@ -104,14 +192,18 @@ function closeConfirm() {
             }
         };
 
-        sse.onmessage = function(e) {
-            try {
-                var msg = JSON.parse(e.data);
-                handleEvent(msg.event, msg.data);
-            } catch (err) {
-                console.error('SSE parse error:', err);
-            }
-        };
+        function listen(name) {
+            sse.addEventListener(name, function(e) {
+                lastEventId = e.lastEventId;
+                try {
+                    handleEvent(name, JSON.parse(e.data));
+                } catch (err) {
+                    console.error('SSE parse error:', err);
+                }
+            });
+        }
+        listen('snapshot');
+        SSE_EVENTS.forEach(listen);
 
         sse.onerror = function() {
             statusDot.className = 'sse-status disconnected';
@@ -125,6 +217,11 @@ function closeConfirm() {
 
     function handleEvent(event, data) {
         switch (event) {
+            case 'snapshot':
+                Object.keys(data).forEach(function(name) {
+                    if (data[name]) handleEvent(name, data[name]);
+                });
+                break;
             case 'host':
                 renderHost(data);
                 break;
@@ -137,6 +234,12 @@ function closeConfirm() {
             case 'torrents':
                 renderTorrents(data);
                 break;
+            case 'vpn':
+                renderVPN(data);
+                break;
+            case 'vpnLeak':
+                renderVPNLeak(data);
+                break;
             case 'downloads':
                 renderDownloads(data);
                 break;
@@ -155,6 +258,12 @@ function closeConfirm() {
             case 'sshSecurity':
                 renderSSHSecurity(data);
                 break;
+            case 'authSecurity':
+                renderAuthSecurity(data);
+                break;
+            case 'collectors':
+                renderCollectors(data);
+                break;
         }
     }
 
@@ -113,13 +113,16 @@ function renderServices(services) {
         const healthClass = svc.health || 'stopped';
         const healthLabel = healthLabels[healthClass] || healthLabels.none;
         const icon = getSvcIcon(svc.name);
+        const exit = svc.lastExitCode != null ? `, last exit ${svc.lastExitCode}` : '';
+        const usage = svc.stats ? `<div class="service-meta">CPU ${formatPercent(svc.stats.cpuPercent)} \u00B7 ${formatBytes(svc.stats.memUsage)}</div>` : '';
+        const restarts = svc.restartCount > 0 ? ` \u00B7 <span class="service-restarts" title="Restarts${exit}">\u21BB ${svc.restartCount}</span>` : '';
         const linkIcon = svc.externalUrl ? '<svg class="link-icon" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><path d="M18 13v6a2 2 0 01-2 2H5a2 2 0 01-2-2V8a2 2 0 012-2h6"/><polyline points="15 3 21 3 21 9"/><line x1="10" y1="14" x2="21" y2="3"/></svg>' : '';
         return `<${tag} class="service-card"${href}>
             <span class="service-dot ${healthClass}"></span>
             ${icon}
             <div class="service-info">
                 <div class="service-name">${esc(svc.name)}${linkIcon}</div>
-                <div class="service-meta"><span class="service-health-text ${healthClass}">${healthLabel}</span> \u00B7 ${esc(svc.uptime)}</div>
+                <div class="service-meta"><span class="service-health-text ${healthClass}">${healthLabel}</span> \u00B7 ${esc(svc.uptime)}${restarts}</div>${usage}
             </div>
         </${tag}>`;
     }).join('');
@@ -182,6 +185,37 @@ function renderTorrents(torrents) {
     `).join('');
 }
 
+function renderVPN(vpn) {
+    if (!vpn || !vpn.status) return;
+    const status = document.getElementById('vpn-status');
+    const where = [vpn.publicIp, vpn.country].filter(Boolean).join(' \u00B7 ');
+    status.textContent = vpn.status + (where ? ' \u00B7 ' + where : '');
+    status.className = 'stat-value' + (vpn.status === 'running' ? '' : ' vpn-down');
+
+    const port = document.getElementById('vpn-port');
+    const mismatch = vpn.forwardedPort && vpn.qbitListenPort && vpn.forwardedPort !== vpn.qbitListenPort;
+    port.textContent = vpn.forwardedPort
+        ? vpn.forwardedPort + (mismatch ? ` (qBittorrent: ${vpn.qbitListenPort})` : '')
+        : 'none';
+    port.className = 'stat-value' + (mismatch ? ' vpn-mismatch' : '');
+}
+
+function renderVPNLeak(leak) {
+    if (!leak || !leak.checkedAt) return;
+    const el = document.getElementById('vpn-leak');
+    const containers = leak.containers || [];
+    const leaking = containers.filter(c => c.leak || (c.networkMode && !c.behindVpn));
+    if (leaking.length > 0) {
+        el.textContent = 'LEAK: ' + leaking.map(c => c.name).join(', ');
+    } else if (containers.some(c => c.error)) {
+        el.textContent = 'unverified: ' + containers.filter(c => c.error).map(c => c.name).join(', ');
+    } else {
+        el.textContent = 'none (' + containers.length + ' checked)';
+    }
+    el.className = 'stat-value' + (leaking.length > 0 ? ' vpn-down' : '');
+    el.title = containers.map(c => c.name + ': ' + (c.egressIp || c.error || c.networkMode)).join('\n');
+}
+
 function renderDownloads(downloads) {
     const list = document.getElementById('download-list');
     if (!downloads || downloads.length === 0) {
@@ -262,10 +296,12 @@ function renderHealth(health) {
     }
 
     list.innerHTML = health.map(h => {
-        const icon = h.type === 'error'
+        const severe = h.type === 'error' || h.type === 'critical';
+        const icon = severe
             ? '<svg class="inline-icon err" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><circle cx="12" cy="12" r="10"/><line x1="15" y1="9" x2="9" y2="15"/><line x1="9" y1="9" x2="15" y2="15"/></svg>'
             : '<svg class="inline-icon warn" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><path d="M10.29 3.86L1.82 18a2 2 0 001.71 3h16.94a2 2 0 001.71-3L13.71 3.86a2 2 0 00-3.42 0z"/><line x1="12" y1="9" x2="12" y2="13"/><line x1="12" y1="17" x2="12.01" y2="17"/></svg>';
-        return `<div class="health-item ${h.type === 'error' ? 'error' : ''}">
+        const cls = h.type === 'critical' ? 'error critical' : (severe ? 'error' : '');
+        return `<div class="health-item ${cls}">
             ${icon}
             <span class="health-source">${esc(h.source)}</span>
             <span class="health-message">${esc(h.message)}</span>
@@ -335,6 +371,31 @@ function renderSSHSecurity(data) {
     }
 }
 
+// Dashboard sign-in lockouts, shown under the SSH data
+function renderAuthSecurity(data) {
+    if (!data) return;
+    var failed = data.failed24h || 0;
+    document.getElementById('auth-failed').textContent =
+        failed.toLocaleString() + ' failed sign-in' + (failed === 1 ? '' : 's') + ' (24h)';
+
+    const list = document.getElementById('auth-lockouts');
+    if (!data.lockouts || data.lockouts.length === 0) {
+        list.innerHTML = '<p class="empty-state">No lockouts</p>';
+        return;
+    }
+    var now = Date.now();
+    list.innerHTML = data.lockouts.map(l =>
+        `<div class="ssh-entry ${new Date(l.until).getTime() > now ? 'ssh-entry-offender' : 'ssh-entry-fail'}">
+            <span class="ssh-entry-${l.kind === 'user' ? 'user' : 'ip'}">${esc(l.key)}</span>
+            <span class="ssh-entry-meta">
+                <span class="ssh-entry-method">${esc(l.kind)}</span>
+                <span class="ssh-entry-time">${timeAgo(l.time)}</span>
+                <span class="ssh-entry-count">${l.failures}</span>
+            </span>
+        </div>`
+    ).join('');
+}
+
 function updateSSHCounters() {
     if (!_sshData) return;
     var d = _sshData;
@@ -364,3 +425,46 @@ document.getElementById('ssh-period-toggle').addEventListener('click', function(
     btn.classList.add('active');
     updateSSHCounters();
 });
+
+// Collector health: dim panels whose data source is failing
+const collectorSections = {
+    'host': 'host-section',
+    'docker': 'services-section',
+    'jellyfin': 'streams-section',
+    'qbittorrent': 'torrents-section',
+    'gluetun': 'torrents-section',
+    'vpn-leak': 'torrents-section',
+    'unmanic': 'transcoding-section',
+    'seerr': 'requests-section',
+    'radarr': 'downloads-section',
+    'sonarr': 'downloads-section',
+    'sabnzbd': 'downloads-section',
+    'jellyfin-library': 'library-section',
+    'prowlarr': 'health-section',
+    'bazarr': 'health-section',
+    'ssh-security': 'ssh-section'
+};
+
+function renderCollectors(collectors) {
+    if (!collectors) return;
+    const problems = {};
+    collectors.forEach(c => {
+        const section = collectorSections[c.name];
+        if (!section) return;
+        if (c.state === 'error') {
+            const msg = `${c.name}: ${c.lastError} (last success ${c.lastSuccess ? timeAgo(c.lastSuccess) : 'never'})`;
+            (problems[section] = problems[section] || []).push(msg);
+        }
+    });
+    new Set(Object.values(collectorSections)).forEach(id => {
+        const el = document.getElementById(id);
+        if (!el) return;
+        if (problems[id]) {
+            el.classList.add('stale');
+            el.title = problems[id].join('\n');
+        } else {
+            el.classList.remove('stale');
+            el.removeAttribute('title');
+        }
+    });
+}
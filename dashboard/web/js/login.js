@@ -0,0 +1,64 @@
+// Arctic Monitor - Login page
+
+(function() {
+    'use strict';
+
+    var form = document.getElementById('login-form');
+    var errorEl = document.getElementById('login-error');
+
+    // Only follow same-origin relative paths after login.
+    function nextURL() {
+        var next = new URLSearchParams(location.search).get('next') || '/';
+        if (next.charAt(0) !== '/' || next.charAt(1) === '/' || next.charAt(1) === '\\') {
+            return '/';
+        }
+        return next;
+    }
+
+    var params = new URLSearchParams(location.search);
+    if (params.get('error')) {
+        errorEl.textContent = params.get('error');
+    }
+
+    // Offer the sign-in methods the server has configured.
+    fetch('/api/auth/config')
+        .then(function(r) { return r.json(); })
+        .then(function(cfg) {
+            if (cfg.oidc) {
+                var sso = document.getElementById('login-sso');
+                sso.href = '/api/auth/oidc/login?next=' + encodeURIComponent(nextURL());
+                sso.style.display = '';
+            }
+            if (!cfg.local && cfg.oidc) {
+                document.getElementById('login-local').style.display = 'none';
+            }
+        })
+        .catch(function() {});
+
+    form.addEventListener('submit', function(e) {
+        e.preventDefault();
+        errorEl.textContent = '';
+        fetch('/api/login', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({
+                username: form.username.value,
+                password: form.password.value
+            })
+        })
+            .then(function(r) {
+                if (r.ok) {
+                    location.replace(nextURL());
+                    return;
+                }
+                return r.json().then(function(data) {
+                    errorEl.textContent = data.error || 'Sign in failed';
+                    form.password.value = '';
+                    form.password.focus();
+                });
+            })
+            .catch(function(err) {
+                errorEl.textContent = 'Sign in failed: ' + err.message;
+            });
+    });
+})();
@@ -22,7 +22,7 @@ echo -e "${YELLOW}[1/6] Creating directory structure...${NC}"
 mkdir -p "$MEDIASERVER_DIR"
 cd "$MEDIASERVER_DIR"
 
-mkdir -p config/{gluetun,qbittorrent,prowlarr,radarr,sonarr,jellyfin,jellyseerr,bazarr,sabnzbd,unmanic,npm/data,npm/letsencrypt}
+mkdir -p config/{gluetun,qbittorrent,prowlarr,radarr,sonarr,jellyfin,jellyseerr,bazarr,sabnzbd,unmanic,npm/data,npm/letsencrypt,arcticmon}
 
 echo -e "${YELLOW}[2/5] Checking for .env file...${NC}"
 if [ ! -f ".env" ]; then